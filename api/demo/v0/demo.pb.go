@@ -27,13 +27,13 @@ type GetArticlesReq struct {
 	unknownFields protoimpl.UnknownFields
 
 	// @inject_tag: form:"title"
-	Title string `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
-	// @inject_tag: form:"page"
-	Page int32 `protobuf:"varint,2,opt,name=page,proto3" json:"page,omitempty"`
-	// @inject_tag: form:"page_size"
-	PageSize int32 `protobuf:"varint,3,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	Title string `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty" form:"title"`
+	// @inject_tag: form:"page" binding:"omitempty,min=1"
+	Page int32 `protobuf:"varint,2,opt,name=page,proto3" json:"page,omitempty" form:"page" binding:"omitempty,min=1"`
+	// @inject_tag: form:"page_size" binding:"omitempty,min=1,max=100"
+	PageSize int32 `protobuf:"varint,3,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty" form:"page_size" binding:"omitempty,min=1,max=100"`
 	// @inject_tag: form:"author_id" uri:"author_id"
-	AuthorId int32 `protobuf:"varint,4,opt,name=author_id,json=authorId,proto3" json:"author_id,omitempty"`
+	AuthorId int32 `protobuf:"varint,4,opt,name=author_id,json=authorId,proto3" json:"author_id,omitempty" form:"author_id" uri:"author_id"`
 }
 
 func (x *GetArticlesReq) Reset() {
@@ -159,7 +159,7 @@ type Article struct {
 	Title   string `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
 	Content string `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
 	// @inject_tag: form:"author_id" uri:"author_id"
-	AuthorId int32 `protobuf:"varint,3,opt,name=author_id,json=authorId,proto3" json:"author_id,omitempty"`
+	AuthorId int32 `protobuf:"varint,3,opt,name=author_id,json=authorId,proto3" json:"author_id,omitempty" form:"author_id" uri:"author_id"`
 }
 
 func (x *Article) Reset() {
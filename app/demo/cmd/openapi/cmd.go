@@ -0,0 +1,45 @@
+package openapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	demo_v0 "nautilus/api/demo/v0"
+	"nautilus/pkg/openapi"
+
+	"github.com/spf13/cobra"
+)
+
+// out 输出文件，为空时输出到标准输出
+var out string
+
+var Cmd = &cobra.Command{
+	Use:   "openapi",
+	Short: "generate openapi doc",
+	Long:  "generate OpenAPI 3 doc from api/*.proto http annotations",
+	Run: func(cmd *cobra.Command, args []string) {
+		b, err := json.MarshalIndent(Document(), "", "  ")
+		if err != nil {
+			panic(err)
+		}
+
+		if out == "" {
+			os.Stdout.Write(b)
+			return
+		}
+
+		if err := ioutil.WriteFile(out, b, 0644); err != nil {
+			panic(err)
+		}
+	},
+}
+
+func init() {
+	Cmd.Flags().StringVar(&out, "out", "", "output file")
+}
+
+// Document demo 应用的接口文档
+func Document() *openapi.Document {
+	return openapi.Generate("demo", "v0", demo_v0.File_demo_v0_demo_proto)
+}
@@ -5,6 +5,12 @@ import "github.com/spf13/cobra"
 // port http server port
 var port int
 
+// grpcPort grpc server port
+var grpcPort int
+
+// docs serve openapi doc and swagger ui at /docs
+var docs bool
+
 // internal http server internal: v0 package
 var internal bool
 
@@ -19,5 +25,7 @@ var Cmd = &cobra.Command{
 
 func init() {
 	Cmd.Flags().IntVar(&port, "port", 8080, "")
+	Cmd.Flags().IntVar(&grpcPort, "grpc-port", 9090, "")
 	Cmd.Flags().BoolVar(&internal, "internal", false, "")
+	Cmd.Flags().BoolVar(&docs, "docs", false, "")
 }
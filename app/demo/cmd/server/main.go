@@ -1,20 +1,43 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"nautilus/pkg/admin"
 	"nautilus/pkg/conf"
+	"nautilus/pkg/deadline"
+	xerrors "nautilus/pkg/errors"
+	"nautilus/pkg/health"
+	"nautilus/pkg/interceptor"
+	"nautilus/pkg/loadshed"
 	"nautilus/pkg/log"
 	"nautilus/pkg/middleware"
+	"nautilus/pkg/rbac"
+	"nautilus/pkg/trace"
 
 	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
 )
 
+// srv http server，stopServer 中优雅退出
+var srv = &http.Server{}
+
+// adminSrv 管理端口，stopServer 中最后关闭，退出过程中 /readyz 仍然可以访问
+var adminSrv *admin.Server
+
+// grpcSrv grpc server，stopServer 中和 http server 一起优雅退出
+var grpcSrv *grpc.Server
+
+// enforcer HTTP 和 grpc 共用的权限策略
+var enforcer = rbac.NewEnforcer(rbac.FromConf())
+
 func main() {
 	reload := make(chan struct{}, 1)
 	stop := make(chan os.Signal, 1)
@@ -24,24 +47,45 @@ func main() {
 	conf.WatchConfig()
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
 
+	// grpc 错误详情中的 domain 使用 app id
+	xerrors.SetDomain(conf.AppID)
+
+	// 需要在创建 gin/grpc server 之前初始化，中间件和拦截器使用全局的 TracerProvider
+	if err := trace.Init(context.Background(), trace.ConfigFromConf()); err != nil {
+		panic(err)
+	}
+
+	// metrics/pprof/日志等级等管理接口在单独的端口上，不对外开放
+	adminSrv = admin.FromConf()
+	if err := adminSrv.Start(); err != nil {
+		panic(err)
+	}
+
+	if err := enforcer.Load(context.Background()); err != nil {
+		panic(err)
+	}
+
+	grpcSrv = newGRPCServer()
+
 	go func() {
 		for {
 			select {
 			case <-reload:
-				// TODO reset
+				// 配置文件变更时只重新读取日志等级，进程继续运行
 				log.Reset()
-				os.Exit(0)
 			case sg := <-stop:
-				fmt.Println("exit ....")
-				if sg == syscall.SIGINT {
-					os.Exit(0)
-				} else {
-					os.Exit(0)
-				}
+				log.Get(context.Background()).WithField("signal", sg.String()).Info("exit")
+				stopServer()
+				// 上报缓冲的 span
+				trace.Stop()
+				// 等待缓冲的日志发送到 log agent
+				log.Close()
+				os.Exit(0)
 			}
 		}
 	}()
 
+	go startGRPCServer()
 	startServer()
 }
 
@@ -49,16 +93,88 @@ func startServer() {
 	// gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 
-	// metrics
-	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
-
 	// middleware
+	router.Use(middleware.Client())
 	router.Use(middleware.Logging())
-	router.Use(middleware.Timeout(time.Millisecond * 50000))
 	router.Use(middleware.NewTraceID())
+	router.Use(middleware.LoadShed(loadshed.NewLimiter("http", loadshed.FromConf()), loadshed.PrioritiesFromConf()))
+	if conf.GetBool("ACCESS_LOG") {
+		router.Use(middleware.AccessLog(middleware.AccessLogConfigFromConf()))
+	}
+	router.Use(middleware.Recovery())
+	router.Use(middleware.Timeout(time.Millisecond*50000, deadline.Routes()))
+
+	// demo 没有数据库等依赖，只检查 trace 上报，失败时标记为 degraded
+	health.Register("trace", health.TraceExporter(), false)
 
-	register(router, internal)
-	router.Run(":8080")
+	register(router, internal, enforcer)
+	srv.Addr = fmt.Sprintf(":%d", port)
+	srv.Handler = router
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		panic(err)
+	}
+
+	// 等待 stopServer 完成之后退出
+	select {}
+}
+
+// newGRPCServer 创建 grpc server，和 HTTP 共用 ctrl 实现
+func newGRPCServer() *grpc.Server {
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			interceptor.UnaryServerInterceptor(),
+			interceptor.UnaryServerTimeout(time.Millisecond*50000),
+			interceptor.UnaryServerAuth(nil, enforcer),
+			interceptor.UnaryServerValidator(),
+		),
+		grpc.ChainStreamInterceptor(
+			interceptor.StreamServerInterceptor(),
+			interceptor.StreamServerAuth(nil, enforcer),
+		),
+	)
+
+	registerGRPC(s, internal)
+	return s
+}
+
+// startGRPCServer 启动 grpc server
+func startGRPCServer() {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", grpcPort))
+	if err != nil {
+		panic(err)
+	}
+
+	if err := grpcSrv.Serve(lis); err != nil {
+		panic(err)
+	}
 }
 
-func stopServer() {}
+// stopServer 优雅退出：先让 /readyz 返回 503，等待负载均衡摘除实例之后再停止接收新的请求，
+// 最后等待正在处理的请求完成
+func stopServer() {
+	health.Shutdown()
+	time.Sleep(conf.GetDuration("HEALTH_DRAIN_DELAY"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Get(ctx).WithError(err).Error("shutdown server failed")
+	}
+
+	// GracefulStop 等待所有 rpc 完成，超时之后强制关闭
+	done := make(chan struct{})
+	go func() {
+		grpcSrv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Get(ctx).Error("graceful stop grpc server timeout")
+		grpcSrv.Stop()
+	}
+
+	if err := adminSrv.Shutdown(ctx); err != nil {
+		log.Get(ctx).WithError(err).Error("shutdown admin server failed")
+	}
+}
@@ -2,15 +2,36 @@ package server
 
 import (
 	demo_v0 "nautilus/api/demo/v0"
+	"nautilus/app/demo/cmd/openapi"
 	serverDemo_v0 "nautilus/ctrl/demov0"
+	"nautilus/pkg/middleware"
+	xopenapi "nautilus/pkg/openapi"
+	"nautilus/pkg/rbac"
+	"nautilus/pkg/response"
 
 	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
 )
 
-func register(router *gin.Engine, internal bool) {
+// register 注册路由，接口的鉴权规则在 proto 中通过 (nautilus.auth.rule) 声明
+func register(router *gin.Engine, internal bool, e *rbac.Enforcer) {
+
+	// 接口文档
+	if docs {
+		xopenapi.Register(router, openapi.Document())
+	}
+
+	// 内网接口
+	if internal {
+		demo_v0.RegisterBlogServiceHTTPServerWithAuth(router, &serverDemo_v0.DemoServer{}, response.Writer{}, middleware.Authorize(nil, e))
+	}
+}
+
+// registerGRPC 注册 grpc 服务，和 HTTP 共用同一个 ctrl 实现
+func registerGRPC(s *grpc.Server, internal bool) {
 
 	// 内网接口
 	if internal {
-		demo_v0.RegisterBlogServiceHTTPServer(router, &serverDemo_v0.DemoServer{})
+		demo_v0.RegisterBlogServiceServer(s, &serverDemo_v0.DemoServer{})
 	}
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"nautilus/app/demo/cmd/help"
 	"nautilus/app/demo/cmd/job"
+	"nautilus/app/demo/cmd/openapi"
 	"nautilus/app/demo/cmd/server"
 
 	"github.com/spf13/cobra"
@@ -22,6 +23,7 @@ func main() {
 		help.Cmd,
 		server.Cmd,
 		job.Cmd,
+		openapi.Cmd,
 	)
 
 	rootCmd.Execute()
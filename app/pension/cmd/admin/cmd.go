@@ -0,0 +1,54 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+
+	dao "nautilus/dao/admin"
+	"nautilus/svc/admin"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	username string
+	password string
+	phone    string
+	super    bool
+)
+
+// Cmd 创建管理员账号，密码使用 bcrypt 哈希后保存
+//
+//	pension admin --username foo --password bar
+//	pension admin --phone 13800000000 --super
+var Cmd = &cobra.Command{
+	Use:   "admin",
+	Short: "create admin",
+	Long:  "create an admin account",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p := dao.Profile{Username: username, Password: password, Phone: phone}
+		if super {
+			if phone == "" {
+				return fmt.Errorf("super admin must have a phone")
+			}
+			p.RoleType = dao.RoleSuper
+		} else if username == "" || password == "" {
+			return fmt.Errorf("username and password are required")
+		}
+
+		id, err := admin.CreateAdmin(context.Background(), p)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("admin %d created\n", id)
+		return nil
+	},
+}
+
+func init() {
+	Cmd.Flags().StringVar(&username, "username", "", "")
+	Cmd.Flags().StringVar(&password, "password", "", "")
+	Cmd.Flags().StringVar(&phone, "phone", "", "")
+	Cmd.Flags().BoolVar(&super, "super", false, "super admin, login by phone")
+}
@@ -0,0 +1,19 @@
+package server
+
+import "github.com/spf13/cobra"
+
+// port http server port
+var port int
+
+var Cmd = &cobra.Command{
+	Use:   "server",
+	Short: "server",
+	Long:  "pension server",
+	Run: func(cmd *cobra.Command, args []string) {
+		main()
+	},
+}
+
+func init() {
+	Cmd.Flags().IntVar(&port, "port", 8080, "")
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	dao "nautilus/dao/admin"
+	"nautilus/pkg/admin"
+	"nautilus/pkg/conf"
+	"nautilus/pkg/deadline"
+	xerrors "nautilus/pkg/errors"
+	"nautilus/pkg/health"
+	"nautilus/pkg/loadshed"
+	"nautilus/pkg/log"
+	"nautilus/pkg/middleware"
+	"nautilus/pkg/ratelimit"
+	"nautilus/pkg/rbac"
+	"nautilus/pkg/recovery"
+	"nautilus/pkg/trace"
+
+	"github.com/gin-gonic/gin"
+)
+
+// srv http server，stopServer 中优雅退出
+var srv = &http.Server{}
+
+// adminSrv 管理端口，stopServer 中最后关闭，退出过程中 /readyz 仍然可以访问
+var adminSrv *admin.Server
+
+func main() {
+	reload := make(chan struct{}, 1)
+	stop := make(chan os.Signal, 1)
+
+	// 监听配置文件变更
+	conf.OnConfigChange(func() { reload <- struct{}{} })
+	conf.WatchConfig()
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+
+	// grpc 错误详情中的 domain 使用 app id
+	xerrors.SetDomain(conf.AppID)
+
+	// 需要在创建 gin/grpc server 之前初始化，中间件和拦截器使用全局的 TracerProvider
+	if err := trace.Init(context.Background(), trace.ConfigFromConf()); err != nil {
+		panic(err)
+	}
+
+	// metrics/pprof/日志等级等管理接口在单独的端口上，不对外开放
+	adminSrv = admin.FromConf()
+	if err := adminSrv.Start(); err != nil {
+		panic(err)
+	}
+
+	go func() {
+		for {
+			select {
+			case <-reload:
+				// 配置文件变更时只重新读取日志等级，进程继续运行
+				log.Reset()
+			case sg := <-stop:
+				log.Get(context.Background()).WithField("signal", sg.String()).Info("exit")
+				stopServer()
+				// 上报缓冲的 span
+				trace.Stop()
+				// 等待缓冲的日志发送到 log agent
+				log.Close()
+				os.Exit(0)
+			}
+		}
+	}()
+
+	startServer()
+}
+
+func startServer() {
+	router := gin.New()
+
+	// middleware
+	router.Use(middleware.Client())
+	router.Use(middleware.Logging())
+	router.Use(middleware.NewTraceID())
+	router.Use(middleware.LoadShed(loadshed.NewLimiter("http", loadshed.FromConf()), loadshed.PrioritiesFromConf()))
+	if conf.GetBool("ACCESS_LOG") {
+		router.Use(middleware.AccessLog(middleware.AccessLogConfigFromConf()))
+	}
+	router.Use(middleware.Recovery())
+	router.Use(middleware.Timeout(time.Second*5, deadline.Routes()))
+
+	// 限流策略来自配置，单实例使用本地令牌桶，多实例可以换成 ratelimit.NewRedis
+	policies, err := ratelimit.FromConf()
+	if err != nil {
+		panic(err)
+	}
+	router.Use(middleware.RateLimit(ratelimit.NewLocal(), policies...))
+
+	// 权限策略来自配置和数据库，定时重新加载
+	e := rbac.NewEnforcer(rbac.FromConf(), dao.QueryPolicies)
+	if err := e.Load(context.Background()); err != nil {
+		panic(err)
+	}
+	recovery.SafeGo(context.Background(), func(ctx context.Context) {
+		e.Watch(ctx, time.Minute)
+	})
+
+	// 数据库不可用时实例不再接收请求
+	health.Register("mysql", health.SQLX(), true)
+	// trace 上报失败只标记为 degraded
+	health.Register("trace", health.TraceExporter(), false)
+
+	register(router, e)
+	srv.Addr = fmt.Sprintf(":%d", port)
+	srv.Handler = router
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		panic(err)
+	}
+
+	// 等待 stopServer 完成之后退出
+	select {}
+}
+
+// stopServer 优雅退出：先让 /readyz 返回 503，等待负载均衡摘除实例之后再停止接收新的请求，
+// 最后等待正在处理的请求完成
+func stopServer() {
+	health.Shutdown()
+	time.Sleep(conf.GetDuration("HEALTH_DRAIN_DELAY"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Get(ctx).WithError(err).Error("shutdown server failed")
+	}
+
+	if err := adminSrv.Shutdown(ctx); err != nil {
+		log.Get(ctx).WithError(err).Error("shutdown admin server failed")
+	}
+}
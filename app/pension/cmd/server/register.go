@@ -0,0 +1,19 @@
+package server
+
+import (
+	pension_v0 "nautilus/api/pension/v0"
+	serverPension_v0 "nautilus/ctrl/pensionv0"
+	"nautilus/pkg/middleware"
+	"nautilus/pkg/rbac"
+	"nautilus/pkg/response"
+	"nautilus/svc/admin"
+
+	"github.com/gin-gonic/gin"
+)
+
+// register 注册路由，接口的鉴权规则在 proto 中通过 (nautilus.auth.rule) 声明
+func register(router *gin.Engine, e *rbac.Enforcer) {
+	authorize := middleware.Authorize(admin.IsRevoked, e)
+
+	pension_v0.RegisterAdminServiceHTTPServerWithAuth(router, &serverPension_v0.AdminServer{}, response.Writer{}, authorize)
+}
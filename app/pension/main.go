@@ -0,0 +1,23 @@
+package main
+
+import (
+	"nautilus/app/pension/cmd/admin"
+	"nautilus/app/pension/cmd/server"
+
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	cmd := &cobra.Command{
+		Use:   "pension",
+		Short: "pension",
+		Long:  "a project named pension",
+	}
+
+	cmd.AddCommand(
+		server.Cmd,
+		admin.Cmd,
+	)
+
+	cmd.Execute()
+}
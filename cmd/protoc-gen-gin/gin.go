@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"nautilus/api/auth"
+	"nautilus/pkg/httprule"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	authPackage     = protogen.GoImportPath("nautilus/api/auth")
+	contextPackage  = protogen.GoImportPath("context")
+	errorsPackage   = protogen.GoImportPath("errors")
+	ginPackage      = protogen.GoImportPath("github.com/gin-gonic/gin")
+	metadataPackage = protogen.GoImportPath("google.golang.org/grpc/metadata")
+)
+
+// route 一个 http 路由，同一个方法的多个 binding 按顺序编号
+type route struct {
+	method  *protogen.Method
+	handler string
+	verb    string
+	path    string
+	hasVars bool
+	rule    *auth.Rule
+}
+
+// generateFile 生成 ${name}_gin.pb.go，没有 service 的文件不生成
+func generateFile(gen *protogen.Plugin, file *protogen.File) *protogen.GeneratedFile {
+	if len(file.Services) == 0 {
+		return nil
+	}
+
+	g := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+"_gin.pb.go", file.GoImportPath)
+	g.P("// Code generated by protoc-gen-gin. DO NOT EDIT.")
+	g.P("// source: ", file.Desc.Path())
+	g.P()
+	g.P("package ", file.GoPackageName)
+	g.P()
+
+	for _, service := range file.Services {
+		genService(g, service)
+	}
+
+	return g
+}
+
+// routes 方法上 google.api.http 注解声明的所有路由
+func routes(service *protogen.Service) []route {
+	var rs []route
+	for _, m := range service.Methods {
+		authRule, _ := proto.GetExtension(m.Desc.Options(), auth.E_Rule).(*auth.Rule)
+
+		for i, r := range httprule.Rules(m.Desc) {
+			verb, path := httprule.Pattern(r)
+			if path == "" {
+				continue
+			}
+
+			rs = append(rs, route{
+				method:  m,
+				handler: fmt.Sprintf("%s_%d", m.GoName, i),
+				verb:    verb,
+				path:    httprule.GinPath(path),
+				hasVars: len(httprule.Vars(path)) > 0,
+				rule:    authRule,
+			})
+		}
+	}
+
+	return rs
+}
+
+// ruleLiteral 鉴权规则的 go 表达式，没有声明规则时为 nil
+func ruleLiteral(g *protogen.GeneratedFile, r *auth.Rule) string {
+	if r == nil {
+		return "nil"
+	}
+
+	var fields []string
+	if r.Public {
+		fields = append(fields, "Public: true")
+	}
+	if r.Permission != "" {
+		fields = append(fields, fmt.Sprintf("Permission: %q", r.Permission))
+	}
+
+	return "&" + g.QualifiedGoIdent(authPackage.Ident("Rule")) + "{" + strings.Join(fields, ", ") + "}"
+}
+
+func genService(g *protogen.GeneratedFile, service *protogen.Service) {
+	name := service.GoName
+	rs := routes(service)
+
+	g.P("type ", name, "HTTPServer interface {")
+	for _, m := range service.Methods {
+		g.P(m.GoName, "(", g.QualifiedGoIdent(contextPackage.Ident("Context")), ", *", g.QualifiedGoIdent(m.Input.GoIdent), ") (*", g.QualifiedGoIdent(m.Output.GoIdent), ", error)")
+	}
+	g.P("}")
+	g.P()
+
+	g.P("// ", name, "Resp 响应处理，可以通过 Register", name, "HTTPServerWithResp 注入")
+	g.P("type ", name, "Resp interface {")
+	g.P("Error(ctx *", ginPackage.Ident("Context"), ", err error)")
+	g.P("ParamsError(ctx *", ginPackage.Ident("Context"), ", err error)")
+	g.P("Success(ctx *", ginPackage.Ident("Context"), ", data interface{})")
+	g.P("}")
+	g.P()
+
+	g.P("func Register", name, "HTTPServer(r ", ginPackage.Ident("IRouter"), ", srv ", name, "HTTPServer) {")
+	g.P("Register", name, "HTTPServerWithResp(r, srv, default", name, "Resp{})")
+	g.P("}")
+	g.P()
+
+	g.P("// ", name, "Authorizer 根据接口的鉴权规则生成中间件，可以通过 Register", name, "HTTPServerWithAuth 注入")
+	g.P("// rule 为 proto 中通过 (nautilus.auth.rule) 声明的规则，没有声明时为 nil")
+	g.P("type ", name, "Authorizer func(rule *", authPackage.Ident("Rule"), ") ", ginPackage.Ident("HandlerFunc"))
+	g.P()
+
+	g.P("func Register", name, "HTTPServerWithResp(r ", ginPackage.Ident("IRouter"), ", srv ", name, "HTTPServer, resp ", name, "Resp) {")
+	g.P("Register", name, "HTTPServerWithAuth(r, srv, resp, nil)")
+	g.P("}")
+	g.P()
+
+	g.P("func Register", name, "HTTPServerWithAuth(r ", ginPackage.Ident("IRouter"), ", srv ", name, "HTTPServer, resp ", name, "Resp, authorize ", name, "Authorizer) {")
+	g.P("s := ", name, "{")
+	g.P("server:    srv,")
+	g.P("router:    r,")
+	g.P("resp:      resp,")
+	g.P("authorize: authorize,")
+	g.P("}")
+	g.P("s.RegisterService()")
+	g.P("}")
+	g.P()
+
+	g.P("type ", name, " struct {")
+	g.P("server    ", name, "HTTPServer")
+	g.P("router    ", ginPackage.Ident("IRouter"))
+	g.P("resp      ", name, "Resp")
+	g.P("authorize ", name, "Authorizer")
+	g.P("}")
+	g.P()
+
+	g.P("// handlers 在接口前加上鉴权中间件")
+	g.P("func (s *", name, ") handlers(rule *", authPackage.Ident("Rule"), ", h ", ginPackage.Ident("HandlerFunc"), ") []", ginPackage.Ident("HandlerFunc"), " {")
+	g.P("if s.authorize == nil {")
+	g.P("return []", ginPackage.Ident("HandlerFunc"), "{h}")
+	g.P("}")
+	g.P()
+	g.P("return []", ginPackage.Ident("HandlerFunc"), "{s.authorize(rule), h}")
+	g.P("}")
+	g.P()
+
+	genResp(g, name)
+
+	for _, r := range rs {
+		genHandler(g, name, r)
+	}
+
+	g.P("func (s *", name, ") RegisterService() {")
+	for _, r := range rs {
+		g.P("s.router.Handle(", fmt.Sprintf("%q, %q", r.verb, r.path), ", s.handlers(", ruleLiteral(g, r.rule), ", s.", r.handler, ")...)")
+	}
+	g.P("}")
+	g.P()
+}
+
+// genResp 默认的响应处理，返回 {code,msg,data}
+func genResp(g *protogen.GeneratedFile, name string) {
+	resp := "default" + name + "Resp"
+	ctx := g.QualifiedGoIdent(ginPackage.Ident("Context"))
+
+	g.P("// Resp 返回值")
+	g.P("type ", resp, " struct{}")
+	g.P()
+	g.P("func (resp ", resp, ") response(ctx *", ctx, ", status, code int, msg string, data interface{}) {")
+	g.P("ctx.JSON(status, map[string]interface{}{")
+	g.P(`"code": code,`)
+	g.P(`"msg":  msg,`)
+	g.P(`"data": data,`)
+	g.P("})")
+	g.P("}")
+	g.P()
+	g.P("// Error 返回错误信息")
+	g.P("func (resp ", resp, ") Error(ctx *", ctx, ", err error) {")
+	g.P("code := -1")
+	g.P("status := 500")
+	g.P(`msg := "未知错误"`)
+	g.P()
+	g.P("if err == nil {")
+	g.P(`msg += ", err is nil"`)
+	g.P("resp.response(ctx, status, code, msg, nil)")
+	g.P("return")
+	g.P("}")
+	g.P()
+	g.P("type iCode interface {")
+	g.P("HTTPCode() int")
+	g.P("Msg() string")
+	g.P("Code() int")
+	g.P("}")
+	g.P()
+	g.P("var c iCode")
+	g.P("if ", errorsPackage.Ident("As"), "(err, &c) {")
+	g.P("status = c.HTTPCode()")
+	g.P("code = c.Code()")
+	g.P("msg = c.Msg()")
+	g.P("}")
+	g.P()
+	g.P("_ = ctx.Error(err)")
+	g.P()
+	g.P("resp.response(ctx, status, code, msg, nil)")
+	g.P("}")
+	g.P()
+	g.P("// ParamsError 参数错误")
+	g.P("func (resp ", resp, ") ParamsError(ctx *", ctx, ", err error) {")
+	g.P("_ = ctx.Error(err)")
+	g.P(`resp.response(ctx, 400, 400, "参数错误", nil)`)
+	g.P("}")
+	g.P()
+	g.P("// Success 返回成功信息")
+	g.P("func (resp ", resp, ") Success(ctx *", ctx, ", data interface{}) {")
+	g.P(`resp.response(ctx, 200, 0, "成功", data)`)
+	g.P("}")
+	g.P()
+}
+
+// genHandler 绑定参数之后调用 HTTPServer 的实现
+func genHandler(g *protogen.GeneratedFile, name string, r route) {
+	g.P("func (s *", name, ") ", r.handler, "(ctx *", ginPackage.Ident("Context"), ") {")
+	g.P("var in ", r.method.Input.GoIdent)
+	g.P()
+
+	var binds []string
+	if r.hasVars {
+		binds = append(binds, "ShouldBindUri")
+	}
+	if r.verb == http.MethodGet || r.verb == http.MethodDelete {
+		binds = append(binds, "ShouldBindQuery")
+	} else {
+		binds = append(binds, "ShouldBindJSON")
+	}
+	for _, bind := range binds {
+		g.P("if err := ctx.", bind, "(&in); err != nil {")
+		g.P("s.resp.ParamsError(ctx, err)")
+		g.P("return")
+		g.P("}")
+		g.P()
+	}
+
+	g.P("// HTTP header 映射为 grpc incoming metadata，GRPC/HTTP 共用同一套实现")
+	g.P("// deadline 沿用 ctx.Request.Context()")
+	g.P("md := ", metadataPackage.Ident("New"), "(nil)")
+	g.P("for k, v := range ctx.Request.Header {")
+	g.P("md.Set(k, v...)")
+	g.P("}")
+	g.P("newCtx := ", metadataPackage.Ident("NewIncomingContext"), "(ctx.Request.Context(), md)")
+	g.P("out, err := s.server.", r.method.GoName, "(newCtx, &in)")
+	g.P("if err != nil {")
+	g.P("s.resp.Error(ctx, err)")
+	g.P("return")
+	g.P("}")
+	g.P()
+	g.P("s.resp.Success(ctx, out)")
+	g.P("}")
+	g.P()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	demo_v0 "nautilus/api/demo/v0"
+	pension_v0 "nautilus/api/pension/v0"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// update 重新生成 api 下的 *_gin.pb.go，修改模板之后执行
+//
+//	go test ./cmd/protoc-gen-gin -update
+var update = flag.Bool("update", false, "regenerate api/**/*_gin.pb.go")
+
+// generate 使用编译进来的 proto 描述生成代码，和 protoc 调用插件的结果一致
+func generate(t *testing.T, fd protoreflect.FileDescriptor) *pluginpb.CodeGeneratorResponse_File {
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{fd.Path()},
+		Parameter:      proto.String("paths=source_relative"),
+	}
+
+	// 依赖需要排在前面
+	seen := map[string]bool{}
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		req.ProtoFile = append(req.ProtoFile, protodesc.ToFileDescriptorProto(fd))
+	}
+	add(fd)
+
+	gen, err := protogen.Options{}.New(req)
+	assert.Nil(t, err)
+	for _, f := range gen.Files {
+		if f.Generate {
+			generateFile(gen, f)
+		}
+	}
+
+	resp := gen.Response()
+	assert.Nil(t, resp.Error)
+	assert.Len(t, resp.File, 1)
+	return resp.File[0]
+}
+
+func TestGenerate(t *testing.T) {
+	code := generate(t, demo_v0.File_demo_v0_demo_proto).GetContent()
+
+	assert.Contains(t, code, "// Code generated by protoc-gen-gin. DO NOT EDIT.")
+	assert.Contains(t, code, "package demo_v0")
+
+	// 路由和参数绑定
+	assert.Contains(t, code, `s.router.Handle("GET", "/v1/articles", s.handlers(nil, s.GetArticles_0)...)`)
+	assert.Contains(t, code, `s.router.Handle("GET", "/v1/author/:author_id/articles", s.handlers(nil, s.GetArticles_1)...)`)
+	assert.Contains(t, code, `s.router.Handle("POST", "/v1/author/:author_id/articles", s.handlers(nil, s.CreateArticle_0)...)`)
+	assert.Contains(t, code, "ctx.ShouldBindUri(&in)")
+
+	// 响应处理可以注入，默认使用 errors.Error 的错误码和信息
+	assert.Contains(t, code, "func RegisterBlogServiceHTTPServerWithResp(r gin.IRouter, srv BlogServiceHTTPServer, resp BlogServiceResp)")
+	assert.Contains(t, code, "msg = c.Msg()")
+
+	// header 映射为 grpc metadata
+	assert.Contains(t, code, "newCtx := metadata.NewIncomingContext(ctx.Request.Context(), md)")
+}
+
+func TestGenerate_Auth(t *testing.T) {
+	code := generate(t, pension_v0.File_pension_v0_service_proto).GetContent()
+
+	assert.Contains(t, code, "func RegisterAdminServiceHTTPServerWithAuth(r gin.IRouter, srv AdminServiceHTTPServer, resp AdminServiceResp, authorize AdminServiceAuthorizer)")
+	assert.Contains(t, code, `s.router.Handle("POST", "/api/v0/admin/login", s.handlers(&auth.Rule{Public: true}, s.Login_0)...)`)
+	assert.Contains(t, code, `s.router.Handle("POST", "/api/v0/admin/logout", s.handlers(nil, s.Logout_0)...)`)
+	assert.Contains(t, code, `s.router.Handle("POST", "/api/v0/admin/create", s.handlers(&auth.Rule{Permission: "admin:create"}, s.CreateAdmin_0)...)`)
+}
+
+// TestGenerated 提交的生成代码需要和模板保持一致，不能手动修改
+func TestGenerated(t *testing.T) {
+	for _, fd := range []protoreflect.FileDescriptor{
+		demo_v0.File_demo_v0_demo_proto,
+		pension_v0.File_pension_v0_service_proto,
+	} {
+		f := generate(t, fd)
+		path := filepath.Join("..", "..", "api", f.GetName())
+
+		if *update {
+			assert.Nil(t, ioutil.WriteFile(path, []byte(f.GetContent()), 0644))
+			continue
+		}
+
+		b, err := ioutil.ReadFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, string(b), f.GetContent(), "%s is out of date, run go test ./cmd/protoc-gen-gin -update", path)
+	}
+}
@@ -0,0 +1,25 @@
+// protoc-gen-gin 根据 proto 中的 google.api.http 注解生成 gin 路由注册代码
+//
+//	go build -o ./bin/protoc-gen-gin ./cmd/protoc-gen-gin
+//	protoc -I ./api/ --plugin=protoc-gen-gin=./bin/protoc-gen-gin \
+//	--gin_out ./api --gin_opt=paths=source_relative ./api/demo/v0/demo.proto
+//
+// 生成的代码不要手动修改，需要调整时修改这里的模板之后执行 make rpc
+package main
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func main() {
+	protogen.Options{}.Run(func(gen *protogen.Plugin) error {
+		gen.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+		for _, f := range gen.Files {
+			if f.Generate {
+				generateFile(gen, f)
+			}
+		}
+		return nil
+	})
+}
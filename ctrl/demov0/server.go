@@ -9,7 +9,11 @@ import (
 	pb "nautilus/api/demo/v0"
 )
 
-type DemoServer struct{}
+// DemoServer 同时实现 BlogServiceHTTPServer 和 BlogServiceServer
+// 既可以注册到 gin 路由，也可以注册到 grpc server
+type DemoServer struct {
+	pb.UnimplementedBlogServiceServer
+}
 
 func (s *DemoServer) CreateArticle(ctx context.Context, req *pb.Article) (resp *pb.Article, err error) {
 	err = demo.TestTimeout(ctx)
@@ -6,7 +6,7 @@ import (
 	"nautilus/pkg/log"
 	"nautilus/svc/demo"
 
-	pb "nautilus/api/grpc/v0"
+	pb "nautilus/api/grpc_demo/v0"
 )
 
 type Server struct {
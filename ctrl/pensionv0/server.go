@@ -0,0 +1,89 @@
+package pensionv0
+
+import (
+	"context"
+
+	dao "nautilus/dao/admin"
+	"nautilus/pkg/ctxkit"
+	"nautilus/pkg/jwt"
+	"nautilus/svc/admin"
+
+	pb "nautilus/api/pension/v0"
+)
+
+// AdminServer 实现 AdminServiceHTTPServer
+type AdminServer struct{}
+
+// Login 登录，传了手机号时使用短信验证码登录，否则使用用户名密码登录
+func (s *AdminServer) Login(ctx context.Context, req *pb.LoginReq) (resp *pb.LoginResp, err error) {
+	var p jwt.Pair
+	if req.Phone != "" {
+		p, err = admin.LoginByPhone(ctx, req.Phone, req.Code)
+	} else {
+		p, err = admin.LoginByPassword(ctx, req.Username, req.Password)
+	}
+	if err != nil {
+		return
+	}
+
+	resp = &pb.LoginResp{Data: loginData(p)}
+	return
+}
+
+// Logout 退出登录，注销当前会话，需要经过 middleware.Auth
+func (s *AdminServer) Logout(ctx context.Context, req *pb.EmptyReq) (resp *pb.EmptyResp, err error) {
+	err = admin.RevokeToken(ctx, ctxkit.GetTokenID(ctx))
+	if err != nil {
+		return
+	}
+
+	resp = &pb.EmptyResp{}
+	return
+}
+
+// Refresh 使用 refresh token 换取新的 token
+func (s *AdminServer) Refresh(ctx context.Context, req *pb.RefreshReq) (resp *pb.LoginResp, err error) {
+	p, err := admin.RefreshToken(ctx, req.RefreshToken)
+	if err != nil {
+		return
+	}
+
+	resp = &pb.LoginResp{Data: loginData(p)}
+	return
+}
+
+// SendCode 发送登录验证码
+func (s *AdminServer) SendCode(ctx context.Context, req *pb.SendCodeReq) (resp *pb.EmptyResp, err error) {
+	err = admin.SendCode(ctx, req.Phone)
+	if err != nil {
+		return
+	}
+
+	resp = &pb.EmptyResp{}
+	return
+}
+
+// CreateAdmin 创建管理员，需要 admin:create 权限
+func (s *AdminServer) CreateAdmin(ctx context.Context, req *pb.CreateAdminReq) (resp *pb.CreateAdminResp, err error) {
+	id, err := admin.CreateAdmin(ctx, dao.Profile{
+		Username: req.Username,
+		Password: req.Password,
+		Phone:    req.Phone,
+		RoleType: req.RoleType,
+	})
+	if err != nil {
+		return
+	}
+
+	resp = &pb.CreateAdminResp{Id: id}
+	return
+}
+
+// loginData 转换为接口返回的 token 信息
+func loginData(p jwt.Pair) *pb.LoginData {
+	return &pb.LoginData{
+		AccessToken:  p.AccessToken,
+		RefreshToken: p.RefreshToken,
+		ExpiresIn:    p.ExpiresIn,
+	}
+}
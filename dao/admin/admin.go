@@ -7,11 +7,22 @@ import (
 	"nautilus/pkg/sqlx"
 )
 
+const (
+	// RoleNormal 普通管理员
+	RoleNormal int32 = 0
+	// RoleSuper 超级管理员
+	RoleSuper int32 = 1
+)
+
+// columns 查询的字段，username/phone 为 NULL 时返回空字符串
+const columns = "id, IFNULL(username, '') AS username, password, IFNULL(phone, '') AS phone, role_type, ctime, mtime"
+
 // Profile 管理员信息
+// Username/Phone 为空时保存为 NULL，唯一索引只约束不为空的值
 type Profile struct {
 	ID       int64     `db:"id"`
 	Username string    `db:"username"`
-	Password string    `db:"password"`
+	Password string    `db:"password"`  // bcrypt 哈希后的密码
 	Phone    string    `db:"phone"`     // 手机号，普通管理员可以通过账号密码登录，超级管理员必须通过手机号登录
 	RoleType int32     `db:"role_type"` // 0: 普通管理员  1: 超级管理员
 	CTime    time.Time `db:"ctime"`     // 创建时间
@@ -28,6 +39,15 @@ func (p Profile) KeyName() string {
 	return "id"
 }
 
+// Role 返回 rbac 中的角色名，对应配置 RBAC_ROLE_${ROLE} 和 t_role_permission.role
+func (p Profile) Role() string {
+	if p.RoleType == RoleSuper {
+		return "super"
+	}
+
+	return "admin"
+}
+
 // CreateAdmin 创建管理员账号
 func CreateAdmin(ctx context.Context, p Profile) (id int64, err error) {
 	conn := sqlx.Get(ctx, "pension")
@@ -41,7 +61,8 @@ func CreateAdmin(ctx context.Context, p Profile) (id int64, err error) {
 		p.MTime = now
 	}
 
-	result, err := conn.InsertContext(ctx, &p)
+	result, err := conn.ExecContext(ctx, "insert into t_admin(username, password, phone, role_type, ctime, mtime) values (nullif(?, ''), ?, nullif(?, ''), ?, ?, ?)",
+		p.Username, p.Password, p.Phone, p.RoleType, p.CTime, p.MTime)
 	if err != nil {
 		return
 	}
@@ -57,7 +78,24 @@ func QueryByUsername(ctx context.Context, username string) (p Profile, err error
 	}
 
 	conn := sqlx.Get(ctx, "pension")
-	err = conn.GetContext(ctx, &p, "select * from t_admin where username=?", username)
+	err = conn.GetContext(ctx, &p, "select "+columns+" from t_admin where username=?", username)
+
+	// 如果没查询到，则id为0
+	if sqlx.IsNoRowErr(err) {
+		err = nil
+	}
+
+	return
+}
+
+// QueryByPhone 根据手机号查询
+func QueryByPhone(ctx context.Context, phone string) (p Profile, err error) {
+	if phone == "" {
+		return
+	}
+
+	conn := sqlx.Get(ctx, "pension")
+	err = conn.GetContext(ctx, &p, "select "+columns+" from t_admin where phone=?", phone)
 
 	// 如果没查询到，则id为0
 	if sqlx.IsNoRowErr(err) {
@@ -74,7 +112,7 @@ func QueryByUID(ctx context.Context, uid int64) (p Profile, err error) {
 	}
 
 	conn := sqlx.Get(ctx, "pension")
-	err = conn.GetContext(ctx, &p, "select * from t_admin where id=?", uid)
+	err = conn.GetContext(ctx, &p, "select "+columns+" from t_admin where id=?", uid)
 
 	// 如果没查询到，则id为0
 	if sqlx.IsNoRowErr(err) {
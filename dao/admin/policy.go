@@ -0,0 +1,17 @@
+package admin
+
+import (
+	"context"
+
+	"nautilus/pkg/rbac"
+	"nautilus/pkg/sqlx"
+)
+
+// QueryPolicies 查询所有角色的权限，作为 rbac.Loader 使用
+//
+//	e := rbac.NewEnforcer(rbac.FromConf(), admin.QueryPolicies)
+func QueryPolicies(ctx context.Context) (ps []rbac.Policy, err error) {
+	conn := sqlx.Get(ctx, "pension")
+	err = conn.SelectContext(ctx, &ps, "select role, permission from t_role_permission")
+	return
+}
@@ -0,0 +1,44 @@
+-- pension 管理员相关表结构，DB 配置为 DB_PENSION_DSN
+
+CREATE TABLE IF NOT EXISTS `t_admin` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `username` varchar(32) DEFAULT NULL COMMENT '用户名，只通过手机号登录的超级管理员为 NULL',
+  `password` varchar(64) NOT NULL DEFAULT '' COMMENT 'bcrypt 哈希后的密码',
+  `phone` varchar(11) DEFAULT NULL COMMENT '手机号，超级管理员必须通过手机号登录，没有手机号时为 NULL',
+  `role_type` tinyint NOT NULL DEFAULT 0 COMMENT '0: 普通管理员  1: 超级管理员',
+  `ctime` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `mtime` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '修改时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_username` (`username`),
+  UNIQUE KEY `uk_phone` (`phone`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='管理员';
+
+-- 已有的 t_admin 表升级，空字符串改为 NULL，唯一索引允许多个 NULL
+-- UPDATE `t_admin` SET `username` = NULL WHERE `username` = '';
+-- UPDATE `t_admin` SET `phone` = NULL WHERE `phone` = '';
+-- ALTER TABLE `t_admin`
+--   MODIFY `username` varchar(32) DEFAULT NULL COMMENT '用户名，只通过手机号登录的超级管理员为 NULL',
+--   MODIFY `phone` varchar(11) DEFAULT NULL COMMENT '手机号，超级管理员必须通过手机号登录，没有手机号时为 NULL',
+--   DROP KEY `idx_phone`,
+--   ADD UNIQUE KEY `uk_phone` (`phone`);
+
+CREATE TABLE IF NOT EXISTS `t_token` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `uid` bigint NOT NULL DEFAULT 0 COMMENT '管理员 id',
+  `key` varchar(64) NOT NULL DEFAULT '' COMMENT '会话 id，即 token 中的 jti',
+  `ctime` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `mtime` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '修改时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_key` (`key`),
+  KEY `idx_uid` (`uid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='登录会话';
+
+CREATE TABLE IF NOT EXISTS `t_role_permission` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `role` varchar(32) NOT NULL DEFAULT '' COMMENT '角色: super/admin',
+  `permission` varchar(64) NOT NULL DEFAULT '' COMMENT '权限，支持 * 和 admin:* 前缀匹配',
+  `ctime` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `mtime` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '修改时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_role_permission` (`role`, `permission`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='角色权限';
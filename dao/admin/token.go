@@ -1,9 +1,14 @@
 package admin
 
 import (
+	"context"
 	"time"
+
+	"nautilus/pkg/sqlx"
 )
 
+// Token 登录会话，一次登录对应一条记录
+// Key 为会话 id，即 token 中的 jti，refresh token 刷新时更换，退出登录时删除
 type Token struct {
 	ID    int64     `db:"id"`
 	UID   int64     `db:"uid"`
@@ -21,3 +26,65 @@ func (t Token) TableName() string {
 func (t Token) KeyName() string {
 	return "id"
 }
+
+// CreateToken 创建登录会话
+// key 是 mysql 关键字，需要手写 sql 加上反引号
+func CreateToken(ctx context.Context, t Token) (id int64, err error) {
+	conn := sqlx.Get(ctx, "pension")
+
+	now := time.Now()
+	if t.CTime.IsZero() {
+		t.CTime = now
+	}
+
+	if t.MTime.IsZero() {
+		t.MTime = now
+	}
+
+	result, err := conn.ExecContext(ctx, "insert into t_token(uid,`key`,ctime,mtime) values (?,?,?,?)",
+		t.UID, t.Key, t.CTime, t.MTime)
+	if err != nil {
+		return
+	}
+
+	id, err = result.LastInsertId()
+	return
+}
+
+// QueryTokenByKey 根据会话 id 查询
+func QueryTokenByKey(ctx context.Context, key string) (t Token, err error) {
+	if key == "" {
+		return
+	}
+
+	conn := sqlx.Get(ctx, "pension")
+	err = conn.GetContext(ctx, &t, "select * from t_token where `key`=?", key)
+
+	// 如果没查询到，则id为0
+	if sqlx.IsNoRowErr(err) {
+		err = nil
+	}
+
+	return
+}
+
+// ReplaceTokenKey 刷新 token 时更换会话 id
+// 只有 old 仍然有效时才会更新，并发刷新或者重放旧的 refresh token 时 ok 为 false
+func ReplaceTokenKey(ctx context.Context, old, key string) (ok bool, err error) {
+	conn := sqlx.Get(ctx, "pension")
+	result, err := conn.ExecContext(ctx, "update t_token set `key`=?, mtime=? where `key`=?", key, time.Now(), old)
+	if err != nil {
+		return
+	}
+
+	n, err := result.RowsAffected()
+	ok = n > 0
+	return
+}
+
+// DeleteTokenByKey 删除登录会话
+func DeleteTokenByKey(ctx context.Context, key string) (err error) {
+	conn := sqlx.Get(ctx, "pension")
+	_, err = conn.ExecContext(ctx, "delete from t_token where `key`=?", key)
+	return
+}
@@ -0,0 +1,195 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"nautilus/pkg/conf"
+	"nautilus/pkg/health"
+	"nautilus/pkg/log"
+	"nautilus/pkg/trace"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Server 管理端口，和业务端口分开监听，只在内网开放
+//
+//	/metrics         prometheus 指标
+//	/healthz         存活检查，参考 health.LiveHandler
+//	/readyz          就绪检查，参考 health.ReadyHandler
+//	/buildinfo       版本和编译信息
+//	/config          配置，敏感配置脱敏，需要 token
+//	/debug/pprof/*   pprof，需要 token
+//	/debug/log       查看和修改日志等级，参考 log.Handler，需要 token
+//	/debug/trace     查看和修改 trace 采样比例，参考 trace.Handler，需要 token
+//
+// 配置了 token 时，需要 token 的接口要带上 Authorization: Bearer ${token}
+// 没有 token 时除开发环境外只能监听 loopback 地址
+type Server struct {
+	addr  string
+	token string
+	mux   *http.ServeMux
+	srv   *http.Server
+}
+
+// New 创建管理端口，addr 为监听地址，例如 127.0.0.1:9095；token 为空时不校验
+func New(addr string, token string) *Server {
+	s := &Server{
+		addr:  addr,
+		token: token,
+		mux:   http.NewServeMux(),
+	}
+
+	s.Handle("/metrics", promhttp.Handler(), false)
+	s.Handle("/healthz", health.LiveHandler(), false)
+	s.Handle("/readyz", health.ReadyHandler(), false)
+	s.Handle("/buildinfo", http.HandlerFunc(buildInfo), false)
+	s.Handle("/config", http.HandlerFunc(dumpConfig), true)
+
+	s.Handle("/debug/pprof/", http.HandlerFunc(pprof.Index), true)
+	s.Handle("/debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline), true)
+	s.Handle("/debug/pprof/profile", http.HandlerFunc(pprof.Profile), true)
+	s.Handle("/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol), true)
+	s.Handle("/debug/pprof/trace", http.HandlerFunc(pprof.Trace), true)
+
+	s.Handle("/debug/log", log.Handler(), true)
+	s.Handle("/debug/trace", trace.Handler(), true)
+
+	s.srv = &http.Server{
+		Addr:              addr,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	return s
+}
+
+// FromConf 从配置中创建管理端口
+//
+//	ADMIN_HOST   监听地址，默认 127.0.0.1，只允许本机访问
+//	ADMIN_PORT   监听端口，默认 9095
+//	ADMIN_TOKEN  需要 token 的接口的 token，为空时不校验
+func FromConf() *Server {
+	host := conf.Get("ADMIN_HOST")
+	if host == "" {
+		host = "127.0.0.1"
+	}
+
+	port := conf.GetInt64("ADMIN_PORT")
+	if port == 0 {
+		port = 9095
+	}
+
+	return New(net.JoinHostPort(host, strconv.FormatInt(port, 10)), conf.Get("ADMIN_TOKEN"))
+}
+
+// Handle 注册接口，protected 为 true 时需要 token，需要在 Start 之前调用
+func (s *Server) Handle(pattern string, h http.Handler, protected bool) {
+	if protected {
+		h = s.auth(h)
+	}
+
+	s.mux.Handle(pattern, h)
+}
+
+// Start 在后台监听，端口被占用等错误直接返回
+// 非开发环境没有配置 token 时不能监听 loopback 以外的地址，避免 pprof 和运行时配置对外开放
+func (s *Server) Start() error {
+	if s.token == "" && !conf.IsDevEnv && !loopback(s.addr) {
+		return fmt.Errorf("admin: ADMIN_TOKEN is required to listen on %s", s.addr)
+	}
+
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Get(context.Background()).WithError(err).Error("admin server stopped")
+		}
+	}()
+
+	return nil
+}
+
+// Shutdown 停止监听，等待正在处理的请求完成
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+// loopback 监听地址是否只允许本机访问，:9095 这种监听所有网卡的地址不是
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ServeHTTP 方便测试
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// auth 校验 token
+func (s *Server) auth(h http.Handler) http.Handler {
+	if s.token == "" {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// buildInfo 版本和编译信息，版本号和 git 信息编译时注入到 conf 中
+func buildInfo(w http.ResponseWriter, r *http.Request) {
+	info := map[string]string{
+		"app_id":     conf.AppID,
+		"env":        conf.Env,
+		"version":    conf.Version,
+		"commit":     conf.Commit,
+		"branch":     conf.Branch,
+		"build_time": conf.BuildTime,
+		"go_version": runtime.Version(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info["module"] = bi.Main.Path
+		info["module_version"] = bi.Main.Version
+	}
+
+	writeJSON(w, http.StatusOK, info)
+}
+
+// dumpConfig 所有配置，敏感配置脱敏
+func dumpConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, conf.Dump())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,104 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"nautilus/pkg/conf"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	s := New(":0", "secret")
+
+	for _, path := range []string{"/metrics", "/healthz", "/readyz", "/buildinfo"} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+
+	// 需要 token
+	for _, path := range []string{"/config", "/debug/pprof/", "/debug/log", "/debug/trace"} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code, path)
+
+		w = httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Authorization", "Bearer secret")
+		s.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+
+	// 没有 token 时不校验
+	w := httptest.NewRecorder()
+	New(":0", "").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/config", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestBuildInfo(t *testing.T) {
+	conf.Version, conf.Commit = "v1.2.3", "abc"
+	defer func() { conf.Version, conf.Commit = "dev", "" }()
+
+	w := httptest.NewRecorder()
+	New(":0", "").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/buildinfo", nil))
+
+	var info map[string]string
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &info))
+	assert.Equal(t, "v1.2.3", info["version"])
+	assert.Equal(t, "abc", info["commit"])
+	assert.NotEmpty(t, info["go_version"])
+}
+
+func TestConfig(t *testing.T) {
+	os.Setenv("JWT_KEYS", "k1")
+	defer os.Unsetenv("JWT_KEYS")
+
+	w := httptest.NewRecorder()
+	New(":0", "").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/config", nil))
+
+	var m map[string]string
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &m))
+	assert.Equal(t, "***", m["db_pension_dsn"])
+	assert.Equal(t, "k1", m["jwt_keys"])
+}
+
+func TestStart(t *testing.T) {
+	s := New("127.0.0.1:0", "")
+	assert.Nil(t, s.Start())
+	assert.Nil(t, s.Shutdown(context.Background()))
+
+	// 端口被占用
+	ln := httptest.NewServer(http.NotFoundHandler())
+	defer ln.Close()
+	assert.NotNil(t, New(ln.Listener.Addr().String(), "").Start())
+}
+
+func TestStart_Token(t *testing.T) {
+	dev := conf.IsDevEnv
+	conf.IsDevEnv = false
+	defer func() { conf.IsDevEnv = dev }()
+
+	// 非开发环境没有 token 时只能监听本机地址
+	assert.NotNil(t, New(":0", "").Start())
+	assert.NotNil(t, New("0.0.0.0:0", "").Start())
+
+	for _, addr := range []string{"127.0.0.1:0", "localhost:0"} {
+		s := New(addr, "")
+		assert.Nil(t, s.Start(), addr)
+		assert.Nil(t, s.Shutdown(context.Background()))
+	}
+
+	s := New(":0", "secret")
+	assert.Nil(t, s.Start())
+	assert.Nil(t, s.Shutdown(context.Background()))
+}
+
+func TestFromConf(t *testing.T) {
+	assert.Equal(t, "127.0.0.1:9095", FromConf().addr)
+}
@@ -0,0 +1,54 @@
+package conf
+
+import (
+	"strings"
+)
+
+// 编译时通过 -ldflags 注入，参考 Makefile 中的 build
+//
+//	go build -ldflags "-X nautilus/pkg/conf.Version=v1.0.0 -X nautilus/pkg/conf.Commit=$(git rev-parse HEAD)"
+var (
+	// Version 版本号
+	Version = "dev"
+	// Commit git commit
+	Commit = ""
+	// Branch git 分支
+	Branch = ""
+	// BuildTime 编译时间
+	BuildTime = ""
+)
+
+// redacted 脱敏后的值
+const redacted = "***"
+
+// sensitive 配置名中包含这些词时脱敏，例如 TOKEN_SECRET、DB_PENSION_DSN
+// HEADERS/AUTH 一般带有鉴权信息，例如 OTEL_AGENT_HEADERS = "authorization=Bearer ..."
+var sensitive = []string{"SECRET", "PASSWORD", "PASSWD", "TOKEN", "DSN", "PRIVATE", "CREDENTIAL", "HEADERS", "AUTH"}
+
+// Dump 所有配置，环境变量覆写的值也会生效，敏感配置不为空时替换为 ***
+// 只包含配置文件中存在的配置，只通过环境变量设置的配置不会出现
+func Dump() map[string]string {
+	keys := v.AllKeys()
+	m := make(map[string]string, len(keys))
+	for _, key := range keys {
+		value := Get(key)
+		if value != "" && IsSensitive(key) {
+			value = redacted
+		}
+		m[key] = value
+	}
+
+	return m
+}
+
+// IsSensitive 是否为敏感配置
+func IsSensitive(key string) bool {
+	key = strings.ToUpper(key)
+	for _, s := range sensitive {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,34 @@
+package conf
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDump(t *testing.T) {
+	os.Setenv("ADMIN_PORT", "9191")
+	defer os.Unsetenv("ADMIN_PORT")
+
+	m := Dump()
+	assert.Equal(t, "***", m["db_pension_dsn"])
+	// 环境变量覆写的值
+	assert.Equal(t, "9191", m["admin_port"])
+	// 空的敏感配置不脱敏
+	assert.Equal(t, "", m["admin_token"])
+
+	// otlp 请求头中带有 collector 的鉴权信息
+	os.Setenv("OTEL_AGENT_HEADERS", "authorization=Bearer x")
+	defer os.Unsetenv("OTEL_AGENT_HEADERS")
+	assert.Equal(t, "***", Dump()["otel_agent_headers"])
+}
+
+func TestIsSensitive(t *testing.T) {
+	for _, key := range []string{"TOKEN_SECRET", "db_pension_dsn", "JWT_KEY_K1_PRIVATE", "REDIS_PASSWORD", "OTEL_AGENT_HEADERS", "HTTP_AUTHORIZATION"} {
+		assert.True(t, IsSensitive(key), key)
+	}
+	for _, key := range []string{"APP_ID", "JWT_KEYS", "RATELIMIT_POLICY_LOGIN_KEY"} {
+		assert.False(t, IsSensitive(key), key)
+	}
+}
@@ -26,10 +26,17 @@ const (
 	AppkeyKey
 	// DeviceKey 浏览器型号
 	DeviceKey
-	// TSKey 时间戳
+	// TSKey 时间戳，单位秒，类型：string
 	TSKey
 	// SignKey 签名
 	SignKey
+
+	// UIDKey 登录用户 id，类型：int64
+	UIDKey
+	// TokenIDKey 登录会话 id，即 token 中的 jti，类型：string
+	TokenIDKey
+	// RoleKey 登录用户角色，类型：string
+	RoleKey
 )
 
 // GetTraceID 获取 trace id
@@ -49,20 +56,117 @@ func GetAccessIP(ctx context.Context) string {
 	return v
 }
 
+// WithAccessIP 向ctx中注入请求 ip
+func WithAccessIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, AccessIPKey, ip)
+}
+
 // GetPlatform 获取平台信息
 func GetPlatform(ctx context.Context) string {
 	v, _ := ctx.Value(PlatformKey).(string)
 	return v
 }
 
+// WithPlatform 向ctx中注入平台信息
+func WithPlatform(ctx context.Context, platform string) context.Context {
+	return context.WithValue(ctx, PlatformKey, platform)
+}
+
+// GetVersion 获取版本信息
+func GetVersion(ctx context.Context) string {
+	v, _ := ctx.Value(VersionKey).(string)
+	return v
+}
+
+// WithVersion 向ctx中注入版本信息
+func WithVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, VersionKey, version)
+}
+
+// GetAccessKey 获取登录token
+func GetAccessKey(ctx context.Context) string {
+	v, _ := ctx.Value(AccessKeyKey).(string)
+	return v
+}
+
+// WithAccessKey 向ctx中注入登录token
+func WithAccessKey(ctx context.Context, accessKey string) context.Context {
+	return context.WithValue(ctx, AccessKeyKey, accessKey)
+}
+
 // GetAppkey 获取 app key
 func GetAppkey(ctx context.Context) string {
 	v, _ := ctx.Value(AppkeyKey).(string)
 	return v
 }
 
+// WithAppkey 向ctx中注入 app key
+func WithAppkey(ctx context.Context, appkey string) context.Context {
+	return context.WithValue(ctx, AppkeyKey, appkey)
+}
+
 // GetDevice 获取浏览器型号
 func GetDevice(ctx context.Context) string {
 	v, _ := ctx.Value(DeviceKey).(string)
 	return v
 }
+
+// WithDevice 向ctx中注入浏览器型号
+func WithDevice(ctx context.Context, device string) context.Context {
+	return context.WithValue(ctx, DeviceKey, device)
+}
+
+// GetTS 获取请求时间戳
+func GetTS(ctx context.Context) string {
+	v, _ := ctx.Value(TSKey).(string)
+	return v
+}
+
+// WithTS 向ctx中注入请求时间戳
+func WithTS(ctx context.Context, ts string) context.Context {
+	return context.WithValue(ctx, TSKey, ts)
+}
+
+// GetSign 获取请求签名
+func GetSign(ctx context.Context) string {
+	v, _ := ctx.Value(SignKey).(string)
+	return v
+}
+
+// WithSign 向ctx中注入请求签名
+func WithSign(ctx context.Context, sign string) context.Context {
+	return context.WithValue(ctx, SignKey, sign)
+}
+
+// GetUID 获取登录用户 id，未登录时为 0
+func GetUID(ctx context.Context) int64 {
+	v, _ := ctx.Value(UIDKey).(int64)
+	return v
+}
+
+// WithUID 向ctx中注入登录用户 id
+func WithUID(ctx context.Context, uid int64) context.Context {
+	return context.WithValue(ctx, UIDKey, uid)
+}
+
+// GetTokenID 获取登录会话 id
+func GetTokenID(ctx context.Context) string {
+	v, _ := ctx.Value(TokenIDKey).(string)
+	return v
+}
+
+// WithTokenID 向ctx中注入登录会话 id
+func WithTokenID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, TokenIDKey, id)
+}
+
+// GetRole 获取登录用户角色
+func GetRole(ctx context.Context) string {
+	v, _ := ctx.Value(RoleKey).(string)
+	return v
+}
+
+// WithRole 向ctx中注入登录用户角色
+func WithRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, RoleKey, role)
+}
@@ -0,0 +1,187 @@
+package deadline
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"nautilus/api/timeout"
+	"nautilus/pkg/conf"
+	"nautilus/pkg/httprule"
+	"nautilus/pkg/log"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// 上游传递超时时间的请求头
+const (
+	// HeaderGRPCTimeout grpc 协议的超时时间，格式为数字加单位 H/M/S/m/u/n，例如 100m
+	HeaderGRPCTimeout = "Grpc-Timeout"
+	// HeaderRequestTimeout HTTP 调用方的超时时间，格式和 time.ParseDuration 一致，纯数字时单位为毫秒
+	HeaderRequestTimeout = "X-Request-Timeout"
+)
+
+// FromHeader 从请求头中解析上游剩余的超时时间，grpc-timeout 优先
+func FromHeader(h http.Header) (time.Duration, bool) {
+	if v := h.Get(HeaderGRPCTimeout); v != "" {
+		if d, ok := ParseGRPCTimeout(v); ok {
+			return d, true
+		}
+	}
+
+	v := strings.TrimSpace(h.Get(HeaderRequestTimeout))
+	if v == "" {
+		return 0, false
+	}
+
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Duration(ms) * time.Millisecond, ms > 0
+	}
+
+	d, err := time.ParseDuration(v)
+	return d, err == nil && d > 0
+}
+
+// ParseGRPCTimeout 解析 grpc-timeout，格式参考
+// https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md
+func ParseGRPCTimeout(s string) (time.Duration, bool) {
+	if len(s) < 2 || len(s) > 9 {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+
+	var unit time.Duration
+	switch s[len(s)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, false
+	}
+
+	return time.Duration(n) * unit, true
+}
+
+// methods grpc full method 对应的超时时间缓存，没有声明时为 -1
+var methods sync.Map
+
+// MethodTimeout 返回 grpc 方法在 proto 中声明的超时时间
+// fullMethod 格式为 /package.Service/Method
+func MethodTimeout(fullMethod string) (time.Duration, bool) {
+	if v, ok := methods.Load(fullMethod); ok {
+		d := v.(time.Duration)
+		return d, d >= 0
+	}
+
+	d := time.Duration(-1)
+	if md := findMethod(fullMethod); md != nil {
+		if v, ok := optionTimeout(md); ok {
+			d = v
+		}
+	}
+
+	methods.Store(fullMethod, d)
+	return d, d >= 0
+}
+
+// Routes 返回 HTTP 接口的超时时间，key 为 gin 路由，例如 /v1/author/:author_id/articles
+// proto 中通过 (nautilus.timeout.timeout) 声明，TIMEOUT_ROUTES 可以覆盖，格式为 ${route}=${timeout}，逗号分隔
+//
+//	TIMEOUT_ROUTES = "/api/v0/report/export=30s,/api/v0/events=0"
+func Routes() map[string]time.Duration {
+	routes := map[string]time.Duration{}
+
+	protoregistry.GlobalFiles.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		services := fd.Services()
+		for i := 0; i < services.Len(); i++ {
+			ms := services.Get(i).Methods()
+			for j := 0; j < ms.Len(); j++ {
+				addRoutes(routes, ms.Get(j))
+			}
+		}
+		return true
+	})
+
+	for _, v := range conf.GetStrings("TIMEOUT_ROUTES") {
+		kv := strings.SplitN(strings.TrimSpace(v), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		d, err := time.ParseDuration(strings.TrimSpace(kv[1]))
+		if err != nil {
+			log.Get(context.Background()).Warnf("invalid route timeout %q: %v", v, err)
+			continue
+		}
+		routes[strings.TrimSpace(kv[0])] = d
+	}
+
+	return routes
+}
+
+// addRoutes 把方法声明的超时时间加到所有 google.api.http 路由上
+func addRoutes(routes map[string]time.Duration, md protoreflect.MethodDescriptor) {
+	d, ok := optionTimeout(md)
+	if !ok {
+		return
+	}
+
+	for _, r := range httprule.Rules(md) {
+		if _, path := httprule.Pattern(r); path != "" {
+			routes[httprule.GinPath(path)] = d
+		}
+	}
+}
+
+func optionTimeout(md protoreflect.MethodDescriptor) (time.Duration, bool) {
+	v, _ := proto.GetExtension(md.Options(), timeout.E_Timeout).(string)
+	if v == "" {
+		return 0, false
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Get(context.Background()).Warnf("invalid timeout %q of %s: %v", v, md.FullName(), err)
+		return 0, false
+	}
+
+	return d, true
+}
+
+func findMethod(fullMethod string) protoreflect.MethodDescriptor {
+	name := strings.TrimPrefix(fullMethod, "/")
+	i := strings.LastIndex(name, "/")
+	if i < 0 {
+		return nil
+	}
+
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name[:i]))
+	if err != nil {
+		return nil
+	}
+
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil
+	}
+
+	return sd.Methods().ByName(protoreflect.Name(name[i+1:]))
+}
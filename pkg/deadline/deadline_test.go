@@ -0,0 +1,84 @@
+package deadline
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	_ "nautilus/api/pension/v0"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGRPCTimeout(t *testing.T) {
+	cases := map[string]time.Duration{
+		"1H":   time.Hour,
+		"2M":   2 * time.Minute,
+		"3S":   3 * time.Second,
+		"100m": 100 * time.Millisecond,
+		"5u":   5 * time.Microsecond,
+		"7n":   7 * time.Nanosecond,
+	}
+	for s, want := range cases {
+		d, ok := ParseGRPCTimeout(s)
+		assert.True(t, ok, s)
+		assert.Equal(t, want, d, s)
+	}
+
+	for _, s := range []string{"", "m", "10", "10x", "-1S", "0S", "123456789S"} {
+		_, ok := ParseGRPCTimeout(s)
+		assert.False(t, ok, s)
+	}
+}
+
+func TestFromHeader(t *testing.T) {
+	h := http.Header{}
+	_, ok := FromHeader(h)
+	assert.False(t, ok)
+
+	h.Set(HeaderRequestTimeout, "1500")
+	d, ok := FromHeader(h)
+	assert.True(t, ok)
+	assert.Equal(t, 1500*time.Millisecond, d)
+
+	h.Set(HeaderRequestTimeout, "2s")
+	d, _ = FromHeader(h)
+	assert.Equal(t, 2*time.Second, d)
+
+	h.Set(HeaderGRPCTimeout, "100m")
+	d, _ = FromHeader(h)
+	assert.Equal(t, 100*time.Millisecond, d)
+
+	h = http.Header{}
+	h.Set(HeaderRequestTimeout, "-1s")
+	_, ok = FromHeader(h)
+	assert.False(t, ok)
+}
+
+func TestRoutes(t *testing.T) {
+	os.Setenv("TIMEOUT_ROUTES", "/api/v0/export=30s, /api/v0/events=0,/bad=x")
+	defer os.Unsetenv("TIMEOUT_ROUTES")
+
+	routes := Routes()
+	assert.Equal(t, 30*time.Second, routes["/api/v0/export"])
+	d, ok := routes["/api/v0/events"]
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), d)
+	assert.NotContains(t, routes, "/bad")
+
+	// proto 中声明的超时时间
+	assert.Equal(t, 3*time.Second, routes["/api/v0/admin/code"])
+	assert.NotContains(t, routes, "/api/v0/admin/login")
+}
+
+func TestMethodTimeout(t *testing.T) {
+	d, ok := MethodTimeout("/AdminService/SendCode")
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, d)
+
+	_, ok = MethodTimeout("/nautilus.unknown.Service/Method")
+	assert.False(t, ok)
+	_, ok = MethodTimeout("invalid")
+	assert.False(t, ok)
+}
@@ -3,7 +3,10 @@ package errors
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+
+	"google.golang.org/protobuf/proto"
 )
 
 type Type string
@@ -12,12 +15,14 @@ const (
 	Authorization        Type = "AUTHORIZATION"
 	BadRequest           Type = "BAD_REQUEST"
 	Conflict             Type = "CONFLICT"
+	Forbidden            Type = "FORBIDDEN"
 	Internal             Type = "INTERNAL"
 	NotFound             Type = "NOT_FOUND"
 	PayloadTooLarge      Type = "PAYLOAD_TOO_LARGE"
 	ServiceUnavailable   Type = "SERVICE_UNAVAILABLE"
 	UnsupportedMediaType Type = "UNSUPPORTED_MEDIA_TYPE"
 	RequestTimeout       Type = "REQUEST_TIMEOUT"
+	TooManyRequests      Type = "TOO_MANY_REQUESTS"
 )
 
 // Error holds a custom error for the application
@@ -25,16 +30,146 @@ const (
 // error type/message from API endpoints
 type Error struct {
 	Type    Type   `json:"type"`
+	BizCode int    `json:"code"`
 	Message string `json:"message"`
+
+	// Reason 机器可读的错误标识，grpc 中通过 google.rpc.ErrorInfo.reason 传递
+	Reason string `json:"reason,omitempty"`
+	// Metadata 错误的附加信息，grpc 中通过 google.rpc.ErrorInfo.metadata 传递
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Details 额外的 google.rpc 错误详情，例如 *errdetails.BadRequest
+	Details []proto.Message `json:"-"`
+
+	cause error
+	stack *stack
 }
 
 // Error satisfies standard error interface
 // we can return errors from this package as
 // a regular old go _error_
 func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Message + ": " + e.cause.Error()
+	}
+
+	return e.Message
+}
+
+// Unwrap 返回底层错误，标准库 errors.Is/errors.As 可以沿着错误链查找
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is 判断是否匹配预定义的错误，target 需要是指定了业务错误码或者 reason 的 *Error，
+// 经过 WithCause/WithMetadata 复制之后仍然可以匹配；
+// 两者都没有指定的 target 只匹配自身，否则所有 NotFound 都会相互匹配
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok || (t.BizCode == 0 && t.Reason == "") {
+		return false
+	}
+
+	return e.Type == t.Type && e.BizCode == t.BizCode && e.Reason == t.Reason
+}
+
+// Format %s/%v 输出错误信息，%+v 同时输出错误链和调用栈（只有 debug 编译时才有调用栈）
+func (e *Error) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			io.WriteString(s, e.Message)
+			if e.stack != nil {
+				io.WriteString(s, e.stack.String())
+			}
+			if e.cause != nil {
+				fmt.Fprintf(s, "\ncaused by: %+v", e.cause)
+			}
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	}
+}
+
+// Stack 返回创建错误时的调用栈，只有使用 `-tags debug` 编译时才不为空
+func (e *Error) Stack() string {
+	if e.stack == nil {
+		return ""
+	}
+
+	return e.stack.String()
+}
+
+// HTTPCode 返回 http 状态码
+// HTTPCode/Code/Msg 三个方法供生成的 gin 代码识别业务错误
+func (e *Error) HTTPCode() int {
+	return e.Status()
+}
+
+// Code 返回业务错误码，未指定时和 http 状态码一致
+func (e *Error) Code() int {
+	if e.BizCode == 0 {
+		return e.Status()
+	}
+
+	return e.BizCode
+}
+
+// Msg 返回错误信息
+func (e *Error) Msg() string {
 	return e.Message
 }
 
+// WithCode 返回一个指定业务错误码的副本
+//
+//	var ErrUserNotFound = errors.NewNotFound("user", "").WithCode(10001)
+func (e *Error) WithCode(code int) *Error {
+	c := e.clone()
+	c.BizCode = code
+	return c
+}
+
+// WithReason 返回一个指定 reason 的副本
+func (e *Error) WithReason(reason string) *Error {
+	c := e.clone()
+	c.Reason = reason
+	return c
+}
+
+// WithCause 返回一个包装底层错误的副本
+func (e *Error) WithCause(err error) *Error {
+	c := e.clone()
+	c.cause = err
+	return c
+}
+
+// WithMetadata 返回一个追加了 metadata 的副本
+func (e *Error) WithMetadata(key, value string) *Error {
+	c := e.clone()
+	c.Metadata = make(map[string]string, len(e.Metadata)+1)
+	for k, v := range e.Metadata {
+		c.Metadata[k] = v
+	}
+	c.Metadata[key] = value
+	return c
+}
+
+// WithDetails 返回一个追加了 google.rpc 错误详情的副本
+func (e *Error) WithDetails(details ...proto.Message) *Error {
+	c := e.clone()
+	c.Details = append(append([]proto.Message{}, e.Details...), details...)
+	return c
+}
+
+// clone 浅拷贝，slice 和 map 由 With* 方法复制
+func (e *Error) clone() *Error {
+	c := *e
+	return &c
+}
+
 // Status is a mapping errors to status codes
 // Of course, this is somewhat redundant since
 // our errors already map http status codes
@@ -46,6 +181,8 @@ func (e *Error) Status() int {
 		return http.StatusBadRequest
 	case Conflict:
 		return http.StatusConflict
+	case Forbidden:
+		return http.StatusForbidden
 	case Internal:
 		return http.StatusInternalServerError
 	case NotFound:
@@ -58,6 +195,8 @@ func (e *Error) Status() int {
 		return http.StatusUnsupportedMediaType
 	case RequestTimeout:
 		return http.StatusRequestTimeout
+	case TooManyRequests:
+		return http.StatusTooManyRequests
 	default:
 		return http.StatusInternalServerError
 	}
@@ -69,81 +208,87 @@ func (e *Error) Status() int {
 func Status(err error) int {
 	var e *Error
 
-	if errors.As(err, e) {
+	if errors.As(err, &e) {
 		return e.Status()
 	}
 
 	return http.StatusInternalServerError
 }
 
+// New 创建一个业务错误
+// t 决定 http 状态码，code 为业务错误码
+func New(t Type, code int, msg string) *Error {
+	return newError(t, code, msg)
+}
+
+// Wrap 创建一个包装底层错误的业务错误，t 决定 http 状态码
+func Wrap(err error, t Type, msg string) *Error {
+	e := newError(t, 0, msg)
+	e.cause = err
+	return e
+}
+
 // NewAuthorization to create a 401
 func NewAuthorization(reason string) *Error {
-	return &Error{
-		Type:    Authorization,
-		Message: reason,
-	}
+	return newError(Authorization, 0, reason)
 }
 
 // NewBadRequest to create 400 errors (validation, for example)
 func NewBadRequest(reason string) *Error {
-	return &Error{
-		Type:    BadRequest,
-		Message: reason,
-	}
+	return newError(BadRequest, 0, reason)
 }
 
 // NewConflict to create an error for 409
 func NewConflict(name string, value string) *Error {
-	return &Error{
-		Type:    Conflict,
-		Message: fmt.Sprintf("resource: %v with value: %v already exists", name, value),
-	}
+	return newError(Conflict, 0, fmt.Sprintf("resource: %v with value: %v already exists", name, value))
+}
+
+// NewForbidden to create a 403, the caller is authenticated but has no permission
+func NewForbidden(reason string) *Error {
+	return newError(Forbidden, 0, reason)
 }
 
 // NewInternal for 500 errors and unknown errors
 func NewInternal() *Error {
-	return &Error{
-		Type:    Internal,
-		Message: "Internal server error.",
-	}
+	return newError(Internal, 0, "Internal server error.")
 }
 
 // NewNotFound to create an error for 404
 func NewNotFound(name string, value string) *Error {
-	return &Error{
-		Type:    NotFound,
-		Message: fmt.Sprintf("resource: %v with value: %v not found", name, value),
-	}
+	return newError(NotFound, 0, fmt.Sprintf("resource: %v with value: %v not found", name, value))
 }
 
 // NewPayloadTooLarge to create an error for 413
 func NewPayloadTooLarge(max int64, contentLength int64) *Error {
-	return &Error{
-		Type:    PayloadTooLarge,
-		Message: fmt.Sprintf("Max payload size of %v exceeded. Actual payload size: %v", max, contentLength),
-	}
+	return newError(PayloadTooLarge, 0, fmt.Sprintf("Max payload size of %v exceeded. Actual payload size: %v", max, contentLength))
 }
 
 // NewServiceUnavailable to create an error for 503
 func NewServiceUnavailable() *Error {
-	return &Error{
-		Type:    ServiceUnavailable,
-		Message: "Service unavailable or timeout",
-	}
+	return newError(ServiceUnavailable, 0, "Service unavailable or timeout")
 }
 
 // NewUnsupportedMediaType to create an error for 415
 func NewUnsupportedMediaType(reason string) *Error {
-	return &Error{
-		Type:    UnsupportedMediaType,
-		Message: reason,
-	}
+	return newError(UnsupportedMediaType, 0, reason)
 }
 
 // NewRequestTimeout to create an error for 408
 func NewRequestTimeout(reason string) *Error {
+	return newError(RequestTimeout, 0, reason)
+}
+
+// NewTooManyRequests to create an error for 429
+func NewTooManyRequests(reason string) *Error {
+	return newError(TooManyRequests, 0, reason)
+}
+
+// newError 只能由导出的构造函数直接调用，调用栈从构造函数的调用方开始记录
+func newError(t Type, code int, msg string) *Error {
 	return &Error{
-		Type:    RequestTimeout,
-		Message: reason,
+		Type:    t,
+		BizCode: code,
+		Message: msg,
+		stack:   callers(),
 	}
 }
@@ -0,0 +1,79 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatus(t *testing.T) {
+	err := fmt.Errorf("query: %w", NewNotFound("user", "1"))
+	assert.Equal(t, http.StatusNotFound, Status(err))
+	assert.Equal(t, http.StatusInternalServerError, Status(io.EOF))
+}
+
+func TestWrap(t *testing.T) {
+	err := Wrap(io.EOF, Internal, "read body")
+	assert.True(t, errors.Is(err, io.EOF))
+	assert.Equal(t, "read body: EOF", err.Error())
+	assert.Equal(t, "read body", err.Msg())
+
+	errUserNotFound := NewNotFound("user", "").WithCode(10001)
+	assert.True(t, errors.Is(fmt.Errorf("svc: %w", errUserNotFound.WithCause(io.EOF)), errUserNotFound))
+	assert.False(t, errors.Is(NewNotFound("user", ""), errUserNotFound))
+	assert.False(t, errors.Is(NewNotFound("user", "").WithCode(10002), errUserNotFound))
+
+	// errors without a code or reason only match themselves
+	errBadRequest := NewBadRequest("a")
+	assert.False(t, errors.Is(NewBadRequest("b"), errBadRequest))
+	assert.True(t, errors.Is(fmt.Errorf("svc: %w", errBadRequest), errBadRequest))
+
+	errRevoked := NewAuthorization("token is revoked").WithReason("TOKEN_REVOKED")
+	assert.True(t, errors.Is(errRevoked.WithMetadata("uid", "1"), errRevoked))
+	assert.False(t, errors.Is(NewAuthorization("token is revoked"), errRevoked))
+}
+
+func TestGRPCStatus(t *testing.T) {
+	src := NewFieldViolations("invalid params", &errdetails.BadRequest_FieldViolation{
+		Field:       "page",
+		Description: "must be greater than 0",
+	}).WithCode(10002).WithReason("INVALID_PAGE").WithMetadata("page", "0")
+
+	s, ok := status.FromError(src)
+	assert.True(t, ok)
+	assert.Equal(t, codes.InvalidArgument, s.Code())
+	assert.Equal(t, "invalid params", s.Message())
+
+	dst := FromError(s.Err())
+	assert.Equal(t, BadRequest, dst.Type)
+	assert.Equal(t, 10002, dst.Code())
+	assert.Equal(t, "INVALID_PAGE", dst.Reason)
+	assert.Equal(t, map[string]string{"page": "0"}, dst.Metadata)
+	assert.Len(t, dst.FieldViolations(), 1)
+	assert.Equal(t, "page", dst.FieldViolations()[0].Field)
+}
+
+func TestSetDomain(t *testing.T) {
+	SetDomain("demo")
+	defer SetDomain("")
+
+	s, _ := status.FromError(NewNotFound("article", "1"))
+	info, ok := s.Details()[0].(*errdetails.ErrorInfo)
+	assert.True(t, ok)
+	assert.Equal(t, "demo", info.Domain)
+}
+
+func TestFromHTTPStatus(t *testing.T) {
+	assert.Equal(t, RequestTimeout, FromHTTPStatus(http.StatusGatewayTimeout, "").Type)
+	assert.Equal(t, TooManyRequests, FromHTTPStatus(http.StatusTooManyRequests, "").Type)
+	assert.Equal(t, BadRequest, FromHTTPStatus(http.StatusTeapot, "").Type)
+	assert.Equal(t, Internal, FromHTTPStatus(http.StatusNotImplemented, "").Type)
+	assert.Equal(t, http.StatusTeapot, FromHTTPStatus(http.StatusTeapot, "").Code())
+}
@@ -0,0 +1,26 @@
+package errors
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// stack 调用栈的 pc
+type stack []uintptr
+
+// String 按照 debug.Stack 的格式输出调用栈
+func (s *stack) String() string {
+	var buf strings.Builder
+
+	frames := runtime.CallersFrames(*s)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&buf, "\n%s\n\t%s:%d", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,17 @@
+//go:build debug
+// +build debug
+
+package errors
+
+import "runtime"
+
+// depth 最多记录的栈帧数
+const depth = 32
+
+// callers 记录调用栈，跳过 runtime.Callers、callers、newError 和导出的构造函数
+func callers() *stack {
+	var pcs [depth]uintptr
+	n := runtime.Callers(4, pcs[:])
+	var st stack = pcs[0:n]
+	return &st
+}
@@ -0,0 +1,9 @@
+//go:build !debug
+// +build !debug
+
+package errors
+
+// callers release 编译时不记录调用栈，需要时使用 `-tags debug` 编译
+func callers() *stack {
+	return nil
+}
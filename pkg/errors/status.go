@@ -0,0 +1,213 @@
+package errors
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/runtime/protoiface"
+	"google.golang.org/protobuf/runtime/protoimpl"
+)
+
+// metadataCode ErrorInfo.metadata 中保存业务错误码的 key
+const metadataCode = "code"
+
+// domain ErrorInfo.domain，app 启动时通过 SetDomain 设置为 app id
+var domain string
+
+// SetDomain 设置 GRPCStatus 中 google.rpc.ErrorInfo 的 domain，需要在处理请求之前调用
+//
+//	errors.SetDomain(conf.AppID)
+func SetDomain(d string) {
+	domain = d
+}
+
+// grpcCodes 错误类型对应的 grpc code
+var grpcCodes = map[Type]codes.Code{
+	Authorization:        codes.Unauthenticated,
+	BadRequest:           codes.InvalidArgument,
+	Conflict:             codes.AlreadyExists,
+	Forbidden:            codes.PermissionDenied,
+	Internal:             codes.Internal,
+	NotFound:             codes.NotFound,
+	PayloadTooLarge:      codes.OutOfRange,
+	ServiceUnavailable:   codes.Unavailable,
+	UnsupportedMediaType: codes.Unimplemented,
+	RequestTimeout:       codes.DeadlineExceeded,
+	TooManyRequests:      codes.ResourceExhausted,
+}
+
+// grpcTypes grpc code 对应的错误类型，没有列出的 code 按照 Internal 处理
+var grpcTypes = map[codes.Code]Type{
+	codes.Unauthenticated:    Authorization,
+	codes.InvalidArgument:    BadRequest,
+	codes.FailedPrecondition: BadRequest,
+	codes.AlreadyExists:      Conflict,
+	codes.Aborted:            Conflict,
+	codes.PermissionDenied:   Forbidden,
+	codes.NotFound:           NotFound,
+	codes.OutOfRange:         PayloadTooLarge,
+	codes.Unavailable:        ServiceUnavailable,
+	codes.Unimplemented:      UnsupportedMediaType,
+	codes.DeadlineExceeded:   RequestTimeout,
+	codes.Canceled:           RequestTimeout,
+	codes.ResourceExhausted:  TooManyRequests,
+}
+
+// httpTypes http 状态码对应的错误类型，未知的 4xx 按照 BadRequest 处理，其余按照 Internal 处理
+var httpTypes = map[int]Type{
+	http.StatusUnauthorized:          Authorization,
+	http.StatusBadRequest:            BadRequest,
+	http.StatusConflict:              Conflict,
+	http.StatusForbidden:             Forbidden,
+	http.StatusInternalServerError:   Internal,
+	http.StatusNotFound:              NotFound,
+	http.StatusRequestEntityTooLarge: PayloadTooLarge,
+	http.StatusServiceUnavailable:    ServiceUnavailable,
+	http.StatusBadGateway:            ServiceUnavailable,
+	http.StatusUnsupportedMediaType:  UnsupportedMediaType,
+	http.StatusRequestTimeout:        RequestTimeout,
+	http.StatusGatewayTimeout:        RequestTimeout,
+	http.StatusTooManyRequests:       TooManyRequests,
+}
+
+// GRPCCode 返回错误类型对应的 grpc code
+func (e *Error) GRPCCode() codes.Code {
+	if c, ok := grpcCodes[e.Type]; ok {
+		return c
+	}
+
+	return codes.Unknown
+}
+
+// GRPCStatus 转换为 grpc status，grpc-go 和 status.FromError 会自动调用
+//
+// 业务错误码、reason 和 metadata 通过 google.rpc.ErrorInfo 传递，之后是 WithDetails 附加的错误详情
+func (e *Error) GRPCStatus() *status.Status {
+	s := status.New(e.GRPCCode(), e.Message)
+
+	info := &errdetails.ErrorInfo{
+		Reason:   e.Reason,
+		Domain:   domain,
+		Metadata: make(map[string]string, len(e.Metadata)+1),
+	}
+	if info.Reason == "" {
+		info.Reason = string(e.Type)
+	}
+	for k, v := range e.Metadata {
+		info.Metadata[k] = v
+	}
+	if e.BizCode != 0 {
+		info.Metadata[metadataCode] = strconv.Itoa(e.BizCode)
+	}
+
+	details := make([]proto.Message, 0, len(e.Details)+1)
+	details = append(details, info)
+	details = append(details, e.Details...)
+
+	ds, err := s.WithDetails(toV1(details)...)
+	if err != nil {
+		return s
+	}
+
+	return ds
+}
+
+// FromGRPCStatus 把 grpc status 转换为 *Error，和 (*Error).GRPCStatus 相反
+func FromGRPCStatus(s *status.Status) *Error {
+	t, ok := grpcTypes[s.Code()]
+	if !ok {
+		t = Internal
+	}
+
+	e := &Error{Type: t, Message: s.Message()}
+	for _, d := range s.Details() {
+		switch detail := d.(type) {
+		case *errdetails.ErrorInfo:
+			if detail.Reason != string(t) {
+				e.Reason = detail.Reason
+			}
+
+			for k, v := range detail.Metadata {
+				if k == metadataCode {
+					e.BizCode, _ = strconv.Atoi(v)
+					continue
+				}
+
+				if e.Metadata == nil {
+					e.Metadata = map[string]string{}
+				}
+				e.Metadata[k] = v
+			}
+		case proto.Message:
+			e.Details = append(e.Details, detail)
+		}
+	}
+
+	return e
+}
+
+// FromHTTPStatus 根据 http 状态码创建错误，例如下游 http 服务的响应
+func FromHTTPStatus(code int, msg string) *Error {
+	t, ok := httpTypes[code]
+	if !ok {
+		t = Internal
+		if code >= 400 && code < 500 {
+			t = BadRequest
+		}
+	}
+
+	return newError(t, code, msg)
+}
+
+// FromError 把任意错误转换为 *Error：
+// 错误链中有 *Error 时直接返回，grpc status 错误通过 FromGRPCStatus 转换，其余包装为 Internal
+func FromError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	if s, ok := status.FromError(err); ok {
+		return FromGRPCStatus(s)
+	}
+
+	return Wrap(err, Internal, "Internal server error.")
+}
+
+// toV1 转换为 status.WithDetails 需要的旧版 proto message
+func toV1(details []proto.Message) []protoiface.MessageV1 {
+	ms := make([]protoiface.MessageV1, 0, len(details))
+	for _, d := range details {
+		ms = append(ms, protoimpl.X.ProtoMessageV1Of(d))
+	}
+
+	return ms
+}
+
+// FieldViolations 返回 google.rpc.BadRequest 详情中的字段错误
+func (e *Error) FieldViolations() []*errdetails.BadRequest_FieldViolation {
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, d := range e.Details {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			violations = append(violations, br.FieldViolations...)
+		}
+	}
+
+	return violations
+}
+
+// NewFieldViolations 创建一个带有 google.rpc.BadRequest 详情的 400 错误
+func NewFieldViolations(reason string, violations ...*errdetails.BadRequest_FieldViolation) *Error {
+	e := newError(BadRequest, 0, reason)
+	e.Details = []proto.Message{&errdetails.BadRequest{FieldViolations: violations}}
+	return e
+}
@@ -0,0 +1,102 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"nautilus/pkg/sqlx"
+	"nautilus/pkg/trace"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// panicError checker panic
+type panicError struct {
+	p interface{}
+}
+
+func (e panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.p)
+}
+
+// SQL 检查数据库连接
+func SQL(db *sql.DB) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	})
+}
+
+// SQLX 检查所有通过 sqlx.Get/sqlx.Open 创建的连接池，检查时才获取连接池列表，之后创建的连接池也会被检查
+//
+//	health.Register("mysql", health.SQLX(), true)
+func SQLX() Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		dbs := sqlx.All()
+
+		var failed []string
+		for name, db := range dbs {
+			if err := db.PingContext(ctx); err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			}
+		}
+		if len(failed) == 0 {
+			return nil
+		}
+
+		sort.Strings(failed)
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	})
+}
+
+// RedisPinger redis.Client/redis.ClusterClient 等都实现了 Ping
+type RedisPinger interface {
+	Ping(ctx context.Context) *redis.StatusCmd
+}
+
+// Redis 检查 redis 连接
+func Redis(c RedisPinger) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		return c.Ping(ctx).Err()
+	})
+}
+
+// HTTP 检查下游 http 服务，请求 url 返回 5xx 或者请求失败时检查失败
+// url 一般为下游服务的健康检查接口
+func HTTP(url string) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("http status %d", resp.StatusCode)
+		}
+
+		return nil
+	})
+}
+
+// TraceExporter 检查 trace 后端，最近一次上报 span 失败时检查失败，参考 trace.ExportError
+// trace 上报失败不影响业务，一般注册为非关键检查
+//
+//	health.Register("trace", health.TraceExporter(), false)
+func TraceExporter() Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		if err := trace.ExportError(); err != nil {
+			return fmt.Errorf("trace exporter: %v", err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,274 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nautilus/pkg/conf"
+	"nautilus/pkg/metrics"
+)
+
+const (
+	// StatusOK 所有检查都通过
+	StatusOK = "ok"
+	// StatusDegraded 非关键的依赖检查失败，仍然可以提供服务
+	StatusDegraded = "degraded"
+	// StatusDown 关键的依赖检查失败或者正在停止，不能提供服务
+	StatusDown = "down"
+)
+
+const (
+	defaultInterval = 10 * time.Second
+	defaultTimeout  = 2 * time.Second
+)
+
+// Checker 依赖检查
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc 函数形式的 Checker
+type CheckerFunc func(ctx context.Context) error
+
+// Check 实现 Checker
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Result 单个检查的结果
+type Result struct {
+	Name      string        `json:"name"`
+	Critical  bool          `json:"critical"`
+	OK        bool          `json:"ok"`
+	Error     string        `json:"error,omitempty"`
+	Duration  time.Duration `json:"duration"`
+	CheckedAt time.Time     `json:"checked_at"`
+}
+
+// Report 所有检查的结果
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// check 注册的检查，结果缓存 interval，同一个检查同时只会执行一次
+type check struct {
+	name     string
+	checker  Checker
+	critical bool
+
+	mu     sync.Mutex
+	result *Result
+}
+
+// Registry 检查注册表
+type Registry struct {
+	// Interval 检查结果的缓存时间
+	Interval time.Duration
+	// Timeout 单个检查的超时时间
+	Timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[string]*check
+
+	shutdown int32
+	started  time.Time
+}
+
+// NewRegistry 创建检查注册表
+func NewRegistry(interval, timeout time.Duration) *Registry {
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &Registry{
+		Interval: interval,
+		Timeout:  timeout,
+		checks:   map[string]*check{},
+		started:  time.Now(),
+	}
+}
+
+// std 默认的注册表
+//
+//	HEALTH_INTERVAL  检查结果的缓存时间，默认 10s
+//	HEALTH_TIMEOUT   单个检查的超时时间，默认 2s
+var std = NewRegistry(conf.GetDuration("HEALTH_INTERVAL"), conf.GetDuration("HEALTH_TIMEOUT"))
+
+// Register 注册检查，同名的检查会被覆盖
+// critical 为 true 时检查失败 /readyz 返回 503，否则只标记为 degraded
+func Register(name string, checker Checker, critical bool) {
+	std.Register(name, checker, critical)
+}
+
+// Unregister 删除检查
+func Unregister(name string) {
+	std.Unregister(name)
+}
+
+// Shutdown 标记为正在停止，之后 /readyz 返回 503，负载均衡不再转发新的请求
+func Shutdown() {
+	std.Shutdown()
+}
+
+// Ready 执行所有检查，返回结果
+func Ready() Report {
+	return std.Ready()
+}
+
+// LiveHandler 存活检查，进程能处理请求就返回 200，不检查依赖，避免依赖故障时实例被重启
+func LiveHandler() http.Handler {
+	return std.LiveHandler()
+}
+
+// ReadyHandler 就绪检查，关键依赖检查失败或者正在停止时返回 503
+func ReadyHandler() http.Handler {
+	return std.ReadyHandler()
+}
+
+// Register 注册检查
+func (r *Registry) Register(name string, checker Checker, critical bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checks[name] = &check{name: name, checker: checker, critical: critical}
+}
+
+// Unregister 删除检查
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.checks, name)
+}
+
+// Shutdown 标记为正在停止
+func (r *Registry) Shutdown() {
+	atomic.StoreInt32(&r.shutdown, 1)
+}
+
+// Ready 并发执行所有检查，缓存没有过期的检查直接使用缓存的结果
+func (r *Registry) Ready() Report {
+	r.mu.RLock()
+	checks := make([]*check, 0, len(r.checks))
+	for _, c := range r.checks {
+		checks = append(checks, c)
+	}
+	r.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *check) {
+			defer wg.Done()
+			results[i] = r.run(c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, res := range results {
+		if res.OK {
+			continue
+		}
+
+		if res.Critical {
+			report.Status = StatusDown
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+	if atomic.LoadInt32(&r.shutdown) == 1 {
+		report.Status = StatusDown
+	}
+
+	return report
+}
+
+// run 执行单个检查，结果没有过期时直接返回
+// 不使用探测请求的 ctx，探测请求断开不影响检查结果的缓存
+func (r *Registry) run(c *check) Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.result != nil && time.Since(c.result.CheckedAt) < r.Interval {
+		return *c.result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := safeCheck(ctx, c.checker)
+	res := &Result{
+		Name:      c.name,
+		Critical:  c.critical,
+		OK:        err == nil,
+		Duration:  time.Since(start),
+		CheckedAt: time.Now(),
+	}
+
+	up := float64(1)
+	if err != nil {
+		res.Error = err.Error()
+		up = 0
+	}
+	metrics.HealthCheckUp.WithLabelValues(c.name).Set(up)
+
+	c.result = res
+	return *res
+}
+
+// LiveHandler 存活检查
+func (r *Registry) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status": StatusOK,
+			"uptime": time.Since(r.started).String(),
+		})
+	})
+}
+
+// ReadyHandler 就绪检查
+func (r *Registry) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.Ready()
+
+		status := http.StatusOK
+		if report.Status == StatusDown {
+			status = http.StatusServiceUnavailable
+		}
+
+		writeJSON(w, status, report)
+	})
+}
+
+// safeCheck checker panic 时按照检查失败处理
+func safeCheck(ctx context.Context, c Checker) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = panicError{p}
+		}
+	}()
+
+	return c.Check(ctx)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
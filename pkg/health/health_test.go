@@ -0,0 +1,144 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nautilus/pkg/sqlx"
+	"nautilus/pkg/trace"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestReady(t *testing.T) {
+	r := NewRegistry(time.Hour, time.Second)
+
+	var calls int32
+	r.Register("db", CheckerFunc(func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}), true)
+	report := r.Ready()
+	assert.Equal(t, StatusOK, report.Status)
+
+	// 非关键依赖失败
+	r.Register("cache", CheckerFunc(func(ctx context.Context) error {
+		return errors.New("refused")
+	}), false)
+	report = r.Ready()
+	assert.Equal(t, StatusDegraded, report.Status)
+	if assert.Len(t, report.Checks, 2) {
+		assert.Equal(t, "cache", report.Checks[0].Name)
+		assert.Equal(t, "refused", report.Checks[0].Error)
+		assert.True(t, report.Checks[1].OK)
+	}
+	// 结果被缓存
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// 关键依赖失败
+	r.Register("panic", CheckerFunc(func(ctx context.Context) error {
+		panic("boom")
+	}), true)
+	report = r.Ready()
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, "panic: boom", report.Checks[2].Error)
+
+	r.Unregister("panic")
+	r.Unregister("cache")
+	assert.Equal(t, StatusOK, r.Ready().Status)
+}
+
+func TestReadyTimeout(t *testing.T) {
+	r := NewRegistry(time.Millisecond, 10*time.Millisecond)
+	r.Register("slow", CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), true)
+
+	report := r.Ready()
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry(time.Hour, time.Second)
+	r.Register("db", CheckerFunc(func(ctx context.Context) error { return nil }), true)
+
+	w := httptest.NewRecorder()
+	r.ReadyHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var report Report
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, StatusOK, report.Status)
+
+	// 停止时存活检查仍然返回 200，就绪检查返回 503
+	r.Shutdown()
+	w = httptest.NewRecorder()
+	r.ReadyHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"down"`)
+
+	w = httptest.NewRecorder()
+	r.LiveHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCheckers(t *testing.T) {
+	ctx := context.Background()
+
+	// sqlx
+	db, err := sqlx.Open("health_test", "sqlite3", "file::memory:")
+	assert.Nil(t, err)
+	assert.Nil(t, SQLX().Check(ctx))
+	assert.Nil(t, SQL(db.DB.DB).Check(ctx))
+	db.Close()
+	assert.NotNil(t, SQLX().Check(ctx))
+
+	// redis
+	mr, err := miniredis.Run()
+	assert.Nil(t, err)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	assert.Nil(t, Redis(client).Check(ctx))
+	mr.Close()
+	assert.NotNil(t, Redis(client).Check(ctx))
+
+	// http
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	assert.Nil(t, HTTP(srv.URL).Check(ctx))
+	status = http.StatusBadGateway
+	assert.NotNil(t, HTTP(srv.URL).Check(ctx))
+
+	// trace，collector 返回 4xx 时上报失败
+	status = http.StatusBadRequest
+	assert.Nil(t, trace.Init(ctx, &trace.Config{Endpoint: srv.URL, Batcher: "otlp-http", Sampler: 1}))
+	defer trace.Stop()
+	assert.Nil(t, TraceExporter().Check(ctx))
+
+	export := func() {
+		_, span := otel.Tracer("health").Start(ctx, "check")
+		span.End()
+		_ = otel.GetTracerProvider().(*sdktrace.TracerProvider).ForceFlush(ctx)
+	}
+	export()
+	assert.NotNil(t, TraceExporter().Check(ctx))
+	status = http.StatusOK
+	export()
+	assert.Nil(t, TraceExporter().Check(ctx))
+}
@@ -0,0 +1,64 @@
+package httprule
+
+import (
+	"regexp"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// varRE 匹配 google.api.http 路径中的变量，例如 {author_id} {name=shelves/*}
+var varRE = regexp.MustCompile(`{([^}=]+)(=[^}]*)?}`)
+
+// Rules 方法上 google.api.http 注解声明的所有路由，additional_bindings 按顺序排在后面，没有注解时为 nil
+func Rules(md protoreflect.MethodDescriptor) []*annotations.HttpRule {
+	rule, ok := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+	if !ok || rule == nil {
+		return nil
+	}
+
+	return append([]*annotations.HttpRule{rule}, rule.AdditionalBindings...)
+}
+
+// Pattern 返回 http method 和路径，method 为大写，没有声明路径时都为空
+func Pattern(r *annotations.HttpRule) (method, path string) {
+	switch p := r.Pattern.(type) {
+	case *annotations.HttpRule_Get:
+		return "GET", p.Get
+	case *annotations.HttpRule_Put:
+		return "PUT", p.Put
+	case *annotations.HttpRule_Post:
+		return "POST", p.Post
+	case *annotations.HttpRule_Delete:
+		return "DELETE", p.Delete
+	case *annotations.HttpRule_Patch:
+		return "PATCH", p.Patch
+	case *annotations.HttpRule_Custom:
+		return strings.ToUpper(p.Custom.Kind), p.Custom.Path
+	default:
+		return "", ""
+	}
+}
+
+// GinPath 把 google.api.http 路径转换成 gin 路由，生成的 gin 代码和超时配置都使用这个格式
+// /v1/author/{author_id}/articles => /v1/author/:author_id/articles
+func GinPath(path string) string {
+	return varRE.ReplaceAllString(path, ":$1")
+}
+
+// OpenAPIPath 去掉变量中的匹配规则，/v1/{name=shelves/*}/books => /v1/{name}/books
+func OpenAPIPath(path string) string {
+	return varRE.ReplaceAllString(path, "{$1}")
+}
+
+// Vars 路径中的变量名
+func Vars(path string) []string {
+	var vars []string
+	for _, m := range varRE.FindAllStringSubmatch(path, -1) {
+		vars = append(vars, m[1])
+	}
+
+	return vars
+}
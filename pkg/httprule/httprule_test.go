@@ -0,0 +1,46 @@
+package httprule
+
+import (
+	"testing"
+
+	demo "nautilus/api/demo/v0"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+func TestRules(t *testing.T) {
+	methods := demo.File_demo_v0_demo_proto.Services().ByName("BlogService").Methods()
+
+	var paths []string
+	for _, r := range Rules(methods.ByName("GetArticles")) {
+		method, path := Pattern(r)
+		assert.Equal(t, "GET", method)
+		paths = append(paths, path)
+	}
+	assert.Equal(t, []string{"/v1/articles", "/v1/author/{author_id}/articles"}, paths)
+}
+
+func TestPattern(t *testing.T) {
+	method, path := Pattern(&annotations.HttpRule{Pattern: &annotations.HttpRule_Custom{
+		Custom: &annotations.CustomHttpPattern{Kind: "head", Path: "/v1/ping"},
+	}})
+	assert.Equal(t, "HEAD", method)
+	assert.Equal(t, "/v1/ping", path)
+
+	method, path = Pattern(&annotations.HttpRule{})
+	assert.Equal(t, "", method)
+	assert.Equal(t, "", path)
+}
+
+func TestGinPath(t *testing.T) {
+	assert.Equal(t, "/v1/author/:author_id/articles", GinPath("/v1/author/{author_id}/articles"))
+	assert.Equal(t, "/v1/:name/books/:id", GinPath("/v1/{name=shelves/*}/books/{id}"))
+	assert.Equal(t, "/v1/articles", GinPath("/v1/articles"))
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	assert.Equal(t, "/v1/{name}/books/{id}", OpenAPIPath("/v1/{name=shelves/*}/books/{id}"))
+	assert.Equal(t, []string{"name", "id"}, Vars("/v1/{name=shelves/*}/books/{id}"))
+	assert.Nil(t, Vars("/v1/articles"))
+}
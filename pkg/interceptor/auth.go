@@ -0,0 +1,71 @@
+package interceptor
+
+import (
+	"context"
+
+	"nautilus/pkg/jwt"
+	"nautilus/pkg/rbac"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnaryServerAuth grpc 鉴权，和 HTTP 使用同一套 proto 中声明的 (nautilus.auth.rule)
+// token 从 metadata authorization: Bearer ${token} 中获取
+func UnaryServerAuth(revoked jwt.Revoked, e *rbac.Enforcer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authorize(ctx, info.FullMethod, revoked, e)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerAuth grpc stream 鉴权
+func StreamServerAuth(revoked jwt.Revoked, e *rbac.Enforcer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		ctx, err := authorize(ss.Context(), info.FullMethod, revoked, e)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &authStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authorize 根据方法的鉴权规则校验 token 和权限
+func authorize(ctx context.Context, fullMethod string, revoked jwt.Revoked, e *rbac.Enforcer) (context.Context, error) {
+	rule := rbac.MethodRule(fullMethod)
+	if rule.GetPublic() {
+		return ctx, nil
+	}
+
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vs := md.Get("authorization"); len(vs) > 0 {
+			token = jwt.BearerToken(vs[0])
+		}
+	}
+
+	ctx, err := jwt.Authenticate(ctx, token, revoked)
+	if err != nil {
+		return ctx, err
+	}
+
+	return ctx, e.Check(ctx, rule)
+}
+
+// authStream 替换 stream 的 ctx，带上登录信息
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context 返回带有登录信息的 ctx
+func (s *authStream) Context() context.Context {
+	return s.ctx
+}
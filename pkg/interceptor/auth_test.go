@@ -0,0 +1,59 @@
+package interceptor
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+
+	pb "nautilus/api/grpc_demo/v0"
+	"nautilus/pkg/ctxkit"
+	"nautilus/pkg/jwt"
+	"nautilus/pkg/rbac"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type uidGreeter struct {
+	pb.UnimplementedGreeterServer
+}
+
+func (g *uidGreeter) SayHello(ctx context.Context, req *pb.HelloRequest) (*pb.HelloReply, error) {
+	return &pb.HelloReply{Message: strconv.FormatInt(ctxkit.GetUID(ctx), 10)}, nil
+}
+
+func TestUnaryServerAuth(t *testing.T) {
+	assert.Nil(t, jwt.SetKeys(jwt.NewHMACKey("k1", []byte("0123456789abcdef0123456789abcdef"))))
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer(grpc.UnaryInterceptor(UnaryServerAuth(nil, rbac.NewEnforcer())))
+	pb.RegisterGreeterServer(s, &uidGreeter{})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.DialContext(context.TODO(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithInsecure(),
+	)
+	assert.Nil(t, err)
+	defer conn.Close()
+	client := pb.NewGreeterClient(conn)
+
+	// Greeter 没有声明鉴权规则，需要登录
+	_, err = client.SayHello(context.TODO(), &pb.HelloRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	p, err := jwt.NewPair(10, "admin", "sid")
+	assert.Nil(t, err)
+	ctx := metadata.AppendToOutgoingContext(context.TODO(), "authorization", "Bearer "+p.AccessToken)
+	reply, err := client.SayHello(ctx, &pb.HelloRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, "10", reply.Message)
+}
@@ -0,0 +1,156 @@
+package interceptor
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	xtrace "nautilus/pkg/trace"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnaryClientInterceptor grpc client 一元拦截器
+// 创建 client span 并通过 metadata 向下游传递 trace 信息，记录日志和监控
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{},
+		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error) {
+		start := time.Now()
+		ctx, span := startClientSpan(ctx, method, cc.Target())
+		defer span.End()
+
+		xtrace.MessageSent.Event(ctx, 1, req)
+
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		if err == nil {
+			xtrace.MessageReceived.Event(ctx, 1, reply)
+		}
+
+		finish(ctx, span, sideClient, method, start, err, "grpc call")
+		return
+	}
+}
+
+// StreamClientInterceptor grpc client 流式拦截器
+// span 在流结束(RecvMsg 返回错误或 io.EOF)时关闭，调用方需要把流读完
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+		method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		ctx, span := startClientSpan(ctx, method, cc.Target())
+
+		s, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			finish(ctx, span, sideClient, method, start, err, "grpc stream call")
+			span.End()
+			return s, err
+		}
+
+		return &clientStream{
+			ClientStream: s,
+			desc:         desc,
+			finish: func(err error) {
+				finish(ctx, span, sideClient, method, start, err, "grpc stream call")
+				span.End()
+			},
+		}, nil
+	}
+}
+
+// startClientSpan 创建 client span，并把 trace 信息注入 outgoing metadata
+func startClientSpan(ctx context.Context, method, target string) (context.Context, trace.Span) {
+	name, attrs := spanInfo(method, target)
+	ctx, span := tracer().Start(
+		ctx,
+		name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+
+	xtrace.Inject(ctx, otel.GetTextMapPropagator(), &md)
+	ctx = metadata.NewOutgoingContext(ctx, md)
+	return ctx, span
+}
+
+// clientStream 包装 grpc.ClientStream，记录收发消息事件，流结束时关闭 span
+type clientStream struct {
+	grpc.ClientStream
+
+	desc   *grpc.StreamDesc
+	once   sync.Once
+	finish func(err error)
+
+	receivedMessageID int
+	sentMessageID     int
+}
+
+// RecvMsg 接收消息，io.EOF 表示流正常结束
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		s.receivedMessageID++
+		xtrace.MessageReceived.Event(s.Context(), s.receivedMessageID, m)
+
+		// 非服务端流模式只会收到一条消息，收到即结束
+		if !s.desc.ServerStreams {
+			s.end(nil)
+		}
+	} else if err == io.EOF {
+		s.end(nil)
+	} else {
+		s.end(err)
+	}
+
+	return err
+}
+
+// SendMsg 发送消息
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	s.sentMessageID++
+	xtrace.MessageSent.Event(s.Context(), s.sentMessageID, m)
+
+	if err != nil && err != io.EOF {
+		s.end(err)
+	}
+
+	return err
+}
+
+// Header 获取响应头
+func (s *clientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.end(err)
+	}
+
+	return md, err
+}
+
+// CloseSend 关闭发送端
+func (s *clientStream) CloseSend() error {
+	err := s.ClientStream.CloseSend()
+	if err != nil {
+		s.end(err)
+	}
+
+	return err
+}
+
+// end 保证 span 只结束一次
+func (s *clientStream) end(err error) {
+	s.once.Do(func() {
+		s.finish(err)
+	})
+}
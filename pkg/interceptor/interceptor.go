@@ -0,0 +1,119 @@
+package interceptor
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"nautilus/pkg/log"
+	"nautilus/pkg/metrics"
+	xtrace "nautilus/pkg/trace"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// tracerName grpc 拦截器使用的 tracer 名字
+const tracerName = "nautilus.grpc"
+
+// 监控中区分 server 和 client 拦截器记录的耗时
+const (
+	sideServer = "server"
+	sideClient = "client"
+)
+
+// tracer 返回全局 TracerProvider 下的 tracer
+// 每次调用时获取，保证 trace.Init 之后替换的 TracerProvider 能生效
+func tracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(tracerName, trace.WithInstrumentationVersion(xtrace.SemVersion()))
+}
+
+// spanInfo 根据 grpc 方法名和对端地址生成 span 名字和属性
+// fullMethod 格式: /package.service/method
+func spanInfo(fullMethod, peerAddress string) (string, []attribute.KeyValue) {
+	attrs := []attribute.KeyValue{xtrace.RPCSystemGRPC}
+	name, mAttrs := parseFullMethod(fullMethod)
+	attrs = append(attrs, mAttrs...)
+	attrs = append(attrs, peerAttr(peerAddress)...)
+	return name, attrs
+}
+
+// parseFullMethod 解析出 rpc.service 和 rpc.method 属性
+func parseFullMethod(fullMethod string) (string, []attribute.KeyValue) {
+	name := strings.TrimLeft(fullMethod, "/")
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) != 2 {
+		return name, nil
+	}
+
+	var attrs []attribute.KeyValue
+	if service := parts[0]; service != "" {
+		attrs = append(attrs, semconv.RPCServiceKey.String(service))
+	}
+	if method := parts[1]; method != "" {
+		attrs = append(attrs, semconv.RPCMethodKey.String(method))
+	}
+
+	return name, attrs
+}
+
+// peerAttr 对端 ip/port 属性
+func peerAttr(addr string) []attribute.KeyValue {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil
+	}
+
+	if host == "" {
+		host = "127.0.0.1"
+	}
+
+	attrs := []attribute.KeyValue{semconv.NetPeerIPKey.String(host)}
+	if p, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, semconv.NetPeerPortKey.Int(p))
+	}
+
+	return attrs
+}
+
+// peerFromCtx 从 ctx 中获取对端地址
+func peerFromCtx(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	return p.Addr.String()
+}
+
+// finish 请求结束时统一处理 span 状态、日志和监控，side 为 sideServer/sideClient
+func finish(ctx context.Context, span trace.Span, side, method string, start time.Time, err error, msg string) {
+	duration := time.Since(start)
+	s, _ := status.FromError(err)
+
+	span.SetAttributes(xtrace.StatusCodeAttr(s.Code()))
+	if err != nil {
+		span.SetStatus(codes.Error, s.Message())
+	} else {
+		span.SetStatus(codes.Ok, "")
+	}
+
+	fields := log.Fields{
+		"method": method,
+		"code":   s.Code().String(),
+		"cost":   duration.Seconds(),
+	}
+	if err != nil {
+		fields["error"] = s.Message()
+	}
+	log.Get(ctx).WithFields(fields).Info(msg)
+
+	metrics.GRPCDurationSeconds.WithLabelValues(side, method, s.Code().String()).Observe(duration.Seconds())
+}
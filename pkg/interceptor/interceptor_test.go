@@ -0,0 +1,105 @@
+package interceptor
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	pb "nautilus/api/grpc_demo/v0"
+	"nautilus/pkg/ctxkit"
+	"nautilus/pkg/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type greeter struct {
+	pb.UnimplementedGreeterServer
+}
+
+func (g *greeter) SayHello(ctx context.Context, req *pb.HelloRequest) (*pb.HelloReply, error) {
+	return &pb.HelloReply{Message: ctxkit.GetTraceID(ctx)}, nil
+}
+
+func (g *greeter) SayHelloAgain(ctx context.Context, req *pb.HelloRequest) (*pb.HelloReply, error) {
+	panic("boom")
+}
+
+func dial(t *testing.T) pb.GreeterClient {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.AlwaysSample())))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor()),
+		grpc.StreamInterceptor(StreamServerInterceptor()),
+	)
+	pb.RegisterGreeterServer(s, &greeter{})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.DialContext(context.TODO(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(StreamClientInterceptor()),
+	)
+	assert.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewGreeterClient(conn)
+}
+
+func TestPropagation(t *testing.T) {
+	client := dial(t)
+
+	ctx, span := otel.Tracer("test").Start(context.TODO(), "root")
+	defer span.End()
+
+	var header metadata.MD
+	reply, err := client.SayHello(ctx, &pb.HelloRequest{Name: "foo"}, grpc.Header(&header))
+	assert.Nil(t, err)
+
+	traceID := trace.SpanContextFromContext(ctx).TraceID().String()
+	assert.Equal(t, traceID, reply.Message)
+	assert.Equal(t, []string{traceID}, header.Get("x-trace-id"))
+}
+
+func TestDurationSide(t *testing.T) {
+	client := dial(t)
+
+	_, err := client.SayHello(context.TODO(), &pb.HelloRequest{Name: "foo"})
+	assert.Nil(t, err)
+
+	// server 和 client 分别记录一条，Delete 返回 true 说明对应的 series 存在
+	const method = "/helloworld.Greeter/SayHello"
+	assert.True(t, metrics.GRPCDurationSeconds.DeleteLabelValues(sideServer, method, "OK"))
+	assert.True(t, metrics.GRPCDurationSeconds.DeleteLabelValues(sideClient, method, "OK"))
+}
+
+func TestRecovery(t *testing.T) {
+	client := dial(t)
+
+	_, err := client.SayHelloAgain(context.TODO(), &pb.HelloRequest{Name: "foo"})
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestParseFullMethod(t *testing.T) {
+	name, attrs := parseFullMethod("/helloworld.Greeter/SayHello")
+	assert.Equal(t, "helloworld.Greeter/SayHello", name)
+	assert.Len(t, attrs, 2)
+
+	name, attrs = parseFullMethod("invalid")
+	assert.Equal(t, "invalid", name)
+	assert.Len(t, attrs, 0)
+}
@@ -0,0 +1,136 @@
+package interceptor
+
+import (
+	"context"
+	"time"
+
+	"nautilus/pkg/ctxkit"
+	"nautilus/pkg/recovery"
+	xtrace "nautilus/pkg/trace"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor grpc server 一元拦截器
+// 从 metadata 中提取 trace 信息创建 span，记录日志和监控，并将 panic 转换为 codes.Internal
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (resp interface{}, err error) {
+		start := time.Now()
+		ctx, span := startServerSpan(ctx, info.FullMethod, start)
+		defer span.End()
+
+		_ = grpc.SetHeader(ctx, metadata.Pairs(xtrace.TraceIDKey, ctxkit.GetTraceID(ctx)))
+
+		defer func() {
+			if p := recover(); p != nil {
+				err = recoverFrom(ctx, p)
+			}
+
+			finish(ctx, span, sideServer, info.FullMethod, start, err, "new rpc")
+		}()
+
+		xtrace.MessageReceived.Event(ctx, 1, req)
+
+		resp, err = handler(ctx, req)
+		if err == nil {
+			xtrace.MessageSent.Event(ctx, 1, resp)
+		}
+
+		return
+	}
+}
+
+// StreamServerInterceptor grpc server 流式拦截器
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) (err error) {
+		start := time.Now()
+		ctx, span := startServerSpan(ss.Context(), info.FullMethod, start)
+		defer span.End()
+
+		_ = ss.SetHeader(metadata.Pairs(xtrace.TraceIDKey, ctxkit.GetTraceID(ctx)))
+
+		defer func() {
+			if p := recover(); p != nil {
+				err = recoverFrom(ctx, p)
+			}
+
+			finish(ctx, span, sideServer, info.FullMethod, start, err, "new stream rpc")
+		}()
+
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// startServerSpan 从 incoming metadata 中提取上游 trace 信息并创建 server span
+// 同时把 trace id 和请求开始时间写入 ctxkit，和 HTTP 中间件保持一致
+func startServerSpan(ctx context.Context, fullMethod string, start time.Time) (context.Context, trace.Span) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+
+	bags, spanCtx := xtrace.Extract(ctx, otel.GetTextMapPropagator(), &md)
+	ctx = baggage.ContextWithBaggage(ctx, bags)
+
+	name, attrs := spanInfo(fullMethod, peerFromCtx(ctx))
+	ctx, span := tracer().Start(
+		trace.ContextWithRemoteSpanContext(ctx, spanCtx),
+		name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attrs...),
+	)
+
+	ctx = context.WithValue(ctx, ctxkit.StartTimeKey, start)
+	ctx = ctxkit.WithTraceID(ctx, xtrace.GetTraceID(ctx))
+	return ctx, span
+}
+
+// recoverFrom 记录 panic 堆栈并转换成 grpc 错误
+func recoverFrom(ctx context.Context, p interface{}) error {
+	recovery.Handle(ctx, recovery.KindGRPC, p)
+	return status.Errorf(codes.Internal, "panic: %v", p)
+}
+
+// serverStream 包装 grpc.ServerStream，替换 ctx 并记录收发消息事件
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+
+	receivedMessageID int
+	sentMessageID     int
+}
+
+// Context 返回带有 span 的 ctx
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// RecvMsg 接收消息
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.receivedMessageID++
+		xtrace.MessageReceived.Event(s.ctx, s.receivedMessageID, m)
+	}
+
+	return err
+}
+
+// SendMsg 发送消息
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	s.sentMessageID++
+	xtrace.MessageSent.Event(s.ctx, s.sentMessageID, m)
+
+	return err
+}
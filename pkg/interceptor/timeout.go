@@ -0,0 +1,31 @@
+package interceptor
+
+import (
+	"context"
+	"time"
+
+	"nautilus/pkg/deadline"
+
+	"google.golang.org/grpc"
+)
+
+// UnaryServerTimeout grpc 接口超时控制
+// timeout 为默认超时时间，proto 中通过 (nautilus.timeout.timeout) 声明的超时时间优先，0 表示不限制
+// 上游通过 grpc-timeout 传递的 deadline 已经由 grpc 设置到 ctx 中，这里只会缩短不会延长
+func UnaryServerTimeout(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		d := timeout
+		if v, ok := deadline.MethodTimeout(info.FullMethod); ok {
+			d = v
+		}
+
+		if d > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d)
+			defer cancel()
+		}
+
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,34 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestUnaryServerTimeout(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+	remaining := func(ctx context.Context, req interface{}) (interface{}, error) {
+		d, ok := ctx.Deadline()
+		if !ok {
+			return time.Duration(0), nil
+		}
+		return time.Until(d), nil
+	}
+
+	resp, err := UnaryServerTimeout(time.Second)(context.Background(), nil, info, remaining)
+	assert.Nil(t, err)
+	assert.InDelta(t, float64(time.Second), float64(resp.(time.Duration)), float64(100*time.Millisecond))
+
+	// 上游的 deadline 更短时不会延长
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	resp, _ = UnaryServerTimeout(time.Second)(ctx, nil, info, remaining)
+	assert.LessOrEqual(t, int64(resp.(time.Duration)), int64(100*time.Millisecond))
+
+	resp, _ = UnaryServerTimeout(0)(context.Background(), nil, info, remaining)
+	assert.Equal(t, time.Duration(0), resp)
+}
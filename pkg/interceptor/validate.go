@@ -0,0 +1,23 @@
+package interceptor
+
+import (
+	"context"
+
+	"nautilus/pkg/validate"
+
+	"google.golang.org/grpc"
+)
+
+// UnaryServerValidator grpc 请求参数校验
+// 和 HTTP 共用 proto 中 @inject_tag 声明的 binding 规则，
+// 校验失败返回 codes.InvalidArgument，携带 google.rpc.BadRequest 详情
+func UnaryServerValidator() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		if err := validate.Struct(req); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,63 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"nautilus/pkg/ctxkit"
+	xerrors "nautilus/pkg/errors"
+)
+
+// Revoked 判断登录会话是否已经注销，例如退出登录后 access token 在过期前也不能再使用
+type Revoked func(ctx context.Context, claims *Claims) (bool, error)
+
+var (
+	errMissingToken = xerrors.NewAuthorization("missing bearer token").WithReason("TOKEN_MISSING")
+	errInvalidToken = xerrors.NewAuthorization("invalid token").WithReason("TOKEN_INVALID")
+	errExpiredToken = xerrors.NewAuthorization("token is expired").WithReason("TOKEN_EXPIRED")
+	errRevokedToken = xerrors.NewAuthorization("token is revoked").WithReason("TOKEN_REVOKED")
+)
+
+// Authenticate 校验 access token，把 uid、角色和会话 id 写入 ctxkit
+// HTTP 中间件和 grpc 拦截器共用，返回的错误为 401 *errors.Error
+// revoked 为 nil 时不检查 token 是否已经注销
+func Authenticate(ctx context.Context, token string, revoked Revoked) (context.Context, error) {
+	if token == "" {
+		return ctx, errMissingToken
+	}
+
+	claims, err := Verify(token, TypeAccess)
+	if err != nil {
+		if errors.Is(err, ErrExpiredToken) {
+			return ctx, errExpiredToken.WithCause(err)
+		}
+
+		return ctx, errInvalidToken.WithCause(err)
+	}
+
+	if revoked != nil {
+		ok, err := revoked(ctx, claims)
+		if err != nil {
+			return ctx, xerrors.Wrap(err, xerrors.Internal, "Internal server error.")
+		}
+
+		if ok {
+			return ctx, errRevokedToken
+		}
+	}
+
+	ctx = ctxkit.WithUID(ctx, int64(claims.UID))
+	ctx = ctxkit.WithRole(ctx, claims.Role)
+	ctx = ctxkit.WithTokenID(ctx, claims.Id)
+	return ctx, nil
+}
+
+// BearerToken 从 Authorization 中提取 token，格式为 Bearer ${token}
+func BearerToken(authorization string) string {
+	if len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+		return strings.TrimSpace(authorization[7:])
+	}
+
+	return ""
+}
@@ -1,6 +1,8 @@
 package jwt
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"nautilus/pkg/conf"
@@ -8,15 +10,188 @@ import (
 	"github.com/golang-jwt/jwt"
 )
 
+const (
+	// TypeAccess 访问 token，请求接口时放在 Authorization: Bearer 中
+	TypeAccess = "access"
+	// TypeRefresh 刷新 token，只能用于换取新的 token
+	TypeRefresh = "refresh"
+)
+
+var (
+	// ErrInvalidToken token 格式、签名或者 kid 不正确
+	ErrInvalidToken = errors.New("jwt: invalid token")
+	// ErrExpiredToken token 已过期
+	ErrExpiredToken = errors.New("jwt: token is expired")
+	// ErrTokenType token 类型不匹配，例如使用 refresh token 访问接口
+	ErrTokenType = errors.New("jwt: unexpected token type")
+	// ErrNoExpiry token 没有过期时间，StandardClaims 会把它当作永不过期
+	ErrNoExpiry = errors.New("jwt: token has no exp")
+)
+
+// Claims token 中的信息
+// Id(jti) 为登录会话 id，同一次登录签发的 access/refresh token 相同，退出登录时根据它注销
+type Claims struct {
+	UID        uint64 `json:"user_id"`
+	Authorized bool   `json:"authorized"`
+	Type       string `json:"typ,omitempty"`
+	// Role 用户角色，用于 rbac 鉴权
+	Role string `json:"role,omitempty"`
+
+	jwt.StandardClaims
+}
+
+// AccessTTL access token 有效期，配置 JWT_ACCESS_TTL，默认15分钟
+func AccessTTL() time.Duration {
+	if d := conf.GetDuration("JWT_ACCESS_TTL"); d > 0 {
+		return d
+	}
+
+	return 15 * time.Minute
+}
+
+// RefreshTTL refresh token 有效期，配置 JWT_REFRESH_TTL，默认7天
+func RefreshTTL() time.Duration {
+	if d := conf.GetDuration("JWT_REFRESH_TTL"); d > 0 {
+		return d
+	}
+
+	return 7 * 24 * time.Hour
+}
+
 // CreateToken jwt 生成 token
-// token 15分钟有效
-// 需要配置 TOKEN_SECRET
+// token 15分钟有效，可以通过 JWT_ACCESS_TTL 修改
 func CreateToken(uid uint64) (token string, err error) {
-	atClaims := jwt.MapClaims{}
-	atClaims["authorized"] = true
-	atClaims["user_id"] = uid
-	atClaims["exp"] = time.Now().Add(time.Minute * 15).Unix()
-	at := jwt.NewWithClaims(jwt.SigningMethodHS256, atClaims)
-	token, err = at.SignedString([]byte(conf.Get("TOKEN_SECRET")))
+	return Sign(&Claims{
+		UID:  uid,
+		Type: TypeAccess,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(AccessTTL()).Unix(),
+		},
+	})
+}
+
+// Sign 使用当前密钥签发 token，header 中带上 kid
+// 必须设置 ExpiresAt，没有过期时间的 token 校验时会被拒绝
+func Sign(c *Claims) (string, error) {
+	if c.ExpiresAt == 0 {
+		return "", ErrNoExpiry
+	}
+
+	s, err := getKeys()
+	if err != nil {
+		return "", err
+	}
+
+	c.Authorized = true
+	if c.IssuedAt == 0 {
+		c.IssuedAt = time.Now().Unix()
+	}
+	if c.Issuer == "" {
+		c.Issuer = conf.AppID
+	}
+
+	t := jwt.NewWithClaims(s.current.Method, c)
+	if s.current.ID != "" {
+		t.Header["kid"] = s.current.ID
+	}
+
+	return t.SignedString(s.current.signKey)
+}
+
+// Parse 校验 token 的签名和有效期，返回 token 中的信息，没有 exp 的 token 不合法
+// 根据 header 中的 kid 选择密钥，签名算法必须和密钥一致，防止算法混淆攻击
+func Parse(token string) (*Claims, error) {
+	s, err := getKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Claims{}
+	_, err = jwt.ParseWithClaims(token, c, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		k, ok := s.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+
+		if t.Method.Alg() != k.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+		}
+
+		return k.verifyKey, nil
+	})
+	if err != nil {
+		var ve *jwt.ValidationError
+		if errors.As(err, &ve) && ve.Errors&jwt.ValidationErrorExpired != 0 {
+			return nil, fmt.Errorf("%w: %v", ErrExpiredToken, err)
+		}
+
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	// StandardClaims.Valid 不检查没有 exp 的 token
+	if c.ExpiresAt == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, ErrNoExpiry)
+	}
+
+	return c, nil
+}
+
+// Verify 校验 token 并检查 token 类型
+// 老版本签发的 token 没有类型，当作 access token
+func Verify(token, typ string) (*Claims, error) {
+	c, err := Parse(token)
+	if err != nil {
+		return nil, err
+	}
+
+	t := c.Type
+	if t == "" {
+		t = TypeAccess
+	}
+	if t != typ {
+		return nil, ErrTokenType
+	}
+
+	return c, nil
+}
+
+// Pair 登录后签发的一对 token
+type Pair struct {
+	AccessToken  string
+	RefreshToken string
+	// ExpiresIn access token 有效期，单位秒
+	ExpiresIn int64
+}
+
+// NewPair 为登录会话 sid 签发 access/refresh token
+func NewPair(uid uint64, role, sid string) (p Pair, err error) {
+	now := time.Now()
+	access := AccessTTL()
+
+	p.AccessToken, err = Sign(&Claims{
+		UID:  uid,
+		Type: TypeAccess,
+		Role: role,
+		StandardClaims: jwt.StandardClaims{
+			Id:        sid,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(access).Unix(),
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	p.RefreshToken, err = Sign(&Claims{
+		UID:  uid,
+		Type: TypeRefresh,
+		Role: role,
+		StandardClaims: jwt.StandardClaims{
+			Id:        sid,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(RefreshTTL()).Unix(),
+		},
+	})
+	p.ExpiresIn = int64(access / time.Second)
 	return
 }
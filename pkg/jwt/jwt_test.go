@@ -0,0 +1,155 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+// secret 测试用的 HS256 密钥，不能少于 32 字节
+var secret = []byte(strings.Repeat("s", 32))
+
+func TestSignAndParse(t *testing.T) {
+	rk, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	_, ek, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+
+	keys := []*Key{
+		NewHMACKey("hs", secret),
+		NewRSAKey("rs", rk, nil),
+		NewEdDSAKey("ed", ek, nil),
+	}
+
+	for _, k := range keys {
+		assert.Nil(t, SetKeys(k))
+
+		token, err := CreateToken(10)
+		assert.Nil(t, err)
+
+		c, err := Verify(token, TypeAccess)
+		assert.Nil(t, err, k.ID)
+		assert.Equal(t, uint64(10), c.UID)
+		assert.True(t, c.Authorized)
+
+		_, err = Verify(token, TypeRefresh)
+		assert.Equal(t, ErrTokenType, err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old := NewHMACKey("k1", secret)
+	assert.Nil(t, SetKeys(old))
+	token, err := CreateToken(10)
+	assert.Nil(t, err)
+
+	// 新 key 签发，旧 key 仍然可以校验
+	_, ek, _ := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, SetKeys(NewEdDSAKey("k2", ek, nil), old))
+	_, err = Parse(token)
+	assert.Nil(t, err)
+
+	// 旧 key 下线后校验失败
+	assert.Nil(t, SetKeys(NewEdDSAKey("k2", ek, nil)))
+	_, err = Parse(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestParseInvalid(t *testing.T) {
+	rk, _ := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, SetKeys(NewRSAKey("rs", rk, nil)))
+
+	// 使用公钥作为 HMAC 密钥伪造签名
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UID: 1})
+	forged.Header["kid"] = "rs"
+	token, err := forged.SignedString([]byte("any"))
+	assert.Nil(t, err)
+	_, err = Parse(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// 过期
+	token, err = Sign(&Claims{UID: 1, StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(-time.Minute).Unix()}})
+	assert.Nil(t, err)
+	_, err = Parse(token)
+	assert.ErrorIs(t, err, ErrExpiredToken)
+
+	_, err = Parse("abc")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// 没有 exp 的 token 当作永不过期，不允许签发和使用
+	_, err = Sign(&Claims{UID: 1})
+	assert.Equal(t, ErrNoExpiry, err)
+	assert.Nil(t, SetKeys(NewHMACKey("hs", secret)))
+	forged = jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UID: 1})
+	forged.Header["kid"] = "hs"
+	token, err = forged.SignedString(secret)
+	assert.Nil(t, err)
+	_, err = Parse(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestLoadKeys(t *testing.T) {
+	defer os.Unsetenv("TOKEN_SECRET")
+	defer os.Unsetenv("JWT_KEYS")
+	defer os.Unsetenv("JWT_KEY_K1_SECRET")
+
+	// 空密钥和太短的密钥
+	_, err := loadKeys()
+	assert.NotNil(t, err)
+	os.Setenv("TOKEN_SECRET", "secret")
+	_, err = loadKeys()
+	assert.NotNil(t, err)
+
+	os.Setenv("TOKEN_SECRET", strings.Repeat("s", 32))
+	s, err := loadKeys()
+	assert.Nil(t, err)
+	assert.Equal(t, "", s.current.ID)
+
+	os.Setenv("JWT_KEYS", "k1")
+	os.Setenv("JWT_KEY_K1_SECRET", "short")
+	_, err = loadKeys()
+	assert.NotNil(t, err)
+
+	os.Setenv("JWT_KEY_K1_SECRET", strings.Repeat("k", 32))
+	s, err = loadKeys()
+	assert.Nil(t, err)
+	assert.Equal(t, "k1", s.current.ID)
+}
+
+func TestSetKeys(t *testing.T) {
+	assert.Nil(t, SetKeys(NewHMACKey("k1", secret)))
+
+	// 太短的密钥不生效，原来的密钥不变
+	assert.NotNil(t, SetKeys(NewHMACKey("k2", []byte("secret"))))
+	_, ek, _ := ed25519.GenerateKey(rand.Reader)
+	assert.NotNil(t, SetKeys(NewEdDSAKey("k2", ek, nil), NewHMACKey("k3", nil)))
+
+	s, err := getKeys()
+	assert.Nil(t, err)
+	assert.Equal(t, "k1", s.current.ID)
+}
+
+func TestNewPair(t *testing.T) {
+	assert.Nil(t, SetKeys(NewHMACKey("", secret)))
+
+	p, err := NewPair(10, "admin", "sid")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(AccessTTL()/time.Second), p.ExpiresIn)
+
+	c, err := Verify(p.AccessToken, TypeAccess)
+	assert.Nil(t, err)
+	assert.Equal(t, "sid", c.Id)
+	assert.Equal(t, "admin", c.Role)
+
+	c, err = Verify(p.RefreshToken, TypeRefresh)
+	assert.Nil(t, err)
+	assert.Equal(t, "sid", c.Id)
+	assert.Equal(t, uint64(10), c.UID)
+}
@@ -0,0 +1,260 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"nautilus/pkg/conf"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// 密钥配置
+//
+//	# key id 列表，第一个用于签发，其余只用于校验
+//	# 轮换密钥时把新的 kid 放在最前面，等旧 token 全部过期后再删除旧的 kid
+//	JWT_KEYS = "k2,k1"
+//	# 签名算法 HS256/RS256/EdDSA，默认 HS256
+//	JWT_KEY_K2_ALG = "RS256"
+//	# HS256 密钥
+//	JWT_KEY_K1_SECRET = "xxx"
+//	# RS256/EdDSA 的 PEM 文件路径，只用于校验的 key 可以只配置公钥
+//	JWT_KEY_K2_PRIVATE = "/path/to/private.pem"
+//	JWT_KEY_K2_PUBLIC = "/path/to/public.pem"
+//
+// 没有配置 JWT_KEYS 时兼容老的配置，使用 TOKEN_SECRET 做 HS256 签名
+// HS256 密钥不能少于 32 字节，没有配置或者太短时签发和校验 token 都会返回错误
+
+// minSecretLen HS256 密钥的最小长度，和签名输出长度一致
+const minSecretLen = 32
+
+// Key 签名密钥，通过 token header 中的 kid 区分
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	// signKey 签发 token 使用，只用于校验的 key 为 nil
+	signKey interface{}
+	// verifyKey 校验 token 使用
+	verifyKey interface{}
+}
+
+// NewHMACKey 创建 HS256 密钥
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{ID: id, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+}
+
+// NewRSAKey 创建 RS256 密钥，private 为 nil 时只用于校验
+func NewRSAKey(id string, private *rsa.PrivateKey, public *rsa.PublicKey) *Key {
+	k := &Key{ID: id, Method: jwt.SigningMethodRS256, verifyKey: public}
+	if private != nil {
+		k.signKey = private
+		if public == nil {
+			k.verifyKey = &private.PublicKey
+		}
+	}
+
+	return k
+}
+
+// NewEdDSAKey 创建 EdDSA(ed25519) 密钥，private 为 nil 时只用于校验
+func NewEdDSAKey(id string, private ed25519.PrivateKey, public ed25519.PublicKey) *Key {
+	k := &Key{ID: id, Method: jwt.SigningMethodEdDSA, verifyKey: public}
+	if private != nil {
+		k.signKey = private
+		if public == nil {
+			k.verifyKey = private.Public()
+		}
+	}
+
+	return k
+}
+
+// keySet 当前使用的密钥
+type keySet struct {
+	current *Key
+	keys    map[string]*Key
+}
+
+var (
+	mu     sync.RWMutex
+	loaded bool
+	ks     keySet
+)
+
+// SetKeys 设置密钥，第一个 key 用于签发，所有 key 都可以用于校验
+// 一般不需要调用，默认从配置中加载，测试或者密钥存放在其他地方时使用
+// HS256 密钥和配置一样不能少于 32 字节，不符合时返回错误，原来的密钥不变
+func SetKeys(current *Key, others ...*Key) error {
+	s := keySet{current: current, keys: map[string]*Key{current.ID: current}}
+	for _, k := range others {
+		s.keys[k.ID] = k
+	}
+
+	for id, k := range s.keys {
+		if k.Method != jwt.SigningMethodHS256 {
+			continue
+		}
+		secret, _ := k.verifyKey.([]byte)
+		if err := checkSecret(fmt.Sprintf("key %q secret", id), string(secret)); err != nil {
+			return err
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	ks = s
+	loaded = true
+	return nil
+}
+
+// getKeys 获取密钥，第一次使用时从配置加载
+func getKeys() (keySet, error) {
+	mu.RLock()
+	if loaded {
+		mu.RUnlock()
+		return ks, nil
+	}
+	mu.RUnlock()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if loaded {
+		return ks, nil
+	}
+
+	s, err := loadKeys()
+	if err != nil {
+		return keySet{}, err
+	}
+
+	ks = s
+	loaded = true
+	return ks, nil
+}
+
+// loadKeys 从配置加载密钥
+func loadKeys() (keySet, error) {
+	ids := conf.GetStrings("JWT_KEYS")
+	if len(ids) == 0 {
+		secret := conf.Get("TOKEN_SECRET")
+		if err := checkSecret("TOKEN_SECRET", secret); err != nil {
+			return keySet{}, err
+		}
+		k := NewHMACKey("", []byte(secret))
+		return keySet{current: k, keys: map[string]*Key{"": k}}, nil
+	}
+
+	s := keySet{keys: make(map[string]*Key, len(ids))}
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		k, err := loadKey(id)
+		if err != nil {
+			return keySet{}, err
+		}
+
+		if s.current == nil {
+			if k.signKey == nil {
+				return keySet{}, fmt.Errorf("jwt: key %s has no private key for signing", id)
+			}
+			s.current = k
+		}
+		s.keys[id] = k
+	}
+
+	return s, nil
+}
+
+// checkSecret 检查 HS256 密钥，空密钥可以被任何人用来伪造 token
+func checkSecret(key, secret string) error {
+	if secret == "" {
+		return fmt.Errorf("jwt: %s is empty", key)
+	}
+	if len(secret) < minSecretLen {
+		return fmt.Errorf("jwt: %s must be at least %d bytes", key, minSecretLen)
+	}
+
+	return nil
+}
+
+// loadKey 加载单个 kid 的配置
+func loadKey(id string) (*Key, error) {
+	prefix := "JWT_KEY_" + strings.ToUpper(id) + "_"
+
+	alg := conf.Get(prefix + "ALG")
+	switch alg {
+	case "", jwt.SigningMethodHS256.Alg():
+		secret := conf.Get(prefix + "SECRET")
+		if err := checkSecret(prefix+"SECRET", secret); err != nil {
+			return nil, err
+		}
+		return NewHMACKey(id, []byte(secret)), nil
+	case jwt.SigningMethodRS256.Alg():
+		var private *rsa.PrivateKey
+		var public *rsa.PublicKey
+		if b, err := readPEM(prefix + "PRIVATE"); err != nil {
+			return nil, err
+		} else if b != nil {
+			if private, err = jwt.ParseRSAPrivateKeyFromPEM(b); err != nil {
+				return nil, fmt.Errorf("jwt: parse %sPRIVATE: %w", prefix, err)
+			}
+		}
+		if b, err := readPEM(prefix + "PUBLIC"); err != nil {
+			return nil, err
+		} else if b != nil {
+			if public, err = jwt.ParseRSAPublicKeyFromPEM(b); err != nil {
+				return nil, fmt.Errorf("jwt: parse %sPUBLIC: %w", prefix, err)
+			}
+		}
+		if private == nil && public == nil {
+			return nil, fmt.Errorf("jwt: key %s has neither private nor public key", id)
+		}
+		return NewRSAKey(id, private, public), nil
+	case jwt.SigningMethodEdDSA.Alg():
+		var private ed25519.PrivateKey
+		var public ed25519.PublicKey
+		if b, err := readPEM(prefix + "PRIVATE"); err != nil {
+			return nil, err
+		} else if b != nil {
+			k, err := jwt.ParseEdPrivateKeyFromPEM(b)
+			if err != nil {
+				return nil, fmt.Errorf("jwt: parse %sPRIVATE: %w", prefix, err)
+			}
+			private = k.(ed25519.PrivateKey)
+		}
+		if b, err := readPEM(prefix + "PUBLIC"); err != nil {
+			return nil, err
+		} else if b != nil {
+			k, err := jwt.ParseEdPublicKeyFromPEM(b)
+			if err != nil {
+				return nil, fmt.Errorf("jwt: parse %sPUBLIC: %w", prefix, err)
+			}
+			public = k.(ed25519.PublicKey)
+		}
+		if private == nil && public == nil {
+			return nil, fmt.Errorf("jwt: key %s has neither private nor public key", id)
+		}
+		return NewEdDSAKey(id, private, public), nil
+	default:
+		return nil, fmt.Errorf("jwt: unsupported algorithm %s of key %s", alg, id)
+	}
+}
+
+// readPEM 读取配置中的 PEM 文件，没有配置时返回 nil
+func readPEM(key string) ([]byte, error) {
+	path := conf.Get(key)
+	if path == "" {
+		return nil, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: read %s: %w", key, err)
+	}
+
+	return b, nil
+}
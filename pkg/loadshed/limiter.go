@@ -0,0 +1,195 @@
+package loadshed
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"nautilus/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 自适应并发限制，参考 netflix concurrency-limits 的 gradient 算法
+//
+// 每个窗口统计请求的平均耗时 shortRTT，和长期平均耗时 longRTT 比较
+//
+//	gradient = clamp(tolerance * longRTT / shortRTT, 0.5, 1)
+//	newLimit = limit * gradient + sqrt(limit)
+//	limit    = limit * (1 - smoothing) + newLimit * smoothing
+//
+// 耗时没有明显上升时 gradient 为 1，limit 按 sqrt(limit) 缓慢增长；
+// 耗时上升说明请求开始排队，limit 按比例下降，超过 limit 的请求直接拒绝，不再继续堆积
+
+// Options 限流器参数，零值使用默认值
+type Options struct {
+	// InitialLimit 初始并发数，默认 100
+	InitialLimit int
+	// MinLimit 最小并发数，默认 10
+	MinLimit int
+	// MaxLimit 最大并发数，默认 1000
+	MaxLimit int
+	// Window 统计窗口，默认 1s，窗口内请求数少于 MinSamples 时延长窗口
+	Window time.Duration
+	// MinSamples 每个窗口最少的样本数，默认 10
+	MinSamples int
+	// Tolerance 可以容忍的耗时上升比例，默认 1.5
+	Tolerance float64
+	// Smoothing limit 调整的平滑系数，默认 0.2
+	Smoothing float64
+}
+
+func (o *Options) defaults() {
+	if o.MinLimit <= 0 {
+		o.MinLimit = 10
+	}
+	if o.MaxLimit <= 0 {
+		o.MaxLimit = 1000
+	}
+	if o.InitialLimit <= 0 {
+		o.InitialLimit = 100
+	}
+	if o.InitialLimit < o.MinLimit {
+		o.InitialLimit = o.MinLimit
+	}
+	if o.InitialLimit > o.MaxLimit {
+		o.InitialLimit = o.MaxLimit
+	}
+	if o.Window <= 0 {
+		o.Window = time.Second
+	}
+	if o.MinSamples <= 0 {
+		o.MinSamples = 10
+	}
+	if o.Tolerance <= 0 {
+		o.Tolerance = 1.5
+	}
+	if o.Smoothing <= 0 || o.Smoothing > 1 {
+		o.Smoothing = 0.2
+	}
+}
+
+// Limiter 自适应并发限制
+type Limiter struct {
+	name string
+	opt  Options
+	now  func() time.Time
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	longRTT  float64
+
+	// 当前窗口的统计
+	windowStart time.Time
+	sumRTT      float64
+	samples     int
+	maxInflight int
+
+	limitGauge    prometheus.Gauge
+	inflightGauge prometheus.Gauge
+}
+
+// NewLimiter 创建并发限制器，name 用于区分监控指标
+func NewLimiter(name string, opt Options) *Limiter {
+	opt.defaults()
+
+	l := &Limiter{
+		name:          name,
+		opt:           opt,
+		now:           time.Now,
+		limit:         float64(opt.InitialLimit),
+		limitGauge:    metrics.LoadShedLimit.With(prometheus.Labels{"name": name}),
+		inflightGauge: metrics.LoadShedInflight.With(prometheus.Labels{"name": name}),
+	}
+	l.windowStart = l.now()
+	l.limitGauge.Set(l.limit)
+
+	return l
+}
+
+// Acquire 申请执行一个请求，超过并发限制时返回 false
+// 成功时必须在请求结束后调用 release，请求耗时用于调整并发限制
+//
+// 不同优先级可以使用的并发数不同：
+// Critical 不受限制，High 可以超出 limit 的 20%，Normal 为 limit，Low 只能使用 limit 的 80%
+func (l *Limiter) Acquire(p Priority) (release func(), ok bool) {
+	l.mu.Lock()
+	if p != PriorityCritical && float64(l.inflight) >= l.limit*p.share() {
+		l.mu.Unlock()
+		metrics.LoadShedDropped.With(prometheus.Labels{"name": l.name, "priority": p.String()}).Inc()
+		return nil, false
+	}
+
+	l.inflight++
+	if l.inflight > l.maxInflight {
+		l.maxInflight = l.inflight
+	}
+	l.inflightGauge.Set(float64(l.inflight))
+	l.mu.Unlock()
+
+	start := l.now()
+	var once sync.Once
+	return func() {
+		once.Do(func() { l.release(l.now().Sub(start)) })
+	}, true
+}
+
+// Limit 当前的并发限制
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return int(l.limit)
+}
+
+// Inflight 正在执行的请求数
+func (l *Limiter) Inflight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.inflight
+}
+
+func (l *Limiter) release(rtt time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inflight--
+	l.inflightGauge.Set(float64(l.inflight))
+
+	l.sumRTT += float64(rtt)
+	l.samples++
+
+	now := l.now()
+	if now.Sub(l.windowStart) < l.opt.Window || l.samples < l.opt.MinSamples {
+		return
+	}
+
+	l.update(l.sumRTT / float64(l.samples))
+	l.windowStart, l.sumRTT, l.samples, l.maxInflight = now, 0, 0, l.inflight
+}
+
+// update 根据窗口的平均耗时调整 limit
+func (l *Limiter) update(shortRTT float64) {
+	if shortRTT <= 0 {
+		return
+	}
+
+	if l.longRTT == 0 {
+		l.longRTT = shortRTT
+	} else {
+		l.longRTT = l.longRTT*0.95 + shortRTT*0.05
+	}
+
+	// 并发没有用到一半，耗时不能反映服务容量，不调整
+	if float64(l.maxInflight) < l.limit/2 {
+		return
+	}
+
+	gradient := math.Max(0.5, math.Min(1, l.opt.Tolerance*l.longRTT/shortRTT))
+	newLimit := l.limit*gradient + math.Sqrt(l.limit)
+	limit := l.limit*(1-l.opt.Smoothing) + newLimit*l.opt.Smoothing
+	l.limit = math.Max(float64(l.opt.MinLimit), math.Min(float64(l.opt.MaxLimit), limit))
+	l.limitGauge.Set(l.limit)
+}
@@ -0,0 +1,114 @@
+package loadshed
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// run 并发执行 n 个耗时为 rtt 的请求
+func run(l *Limiter, now *time.Time, n int, rtt time.Duration) {
+	releases := make([]func(), 0, n)
+	for i := 0; i < n; i++ {
+		if release, ok := l.Acquire(PriorityNormal); ok {
+			releases = append(releases, release)
+		}
+	}
+
+	*now = now.Add(rtt)
+	for _, release := range releases {
+		release()
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter("test", Options{InitialLimit: 20, MinLimit: 5, MaxLimit: 100, Window: time.Millisecond})
+	l.now = func() time.Time { return now }
+	l.windowStart = now
+
+	// 耗时稳定，并发打满时 limit 增长
+	for i := 0; i < 10; i++ {
+		run(l, &now, l.Limit(), 10*time.Millisecond)
+	}
+	grown := l.Limit()
+	assert.Greater(t, grown, 20)
+	assert.Equal(t, 0, l.Inflight())
+
+	// 耗时上升，limit 下降
+	for i := 0; i < 10; i++ {
+		run(l, &now, l.Limit(), 100*time.Millisecond)
+	}
+	assert.Less(t, l.Limit(), grown)
+
+	// 耗时持续上升时不会低于 MinLimit
+	rtt := 100 * time.Millisecond
+	for i := 0; i < 40; i++ {
+		rtt = rtt * 3 / 2
+		run(l, &now, l.Limit(), rtt)
+	}
+	assert.Equal(t, 5, l.Limit())
+}
+
+func TestLimiterAppLimited(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter("test", Options{InitialLimit: 20, Window: time.Millisecond})
+	l.now = func() time.Time { return now }
+	l.windowStart = now
+
+	// 并发没有用到一半时不调整
+	for i := 0; i < 10; i++ {
+		run(l, &now, 10, 10*time.Millisecond)
+		run(l, &now, 5, time.Second)
+	}
+	assert.Equal(t, 20, l.Limit())
+}
+
+func TestPriority(t *testing.T) {
+	l := NewLimiter("test", Options{InitialLimit: 10, MinLimit: 10})
+
+	var releases []func()
+	acquire := func(p Priority) bool {
+		release, ok := l.Acquire(p)
+		if ok {
+			releases = append(releases, release)
+		}
+		return ok
+	}
+
+	for i := 0; i < 8; i++ {
+		assert.True(t, acquire(PriorityLow))
+	}
+	assert.False(t, acquire(PriorityLow))
+
+	assert.True(t, acquire(PriorityNormal))
+	assert.True(t, acquire(PriorityNormal))
+	assert.False(t, acquire(PriorityNormal))
+
+	assert.True(t, acquire(PriorityHigh))
+	assert.True(t, acquire(PriorityHigh))
+	assert.False(t, acquire(PriorityHigh))
+
+	assert.True(t, acquire(PriorityCritical))
+	assert.Equal(t, 13, l.Inflight())
+
+	// 重复 release 只生效一次
+	releases[0]()
+	releases[0]()
+	assert.Equal(t, 12, l.Inflight())
+}
+
+func TestPrioritiesFromConf(t *testing.T) {
+	os.Setenv("LOADSHED_ROUTES_CRITICAL", "/metrics, /health")
+	os.Setenv("LOADSHED_ROUTES_LOW", "/export")
+	defer os.Unsetenv("LOADSHED_ROUTES_CRITICAL")
+	defer os.Unsetenv("LOADSHED_ROUTES_LOW")
+
+	assert.Equal(t, map[string]Priority{
+		"/metrics": PriorityCritical,
+		"/health":  PriorityCritical,
+		"/export":  PriorityLow,
+	}, PrioritiesFromConf())
+}
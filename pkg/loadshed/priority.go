@@ -0,0 +1,76 @@
+package loadshed
+
+import (
+	"fmt"
+	"strings"
+
+	"nautilus/pkg/conf"
+)
+
+// Priority 请求优先级，过载时优先拒绝低优先级的请求
+type Priority int
+
+const (
+	PriorityLow Priority = iota - 1
+	PriorityNormal
+	PriorityHigh
+	// PriorityCritical 永远不会被拒绝，例如健康检查和监控接口
+	PriorityCritical
+)
+
+var priorityNames = map[Priority]string{
+	PriorityLow:      "low",
+	PriorityNormal:   "normal",
+	PriorityHigh:     "high",
+	PriorityCritical: "critical",
+}
+
+func (p Priority) String() string {
+	if s, ok := priorityNames[p]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("Priority(%d)", int(p))
+}
+
+// share 可以使用的并发比例
+func (p Priority) share() float64 {
+	switch p {
+	case PriorityLow:
+		return 0.8
+	case PriorityHigh:
+		return 1.2
+	default:
+		return 1
+	}
+}
+
+// FromConf 从配置中读取限流器参数
+// LOADSHED_INITIAL_LIMIT/LOADSHED_MIN_LIMIT/LOADSHED_MAX_LIMIT/LOADSHED_WINDOW/LOADSHED_TOLERANCE
+func FromConf() Options {
+	return Options{
+		InitialLimit: int(conf.GetInt64("LOADSHED_INITIAL_LIMIT")),
+		MinLimit:     int(conf.GetInt64("LOADSHED_MIN_LIMIT")),
+		MaxLimit:     int(conf.GetInt64("LOADSHED_MAX_LIMIT")),
+		Window:       conf.GetDuration("LOADSHED_WINDOW"),
+		Tolerance:    conf.GetFloat64("LOADSHED_TOLERANCE"),
+	}
+}
+
+// PrioritiesFromConf 从配置中读取接口优先级，没有配置的接口为 normal
+// LOADSHED_ROUTES_${PRIORITY} 为接口路由列表，和 gin 中注册的路由一致，逗号分隔
+//
+//	LOADSHED_ROUTES_CRITICAL = "/metrics,/health"
+//	LOADSHED_ROUTES_LOW = "/api/v0/report/export"
+func PrioritiesFromConf() map[string]Priority {
+	m := map[string]Priority{}
+	for _, p := range []Priority{PriorityLow, PriorityHigh, PriorityCritical} {
+		for _, route := range conf.GetStrings("LOADSHED_ROUTES_" + strings.ToUpper(p.String())) {
+			if route = strings.TrimSpace(route); route != "" {
+				m[route] = p
+			}
+		}
+	}
+
+	return m
+}
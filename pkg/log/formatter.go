@@ -0,0 +1,64 @@
+package log
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// FormatJSON 每行一个 json，默认格式，方便日志系统解析
+	FormatJSON = "json"
+	// FormatLogfmt key=value 格式
+	FormatLogfmt = "logfmt"
+	// FormatText 本地开发使用，终端中带颜色
+	FormatText = "text"
+)
+
+// timeFormat 日志时间格式
+const timeFormat = "2006-01-02 15:04:05.000000"
+
+// fieldMap 统一字段名，和之前手动计算的 ts 字段保持一致
+var fieldMap = logrus.FieldMap{
+	logrus.FieldKeyTime:  "ts",
+	logrus.FieldKeyLevel: "level",
+	logrus.FieldKeyMsg:   "msg",
+	logrus.FieldKeyFile:  "caller",
+}
+
+// newFormatter 根据格式创建 formatter
+func newFormatter(format string) (logrus.Formatter, error) {
+	switch format {
+	case FormatJSON, "":
+		return &logrus.JSONFormatter{
+			TimestampFormat:  timeFormat,
+			FieldMap:         fieldMap,
+			CallerPrettyfier: prettyCaller,
+		}, nil
+	case FormatLogfmt:
+		return &logrus.TextFormatter{
+			DisableColors:    true,
+			FullTimestamp:    true,
+			TimestampFormat:  timeFormat,
+			QuoteEmptyFields: true,
+			FieldMap:         fieldMap,
+			CallerPrettyfier: prettyCaller,
+		}, nil
+	case FormatText:
+		return &logrus.TextFormatter{
+			FullTimestamp:    true,
+			TimestampFormat:  timeFormat,
+			CallerPrettyfier: prettyCaller,
+		}, nil
+	default:
+		return nil, fmt.Errorf("log: unknown format %q", format)
+	}
+}
+
+// prettyCaller 只输出 file:line，不输出函数名
+// 文件只保留最后一级目录，例如 middleware/logging.go:42
+func prettyCaller(f *runtime.Frame) (function string, file string) {
+	return "", fmt.Sprintf("%s/%s:%d", filepath.Base(filepath.Dir(f.File)), filepath.Base(f.File), f.Line)
+}
@@ -0,0 +1,87 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultOverrideTTL DebugTrace/DebugUID 默认的有效时间
+	defaultOverrideTTL = 10 * time.Minute
+	// maxOverrideTTL DebugTrace/DebugUID 最长的有效时间，避免忘记关闭
+	maxOverrideTTL = time.Hour
+)
+
+// Handler 运行中查看和修改日志等级的 http 接口，需要挂在内网或者管理端口上
+//
+//	GET     查看所有模块的日志等级和 DebugTrace/DebugUID
+//	PUT     ?module=sqlx&level=debug 修改模块的日志等级，module 默认为 root
+//	PUT     ?trace_id=xxx&ttl=10m 或者 ?uid=1&ttl=10m 临时打开单个请求或者用户的 debug 日志，ttl 最长 1h
+//	DELETE  删除所有的 DebugTrace/DebugUID，并重新从配置中读取日志等级
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			if err := update(r); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		case http.MethodDelete:
+			ClearOverrides()
+			Reset()
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"levels":    Levels(),
+			"overrides": Overrides(),
+		})
+	})
+}
+
+// update 按照请求参数修改日志等级或者添加 override
+func update(r *http.Request) error {
+	q := r.URL.Query()
+
+	traceID, uid := q.Get("trace_id"), q.Get("uid")
+	if traceID == "" && uid == "" {
+		module := q.Get("module")
+		if module == "" {
+			module = RootModule
+		}
+
+		return SetLevel(module, q.Get("level"))
+	}
+
+	ttl := defaultOverrideTTL
+	if v := q.Get("ttl"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		ttl = d
+	}
+	if ttl > maxOverrideTTL {
+		ttl = maxOverrideTTL
+	}
+
+	if traceID != "" {
+		DebugTrace(traceID, ttl)
+	}
+	if uid != "" {
+		id, err := strconv.ParseInt(uid, 10, 64)
+		if err != nil {
+			return err
+		}
+		DebugUID(id, ttl)
+	}
+
+	return nil
+}
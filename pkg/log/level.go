@@ -0,0 +1,232 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"nautilus/pkg/conf"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RootModule 全局 logger 的模块名，日志等级为 LOG_LEVEL
+const RootModule = "root"
+
+// defaultLevel 没有配置 LOG_LEVEL 时的日志等级
+const defaultLevel = logrus.DebugLevel
+
+// Module 命名的子 logger，日志中带有 module 字段，可以单独设置日志等级
+//
+//	var logger = log.Named("sqlx")
+//	logger.Get(ctx).Debug("...")
+type Module struct {
+	name   string
+	logger *logrus.Logger
+	// level 单独设置的日志等级，为 nil 时和 root 的日志等级一致
+	level *logrus.Level
+}
+
+var (
+	// mu 保护 modules 和当前的输出配置
+	mu      sync.Mutex
+	modules = map[string]*Module{}
+
+	// 当前的输出配置，新创建的 Module 使用相同的配置
+	curFormatter logrus.Formatter = &logrus.TextFormatter{}
+	curOutput    io.Writer
+	curCaller    bool
+)
+
+// root 全局 logger，Get 使用，在 init 中创建
+var root *Module
+
+// Named 获取命名的子 logger，同名的 Module 只会创建一次
+// 日志等级优先使用 LOG_LEVEL_${MODULE}，模块名中的 . 和 - 替换为 _，例如 LOG_LEVEL_SQLX
+// 没有配置时和 LOG_LEVEL 一致
+func Named(name string) *Module {
+	name = strings.ToLower(name)
+	if name == "" {
+		name = RootModule
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if m, ok := modules[name]; ok {
+		return m
+	}
+
+	m := newModuleLocked(name)
+	lv, err := confLevel(m.name)
+	m.level = lv
+	m.logger.SetLevel(m.effective())
+	if err != nil {
+		m.logger.Warn(err)
+	}
+
+	return m
+}
+
+// Name 模块名
+func (m *Module) Name() string {
+	return m.name
+}
+
+// Get 获取带有 ctx 信息的 logger
+// 命中 DebugTrace/DebugUID 时使用 debug 等级，不受模块日志等级的限制
+func (m *Module) Get(ctx context.Context) Logger {
+	l := m.logger
+	if !l.IsLevelEnabled(logrus.DebugLevel) && overridden(ctx) {
+		l = debugLogger
+	}
+
+	entry := l.WithContext(ctx).WithFields(fields(ctx))
+	if m != root {
+		entry = entry.WithField("module", m.name)
+	}
+
+	return entry
+}
+
+// debugLogger 命中 DebugTrace/DebugUID 的请求使用的 logger
+var debugLogger = func() *logrus.Logger {
+	l := logrus.New()
+	l.SetLevel(logrus.DebugLevel)
+	return l
+}()
+
+// SetLevel 运行中修改模块的日志等级，module 为 root 时修改全局的日志等级
+// 没有单独设置日志等级的模块跟随 root 变化；配置变更调用 Reset 之后以配置为准
+func SetLevel(module string, level string) error {
+	lv, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	m, ok := modules[strings.ToLower(module)]
+	if !ok {
+		return fmt.Errorf("log: unknown module %q", module)
+	}
+	m.level = &lv
+
+	applyLevels()
+	return nil
+}
+
+// Levels 所有模块当前的日志等级
+func Levels() map[string]string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	levels := make(map[string]string, len(modules))
+	for name, m := range modules {
+		levels[name] = m.logger.GetLevel().String()
+	}
+
+	return levels
+}
+
+// Modules 所有模块名，按字母排序
+func Modules() []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ParseLevel 解析日志等级，不支持的等级返回错误
+func ParseLevel(level string) (logrus.Level, error) {
+	lv, ok := levels[strings.ToLower(strings.TrimSpace(level))]
+	if !ok {
+		return 0, fmt.Errorf("log: unknown level %q", level)
+	}
+
+	return lv, nil
+}
+
+// Reset 重新读取 LOG_LEVEL 和 LOG_LEVEL_${MODULE}，覆盖运行中通过 SetLevel 修改的日志等级
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	invalid := map[*Module]error{}
+	for _, m := range modules {
+		lv, err := confLevel(m.name)
+		m.level = lv
+		if err != nil {
+			invalid[m] = err
+		}
+	}
+
+	applyLevels()
+	for m, err := range invalid {
+		m.logger.Warn(err)
+	}
+}
+
+// newModuleLocked 创建 Module 并使用当前的输出配置，调用方需要持有锁
+func newModuleLocked(name string) *Module {
+	l := logrus.New()
+	l.SetFormatter(curFormatter)
+	if curOutput != nil {
+		l.SetOutput(curOutput)
+	}
+	l.SetReportCaller(curCaller)
+
+	m := &Module{name: name, logger: l}
+	modules[name] = m
+	return m
+}
+
+// effective 模块实际的日志等级，调用方需要持有锁
+func (m *Module) effective() logrus.Level {
+	if m.level != nil {
+		return *m.level
+	}
+	if root != nil && root.level != nil {
+		return *root.level
+	}
+
+	return defaultLevel
+}
+
+// applyLevels 重新计算所有模块的日志等级，调用方需要持有锁
+func applyLevels() {
+	for _, m := range modules {
+		m.logger.SetLevel(m.effective())
+	}
+}
+
+// confLevel 读取模块的日志等级配置，没有配置或者配置错误时返回 nil，配置错误时同时返回 error 由调用方记录
+// 配置错误时不能使用 logrus.Level 的零值，否则会变成 panic 等级，所有日志都不输出
+func confLevel(name string) (*logrus.Level, error) {
+	key := "LOG_LEVEL"
+	if name != RootModule {
+		key = "LOG_LEVEL_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(name))
+	}
+
+	value := conf.Get(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	lv, err := ParseLevel(value)
+	if err != nil {
+		return nil, fmt.Errorf("log: invalid %s %q, ignored", key, value)
+	}
+
+	return &lv, nil
+}
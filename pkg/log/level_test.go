@@ -0,0 +1,166 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"nautilus/pkg/ctxkit"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// capture 所有模块的日志输出到 buf
+func capture(t *testing.T) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	assert.Nil(t, Setup(Options{Format: FormatLogfmt, Output: OutputNone}))
+
+	mu.Lock()
+	curOutput = buf
+	for _, m := range modules {
+		m.logger.SetOutput(buf)
+	}
+	debugLogger.SetOutput(buf)
+	mu.Unlock()
+
+	return buf
+}
+
+func TestNamed(t *testing.T) {
+	defer Setup(OptionsFromConf())
+	defer Reset()
+
+	os.Setenv("LOG_LEVEL_TEST_NAMED", "warn")
+	defer os.Unsetenv("LOG_LEVEL_TEST_NAMED")
+
+	buf := capture(t)
+	m := Named("test.named")
+	assert.Same(t, m, Named("TEST.named"))
+	assert.Same(t, root, Named(""))
+
+	m.Get(context.Background()).Info("hidden")
+	m.Get(context.Background()).Warn("shown")
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "msg=shown")
+	assert.Contains(t, buf.String(), "module=test.named")
+
+	// 运行中修改日志等级
+	assert.Nil(t, SetLevel("test.named", "info"))
+	m.Get(context.Background()).Info("info")
+	assert.Contains(t, buf.String(), "msg=info")
+	assert.Equal(t, "info", Levels()["test.named"])
+
+	// Reset 之后以配置为准
+	Reset()
+	assert.Equal(t, "warning", Levels()["test.named"])
+
+	assert.NotNil(t, SetLevel("test.named", "verbose"))
+	assert.NotNil(t, SetLevel("unknown", "info"))
+}
+
+func TestSetRootLevel(t *testing.T) {
+	defer Reset()
+
+	m := Named("test.root")
+	assert.Nil(t, SetLevel(RootModule, "error"))
+	// 没有单独设置日志等级的模块跟随 root
+	assert.Equal(t, "error", Levels()[RootModule])
+	assert.Equal(t, "error", Levels()["test.root"])
+	assert.False(t, m.Get(context.Background()).Logger.IsLevelEnabled(logrus.InfoLevel))
+}
+
+func TestInvalidConfLevel(t *testing.T) {
+	defer Setup(OptionsFromConf())
+	defer Reset()
+
+	os.Setenv("LOG_LEVEL", "verbose")
+	defer os.Unsetenv("LOG_LEVEL")
+
+	// 配置错误时使用默认的 debug，而不是 panic，错误记录在日志中
+	buf := capture(t)
+	Reset()
+	assert.Equal(t, "debug", Levels()[RootModule])
+	assert.Contains(t, buf.String(), `level=warning msg="log: invalid LOG_LEVEL \"verbose\", ignored"`)
+}
+
+func TestOverride(t *testing.T) {
+	defer Setup(OptionsFromConf())
+	defer Reset()
+	defer ClearOverrides()
+
+	buf := capture(t)
+	m := Named("test.override")
+	assert.Nil(t, SetLevel("test.override", "info"))
+
+	traced := ctxkit.WithTraceID(context.Background(), "trace-1")
+	user := ctxkit.WithUID(context.Background(), 42)
+
+	m.Get(traced).Debug("before")
+	assert.NotContains(t, buf.String(), "before")
+
+	DebugTrace("trace-1", time.Minute)
+	DebugUID(42, time.Minute)
+	DebugTrace("expired", time.Nanosecond)
+
+	m.Get(traced).Debug("by trace")
+	m.Get(user).Debug("by uid")
+	m.Get(context.Background()).Debug("others")
+	assert.Contains(t, buf.String(), `msg="by trace"`)
+	assert.Contains(t, buf.String(), `msg="by uid"`)
+	assert.Contains(t, buf.String(), "module=test.override")
+	assert.NotContains(t, buf.String(), "others")
+
+	time.Sleep(time.Millisecond)
+	list := Overrides()
+	assert.Len(t, list, 2)
+
+	ClearOverrides()
+	buf.Reset()
+	m.Get(traced).Debug("after")
+	assert.Equal(t, "", buf.String())
+}
+
+func TestHandler(t *testing.T) {
+	defer Reset()
+	defer ClearOverrides()
+
+	Named("test.handler")
+	h := Handler()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/?module=test.handler&level=error", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"test.handler":"error"`)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/?trace_id=abc&ttl=24h", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	list := Overrides()
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, "abc", list[0].TraceID)
+		// ttl 最长 1h
+		assert.True(t, time.Until(list[0].Expire) <= time.Hour)
+	}
+
+	for _, q := range []string{"module=test.handler&level=x", "uid=x", "trace_id=a&ttl=x"} {
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/?"+q, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, q)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Contains(w.Body.String(), `"overrides":[]`))
+	assert.Len(t, Overrides(), 0)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
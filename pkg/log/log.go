@@ -2,16 +2,24 @@ package log
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
 	"time"
 
 	"nautilus/pkg/conf"
 	"nautilus/pkg/ctxkit"
 
 	"github.com/sirupsen/logrus"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
-// log 全局的 log 对象
-var logger *logrus.Logger
+// closers Setup 打开的文件和 Shipper，Close 时关闭
+var (
+	closersMu sync.Mutex
+	closers   []func() error
+)
 
 // Logger logrus logger封装
 type Logger = *logrus.Entry
@@ -28,41 +36,177 @@ var levels = map[string]logrus.Level{
 	"debug": logrus.DebugLevel,
 }
 
-func init() {
-	setLevel()
+const (
+	// OutputStdout 输出到标准输出，默认
+	OutputStdout = "stdout"
+	// OutputStderr 输出到标准错误
+	OutputStderr = "stderr"
+	// OutputFile 输出到文件，按照 RotateOptions 切分
+	OutputFile = "file"
+	// OutputNone 不输出到本地，只发送到 log agent
+	OutputNone = "none"
+)
+
+// Options 日志配置
+type Options struct {
+	// Format 日志格式 json/logfmt/text
+	Format string
+	// Output 本地输出 stdout/stderr/file/none
+	Output string
+	// File Output 为 file 时的文件配置
+	File RotateOptions
+	// Agent log agent 地址，为空时不发送，参考 Shipper
+	Agent string
+	// AgentBuffer 发送到 log agent 的缓冲条数
+	AgentBuffer int
+	// Caller 输出调用日志的文件和行号
+	Caller bool
+}
+
+// OptionsFromConf 从配置中读取日志配置
+//
+//	LOG_FORMAT               日志格式 json/logfmt/text，默认 json
+//	LOG_OUTPUT               本地输出 stdout/stderr/file/none，默认 stdout
+//	LOG_FILE                 日志文件路径
+//	LOG_FILE_ROTATE          按时间切分 hour/day，为空时只按大小切分
+//	LOG_FILE_MAX_SIZE        单个文件的最大 MB，0 表示不限制
+//	LOG_FILE_MAX_AGE         备份文件保留时间，例如 168h
+//	LOG_FILE_MAX_BACKUPS     备份文件保留数量
+//	LOG_AGENT                log agent 地址，例如 udp://127.0.0.1:5140
+//	LOG_AGENT_BUFFER         发送到 log agent 的缓冲条数，默认 1024
+//	LOG_CALLER               输出调用日志的文件和行号，默认 true
+func OptionsFromConf() Options {
+	opt := Options{
+		Format: conf.Get("LOG_FORMAT"),
+		Output: conf.Get("LOG_OUTPUT"),
+		File: RotateOptions{
+			Filename:   conf.Get("LOG_FILE"),
+			Rotate:     conf.Get("LOG_FILE_ROTATE"),
+			MaxSize:    conf.GetInt64("LOG_FILE_MAX_SIZE") << 20,
+			MaxAge:     conf.GetDuration("LOG_FILE_MAX_AGE"),
+			MaxBackups: int(conf.GetInt64("LOG_FILE_MAX_BACKUPS")),
+		},
+		Agent:       conf.Get("LOG_AGENT"),
+		AgentBuffer: int(conf.GetInt64("LOG_AGENT_BUFFER")),
+		Caller:      true,
+	}
+	if conf.Get("LOG_CALLER") != "" {
+		opt.Caller = conf.GetBool("LOG_CALLER")
+	}
+
+	return opt
+}
 
-	logger = logrus.New()
+func init() {
+	root = Named(RootModule)
 
-	// TODO 如果有设置log-agent修改logrus.SetOutput()
-	if conf.Get("LOG_AGENT") != "" {
+	if err := Setup(OptionsFromConf()); err != nil {
+		root.logger.WithError(err).Error("setup log failed, use default options")
 	}
 }
 
-// setLevel 设置日志等级
-func setLevel() {
-	level := conf.Get("LOG_LEVEL")
-	if level == "" {
-		level = "debug"
+// Setup 按照配置设置全局 logger 的格式和输出，会关闭之前打开的文件和 Shipper
+func Setup(opt Options) error {
+	f, err := newFormatter(opt.Format)
+	if err != nil {
+		return err
+	}
+
+	var writers []io.Writer
+	var cs []func() error
+
+	switch opt.Output {
+	case OutputStdout, "":
+		writers = append(writers, os.Stdout)
+	case OutputStderr:
+		writers = append(writers, os.Stderr)
+	case OutputFile:
+		w, err := NewRotateWriter(opt.File)
+		if err != nil {
+			return err
+		}
+		writers = append(writers, w)
+		cs = append(cs, w.Close)
+	case OutputNone:
+	default:
+		return fmt.Errorf("log: unknown output %q", opt.Output)
 	}
 
-	logrus.SetLevel(levels[level])
+	if opt.Agent != "" {
+		s, err := NewShipper(opt.Agent, opt.AgentBuffer)
+		if err != nil {
+			for _, c := range cs {
+				c()
+			}
+			return err
+		}
+		writers = append(writers, s)
+		cs = append(cs, func() error { return s.Close(3 * time.Second) })
+	}
+
+	var out io.Writer = io.Discard
+	if len(writers) == 1 {
+		out = writers[0]
+	} else if len(writers) > 1 {
+		out = io.MultiWriter(writers...)
+	}
+
+	// logrus 的 SetFormatter/SetOutput/SetReportCaller 内部加锁，可以在运行中调用
+	mu.Lock()
+	curFormatter, curOutput, curCaller = f, out, opt.Caller
+	for _, m := range modules {
+		m.logger.SetFormatter(f)
+		m.logger.SetOutput(out)
+		m.logger.SetReportCaller(opt.Caller)
+	}
+	debugLogger.SetFormatter(f)
+	debugLogger.SetOutput(out)
+	debugLogger.SetReportCaller(opt.Caller)
+	mu.Unlock()
+
+	closersMu.Lock()
+	old := closers
+	closers = cs
+	closersMu.Unlock()
+	for _, c := range old {
+		c()
+	}
+
+	return nil
 }
 
-// Reset 重置日志等级
-func Reset() {
-	setLevel()
+// Close 关闭日志文件，并等待缓冲的日志发送到 log agent，服务退出前调用
+func Close() {
+	closersMu.Lock()
+	cs := closers
+	closers = nil
+	closersMu.Unlock()
+
+	for _, c := range cs {
+		c()
+	}
 }
 
+// Get 获取带有 ctx 信息的全局 logger
 func Get(ctx context.Context) Logger {
-	entry := logger.WithFields(logrus.Fields{
+	return root.Get(ctx)
+}
+
+// fields 从 ctx 中获取公共字段
+func fields(ctx context.Context) logrus.Fields {
+	fields := logrus.Fields{
 		"env":         conf.Env,
 		"app_id":      conf.AppID,
 		"instance_id": conf.Hostname,
 		"trace_id":    ctxkit.GetTraceID(ctx),
 		"platform":    ctxkit.GetPlatform(ctx),
 		"ip":          ctxkit.GetAccessIP(ctx),
-		"ts":          time.Now().Format(time.RFC3339Nano),
-	})
+	}
+	if sc := oteltrace.SpanContextFromContext(ctx); sc.HasSpanID() {
+		fields["span_id"] = sc.SpanID().String()
+	} else {
+		fields["span_id"] = ""
+	}
 
-	return entry
+	return fields
 }
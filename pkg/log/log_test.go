@@ -0,0 +1,65 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nautilus/pkg/ctxkit"
+
+	"github.com/stretchr/testify/assert"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestFormatter(t *testing.T) {
+	defer Setup(OptionsFromConf())
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, Setup(Options{Format: FormatJSON, Output: OutputNone, Caller: true}))
+	root.logger.SetOutput(buf)
+
+	sc := oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID: oteltrace.TraceID{1},
+		SpanID:  oteltrace.SpanID{2},
+	})
+	ctx := oteltrace.ContextWithSpanContext(ctxkit.WithTraceID(context.Background(), "trace"), sc)
+	Get(ctx).Info("hello")
+
+	var m map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &m))
+	assert.Equal(t, "hello", m["msg"])
+	assert.Equal(t, "info", m["level"])
+	assert.Equal(t, "trace", m["trace_id"])
+	assert.Equal(t, sc.SpanID().String(), m["span_id"])
+	assert.Regexp(t, `^log/log_test.go:\d+$`, m["caller"])
+	assert.Regexp(t, `^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d{6}$`, m["ts"])
+	assert.NotContains(t, m, "func")
+
+	buf.Reset()
+	assert.Nil(t, Setup(Options{Format: FormatLogfmt, Output: OutputNone}))
+	root.logger.SetOutput(buf)
+	Get(context.Background()).Info("hello world")
+	assert.Contains(t, buf.String(), `msg="hello world"`)
+	assert.Contains(t, buf.String(), `span_id=""`)
+	assert.NotContains(t, buf.String(), "caller=")
+
+	assert.NotNil(t, Setup(Options{Format: "xml"}))
+	assert.NotNil(t, Setup(Options{Output: "kafka"}))
+}
+
+func TestSetupFile(t *testing.T) {
+	defer Setup(OptionsFromConf())
+
+	filename := filepath.Join(t.TempDir(), "app.log")
+	assert.Nil(t, Setup(Options{Output: OutputFile, File: RotateOptions{Filename: filename}}))
+	Get(context.Background()).Info("to file")
+	Close()
+
+	b, err := os.ReadFile(filename)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(b), `"msg":"to file"`))
+}
@@ -0,0 +1,129 @@
+package log
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nautilus/pkg/ctxkit"
+)
+
+// Override 临时打开 debug 日志的请求，按照 trace_id 或者 uid 匹配，到期后自动失效
+type Override struct {
+	TraceID string    `json:"trace_id,omitempty"`
+	UID     int64     `json:"uid,omitempty"`
+	Expire  time.Time `json:"expire"`
+}
+
+var overrides = struct {
+	sync.RWMutex
+	// n 没有过期的 override 数量，为 0 时不需要加锁检查
+	n      int32
+	traces map[string]time.Time
+	uids   map[int64]time.Time
+}{
+	traces: map[string]time.Time{},
+	uids:   map[int64]time.Time{},
+}
+
+// DebugTrace 在 ttl 时间内打开 trace_id 对应请求的 debug 日志，用于排查单个请求的问题
+func DebugTrace(traceID string, ttl time.Duration) {
+	if traceID == "" || ttl <= 0 {
+		return
+	}
+
+	overrides.Lock()
+	defer overrides.Unlock()
+
+	overrides.traces[traceID] = time.Now().Add(ttl)
+	countOverrides()
+}
+
+// DebugUID 在 ttl 时间内打开 uid 对应用户所有请求的 debug 日志
+// uid 由 Auth 中间件写入 ctx，Auth 之前输出的日志不受影响
+func DebugUID(uid int64, ttl time.Duration) {
+	if uid == 0 || ttl <= 0 {
+		return
+	}
+
+	overrides.Lock()
+	defer overrides.Unlock()
+
+	overrides.uids[uid] = time.Now().Add(ttl)
+	countOverrides()
+}
+
+// ClearOverrides 删除所有的 DebugTrace/DebugUID
+func ClearOverrides() {
+	overrides.Lock()
+	defer overrides.Unlock()
+
+	overrides.traces = map[string]time.Time{}
+	overrides.uids = map[int64]time.Time{}
+	countOverrides()
+}
+
+// Overrides 没有过期的 DebugTrace/DebugUID，按照过期时间排序
+func Overrides() []Override {
+	overrides.Lock()
+	defer overrides.Unlock()
+
+	countOverrides()
+
+	list := make([]Override, 0, len(overrides.traces)+len(overrides.uids))
+	for id, expire := range overrides.traces {
+		list = append(list, Override{TraceID: id, Expire: expire})
+	}
+	for uid, expire := range overrides.uids {
+		list = append(list, Override{UID: uid, Expire: expire})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Expire.Before(list[j].Expire)
+	})
+
+	return list
+}
+
+// overridden ctx 对应的请求是否打开了 debug 日志
+func overridden(ctx context.Context) bool {
+	if ctx == nil || atomic.LoadInt32(&overrides.n) == 0 {
+		return false
+	}
+
+	now := time.Now()
+	overrides.RLock()
+	defer overrides.RUnlock()
+
+	if id := ctxkit.GetTraceID(ctx); id != "" {
+		if expire, ok := overrides.traces[id]; ok && now.Before(expire) {
+			return true
+		}
+	}
+	if uid := ctxkit.GetUID(ctx); uid != 0 {
+		if expire, ok := overrides.uids[uid]; ok && now.Before(expire) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// countOverrides 删除过期的 override 并更新数量，调用方需要持有写锁
+// 过期的 override 在下一次修改或者查询时才会删除，在此之前 overridden 会按照过期时间判断
+func countOverrides() {
+	now := time.Now()
+	for id, expire := range overrides.traces {
+		if !now.Before(expire) {
+			delete(overrides.traces, id)
+		}
+	}
+	for uid, expire := range overrides.uids {
+		if !now.Before(expire) {
+			delete(overrides.uids, uid)
+		}
+	}
+
+	atomic.StoreInt32(&overrides.n, int32(len(overrides.traces)+len(overrides.uids)))
+}
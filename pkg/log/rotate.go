@@ -0,0 +1,200 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// RotateHourly 每小时切分
+	RotateHourly = "hour"
+	// RotateDaily 每天切分
+	RotateDaily = "day"
+)
+
+// backupTimeFormat 备份文件名中的时间格式，例如 app.log.20220102-150405.000
+const backupTimeFormat = "20060102-150405.000"
+
+// RotateOptions 日志文件切分配置
+type RotateOptions struct {
+	// Filename 日志文件路径，备份文件为 ${Filename}.${time}
+	Filename string
+	// Rotate 按时间切分，hour/day，为空时只按大小切分
+	Rotate string
+	// MaxSize 单个文件的最大字节数，0 表示不限制
+	MaxSize int64
+	// MaxAge 备份文件保留的时间，0 表示不限制
+	MaxAge time.Duration
+	// MaxBackups 备份文件保留的数量，0 表示不限制
+	MaxBackups int
+}
+
+// RotateWriter 按照大小或者时间切分日志文件，并清理过期的备份文件
+// 切分时当前文件重命名为备份文件，再创建新文件继续写入
+type RotateWriter struct {
+	opt RotateOptions
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	period string
+
+	// now 方便测试
+	now func() time.Time
+}
+
+// NewRotateWriter 打开日志文件，目录不存在时自动创建
+func NewRotateWriter(opt RotateOptions) (*RotateWriter, error) {
+	if opt.Filename == "" {
+		return nil, fmt.Errorf("log: empty filename")
+	}
+	switch opt.Rotate {
+	case "", RotateHourly, RotateDaily:
+	default:
+		return nil, fmt.Errorf("log: unknown rotate %q", opt.Rotate)
+	}
+
+	w := &RotateWriter{opt: opt, now: time.Now}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Write 写入一条日志，超过大小或者跨越时间周期时先切分
+// 一条日志不会被拆分到两个文件
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if w.period != w.periodOf(w.now()) || (w.opt.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.opt.MaxSize) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close 关闭日志文件
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// open 打开日志文件，继续写入已有的内容，调用方需要持有锁
+func (w *RotateWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.opt.Filename), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(w.opt.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	w.file = f
+	w.size = info.Size()
+	// 已有的文件按照修改时间计算周期，重启之后跨越周期的文件也会被切分
+	w.period = w.periodOf(info.ModTime())
+	if info.Size() == 0 {
+		w.period = w.periodOf(w.now())
+	}
+
+	return nil
+}
+
+// rotate 切分文件，调用方需要持有锁
+func (w *RotateWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+
+	backup := w.opt.Filename + "." + w.now().Format(backupTimeFormat)
+	if err := os.Rename(w.opt.Filename, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	if w.opt.MaxAge > 0 || w.opt.MaxBackups > 0 {
+		go w.clean(w.now())
+	}
+
+	return nil
+}
+
+// clean 删除超过保留时间或者保留数量的备份文件
+func (w *RotateWriter) clean(now time.Time) {
+	matches, err := filepath.Glob(w.opt.Filename + ".*")
+	if err != nil {
+		return
+	}
+
+	type backup struct {
+		path string
+		t    time.Time
+	}
+
+	var backups []backup
+	prefix := w.opt.Filename + "."
+	for _, path := range matches {
+		t, err := time.ParseInLocation(backupTimeFormat, strings.TrimPrefix(path, prefix), time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: path, t: t})
+	}
+
+	// 新的在前
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].t.After(backups[j].t)
+	})
+
+	for i, b := range backups {
+		if (w.opt.MaxBackups > 0 && i >= w.opt.MaxBackups) || (w.opt.MaxAge > 0 && now.Sub(b.t) > w.opt.MaxAge) {
+			os.Remove(b.path)
+		}
+	}
+}
+
+// periodOf 时间所在的切分周期
+func (w *RotateWriter) periodOf(t time.Time) string {
+	switch w.opt.Rotate {
+	case RotateHourly:
+		return t.Format("2006010215")
+	case RotateDaily:
+		return t.Format("20060102")
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,96 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotateWriterSize(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "logs", "app.log")
+
+	w, err := NewRotateWriter(RotateOptions{Filename: filename, MaxSize: 10})
+	assert.Nil(t, err)
+	defer w.Close()
+
+	now := time.Date(2022, 1, 2, 15, 4, 5, 0, time.Local)
+	w.now = func() time.Time { return now }
+
+	_, err = w.Write([]byte("123456\n"))
+	assert.Nil(t, err)
+	// 超过大小，切分之后写入新文件
+	now = now.Add(time.Second)
+	_, err = w.Write([]byte("abcdef\n"))
+	assert.Nil(t, err)
+
+	b, _ := os.ReadFile(filename)
+	assert.Equal(t, "abcdef\n", string(b))
+	b, _ = os.ReadFile(filename + ".20220102-150406.000")
+	assert.Equal(t, "123456\n", string(b))
+
+	// 单条日志超过大小时不切分空文件
+	w2, err := NewRotateWriter(RotateOptions{Filename: filepath.Join(dir, "big.log"), MaxSize: 1})
+	assert.Nil(t, err)
+	defer w2.Close()
+	_, err = w2.Write([]byte("big\n"))
+	assert.Nil(t, err)
+	matches, _ := filepath.Glob(filepath.Join(dir, "big.log.*"))
+	assert.Len(t, matches, 0)
+}
+
+func TestRotateWriterTime(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.log")
+
+	w, err := NewRotateWriter(RotateOptions{Filename: filename, Rotate: RotateDaily})
+	assert.Nil(t, err)
+	defer w.Close()
+
+	now := time.Date(2022, 1, 2, 23, 59, 0, 0, time.Local)
+	w.now = func() time.Time { return now }
+	w.period = w.periodOf(now)
+
+	w.Write([]byte("day1\n"))
+	now = now.Add(30 * time.Second)
+	w.Write([]byte("day1\n"))
+	now = now.Add(time.Minute)
+	w.Write([]byte("day2\n"))
+
+	b, _ := os.ReadFile(filename)
+	assert.Equal(t, "day2\n", string(b))
+	b, _ = os.ReadFile(filename + ".20220103-000030.000")
+	assert.Equal(t, "day1\nday1\n", string(b))
+}
+
+func TestRotateWriterClean(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.log")
+
+	now := time.Date(2022, 1, 10, 0, 0, 0, 0, time.Local)
+	for _, d := range []int{1, 2, 3, 8, 9} {
+		name := filename + "." + now.AddDate(0, 0, -d).Format(backupTimeFormat)
+		assert.Nil(t, os.WriteFile(name, []byte("x"), 0644))
+	}
+	// 不是备份文件，不删除
+	assert.Nil(t, os.WriteFile(filename+".bak", []byte("x"), 0644))
+
+	w := &RotateWriter{opt: RotateOptions{Filename: filename, MaxAge: 7 * 24 * time.Hour, MaxBackups: 2}}
+	w.clean(now)
+
+	matches, _ := filepath.Glob(filename + ".*")
+	assert.ElementsMatch(t, []string{
+		filename + ".bak",
+		filename + "." + now.AddDate(0, 0, -1).Format(backupTimeFormat),
+		filename + "." + now.AddDate(0, 0, -2).Format(backupTimeFormat),
+	}, matches)
+}
+
+func TestNewRotateWriter(t *testing.T) {
+	_, err := NewRotateWriter(RotateOptions{})
+	assert.NotNil(t, err)
+	_, err = NewRotateWriter(RotateOptions{Filename: filepath.Join(t.TempDir(), "app.log"), Rotate: "week"})
+	assert.NotNil(t, err)
+}
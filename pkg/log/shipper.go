@@ -0,0 +1,185 @@
+package log
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nautilus/pkg/metrics"
+)
+
+const (
+	// DropBufferFull 缓冲区满了，日志被丢弃
+	DropBufferFull = "buffer_full"
+	// DropWriteError 写入 agent 失败，日志被丢弃
+	DropWriteError = "write_error"
+)
+
+// defaultShipperBuffer 默认缓冲的日志条数
+const defaultShipperBuffer = 1024
+
+// Shipper 异步发送日志到本地的 log agent，例如 fluent-bit/vector/filebeat
+// 写入只是放到缓冲区，不会阻塞业务；缓冲区满了或者发送失败时丢弃日志，并记录到 LogDropped
+// 支持 udp://127.0.0.1:5140、tcp://127.0.0.1:5140、unix:///var/run/agent.sock
+// udp 时每条日志一个包，需要保证日志长度不超过 agent 的限制
+type Shipper struct {
+	network string
+	addr    string
+
+	ch   chan []byte
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	closeOnce sync.Once
+	dropped   uint64
+
+	// dial 方便测试
+	dial func(network, addr string) (net.Conn, error)
+}
+
+// NewShipper 创建 Shipper 并启动发送协程，buffer 为缓冲的日志条数，0 时默认 1024
+// 连接在发送时建立，agent 没有启动不影响服务启动
+func NewShipper(rawURL string, buffer int) (*Shipper, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Shipper{network: u.Scheme, addr: u.Host}
+	switch u.Scheme {
+	case "udp", "tcp":
+	case "unix", "unixgram":
+		s.addr = u.Path
+	default:
+		return nil, fmt.Errorf("log: unsupported agent %q", rawURL)
+	}
+	if s.addr == "" {
+		return nil, fmt.Errorf("log: empty agent address %q", rawURL)
+	}
+
+	if buffer <= 0 {
+		buffer = defaultShipperBuffer
+	}
+	s.ch = make(chan []byte, buffer)
+	s.done = make(chan struct{})
+	s.dial = func(network, addr string) (net.Conn, error) {
+		return net.DialTimeout(network, addr, time.Second)
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s, nil
+}
+
+// Write 把日志放到缓冲区，logrus 每次写入一条完整的日志
+// 总是返回成功，避免 logrus 在发送失败时输出错误
+func (s *Shipper) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	copy(b, p)
+
+	select {
+	case <-s.done:
+		s.drop(DropWriteError)
+	default:
+		select {
+		case s.ch <- b:
+		default:
+			s.drop(DropBufferFull)
+		}
+	}
+
+	return len(p), nil
+}
+
+// Dropped 被丢弃的日志条数
+func (s *Shipper) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close 停止接收日志，等待缓冲区中的日志发送完成，最多等待 timeout
+func (s *Shipper) Close(timeout time.Duration) error {
+	s.closeOnce.Do(func() { close(s.done) })
+
+	finished := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("log: shipper close timeout, %d logs pending", len(s.ch))
+	}
+}
+
+// run 发送协程，连接断开时重连，重连间隔从 100ms 开始指数增长，最长 5s
+func (s *Shipper) run() {
+	defer s.wg.Done()
+
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	backoff := 100 * time.Millisecond
+	var retryAt time.Time
+
+	send := func(b []byte) {
+		if conn == nil {
+			if time.Now().Before(retryAt) {
+				s.drop(DropWriteError)
+				return
+			}
+
+			c, err := s.dial(s.network, s.addr)
+			if err != nil {
+				retryAt = time.Now().Add(backoff)
+				if backoff *= 2; backoff > 5*time.Second {
+					backoff = 5 * time.Second
+				}
+				s.drop(DropWriteError)
+				return
+			}
+			conn = c
+			backoff = 100 * time.Millisecond
+		}
+
+		_ = conn.SetWriteDeadline(time.Now().Add(time.Second))
+		if _, err := conn.Write(b); err != nil {
+			conn.Close()
+			conn = nil
+			s.drop(DropWriteError)
+		}
+	}
+
+	for {
+		select {
+		case b := <-s.ch:
+			send(b)
+		case <-s.done:
+			// 发送缓冲区中剩余的日志
+			for {
+				select {
+				case b := <-s.ch:
+					send(b)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// drop 记录被丢弃的日志
+func (s *Shipper) drop(reason string) {
+	atomic.AddUint64(&s.dropped, 1)
+	metrics.LogDropped.WithLabelValues(reason).Inc()
+}
@@ -0,0 +1,87 @@
+package log
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShipperUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer pc.Close()
+
+	s, err := NewShipper("udp://"+pc.LocalAddr().String(), 10)
+	assert.Nil(t, err)
+
+	s.Write([]byte(`{"msg":"hello"}` + "\n"))
+
+	buf := make([]byte, 1024)
+	_ = pc.SetReadDeadline(time.Now().Add(3 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"msg":"hello"}`+"\n", string(buf[:n]))
+
+	assert.Nil(t, s.Close(time.Second))
+	assert.Equal(t, uint64(0), s.Dropped())
+}
+
+func TestShipperTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+
+	s, err := NewShipper("tcp://"+ln.Addr().String(), 10)
+	assert.Nil(t, err)
+
+	for i := 0; i < 3; i++ {
+		s.Write([]byte("line\n"))
+	}
+	// Close 时发送完缓冲区中的日志
+	assert.Nil(t, s.Close(3*time.Second))
+
+	conn, err := ln.Accept()
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	r := bufio.NewScanner(conn)
+	var lines int
+	for r.Scan() {
+		lines++
+	}
+	assert.Equal(t, 3, lines)
+}
+
+func TestShipperDrop(t *testing.T) {
+	s, err := NewShipper("tcp://127.0.0.1:1", 1)
+	assert.Nil(t, err)
+
+	block := make(chan struct{})
+	s.dial = func(network, addr string) (net.Conn, error) {
+		<-block
+		return nil, errors.New("refused")
+	}
+
+	// 发送协程阻塞在 dial，缓冲区只能放一条
+	for i := 0; i < 5; i++ {
+		n, err := s.Write([]byte("line\n"))
+		assert.Nil(t, err)
+		assert.Equal(t, 5, n)
+	}
+	close(block)
+	assert.Nil(t, s.Close(time.Second))
+
+	// 3 或者 4 条因为缓冲区满了被丢弃，其余的因为连接失败被丢弃
+	assert.Equal(t, uint64(5), s.Dropped())
+}
+
+func TestNewShipper(t *testing.T) {
+	for _, u := range []string{"http://127.0.0.1:80", "udp://", "unix://"} {
+		_, err := NewShipper(u, 0)
+		assert.NotNil(t, err, u)
+	}
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"sync"
+
+	"nautilus/pkg/conf"
+)
+
+const (
+	// Unmatched 没有匹配到路由的请求，例如 404、405
+	Unmatched = "unmatched"
+
+	// Overflow label 值的数量超过上限之后统一使用的值
+	Overflow = "other"
+)
+
+// defaultMaxLabelValues 每个指标 label 值数量的默认上限
+const defaultMaxLabelValues = 500
+
+// LabelGuard 限制一个指标 label 值的数量，避免 prometheus 序列数量爆炸
+// 前 max 个出现的值原样返回，之后新出现的值都返回 Overflow，并记录到 MetricsLabelDropped
+type LabelGuard struct {
+	metric string
+	max    int
+
+	mu     sync.RWMutex
+	values map[string]struct{}
+}
+
+// NewLabelGuard 创建 LabelGuard，metric 为指标名，用于统计被丢弃的序列
+// max 为 0 时读取配置 METRICS_MAX_LABEL_VALUES，默认 500
+func NewLabelGuard(metric string, max int) *LabelGuard {
+	if max <= 0 {
+		max = int(conf.GetInt64("METRICS_MAX_LABEL_VALUES"))
+	}
+	if max <= 0 {
+		max = defaultMaxLabelValues
+	}
+
+	return &LabelGuard{
+		metric: metric,
+		max:    max,
+		values: map[string]struct{}{},
+	}
+}
+
+// Value 返回可以作为 label 的值
+func (g *LabelGuard) Value(v string) string {
+	g.mu.RLock()
+	_, ok := g.values[v]
+	g.mu.RUnlock()
+	if ok {
+		return v
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.values[v]; ok {
+		return v
+	}
+	if len(g.values) >= g.max {
+		MetricsLabelDropped.WithLabelValues(g.metric).Inc()
+		return Overflow
+	}
+
+	g.values[v] = struct{}{}
+	return v
+}
+
+// Len 已经记录的 label 值数量
+func (g *LabelGuard) Len() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return len(g.values)
+}
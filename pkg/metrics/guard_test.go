@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelGuard(t *testing.T) {
+	g := NewLabelGuard("test_guard", 2)
+	dropped := MetricsLabelDropped.WithLabelValues("test_guard")
+
+	assert.Equal(t, "/a", g.Value("/a"))
+	assert.Equal(t, "/b", g.Value("/b"))
+	assert.Equal(t, Overflow, g.Value("/c"))
+	assert.Equal(t, Overflow, g.Value("/d"))
+	// 已经记录的值不受上限影响
+	assert.Equal(t, "/a", g.Value("/a"))
+	assert.Equal(t, 2, g.Len())
+	assert.Equal(t, float64(2), testutil.ToFloat64(dropped))
+}
+
+func TestLabelGuardConcurrent(t *testing.T) {
+	g := NewLabelGuard("test_guard_concurrent", 10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			g.Value(fmt.Sprint(i))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, g.Len())
+	assert.Equal(t, float64(90), testutil.ToFloat64(MetricsLabelDropped.WithLabelValues("test_guard_concurrent")))
+}
+
+func TestNewLabelGuardDefault(t *testing.T) {
+	assert.Equal(t, defaultMaxLabelValues, NewLabelGuard("test_guard_default", 0).max)
+}
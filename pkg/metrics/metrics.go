@@ -34,7 +34,7 @@ var (
 	// HTTPDurationSeconds http 调用耗时
 	HTTPDurationSeconds *prometheus.HistogramVec
 
-	// GRPCDurationSeconds grpc 调用耗时
+	// GRPCDurationSeconds grpc 调用耗时，side 为 server/client
 	GRPCDurationSeconds *prometheus.HistogramVec
 
 	// DBMaxOpenConnections 最大DB连接数
@@ -58,6 +58,39 @@ var (
 	// DBMaxLifetimeClosed 因为 SetMaxLifetimeClosed 而被关闭的连接总数
 	DBMaxLifetimeClosed *prometheus.CounterVec
 
+	// RateLimitCount 限流结果统计，result 为 allowed/limited/error
+	RateLimitCount *prometheus.CounterVec
+
+	// LoadShedLimit 自适应并发限制的当前 limit
+	LoadShedLimit *prometheus.GaugeVec
+
+	// LoadShedInflight 正在执行的请求数
+	LoadShedInflight *prometheus.GaugeVec
+
+	// LoadShedDropped 因为过载被拒绝的请求数
+	LoadShedDropped *prometheus.CounterVec
+
+	// PanicCount panic 次数，kind 为 http/grpc/goroutine
+	PanicCount *prometheus.CounterVec
+
+	// MetricsLabelDropped label 值数量超过上限被合并到 other 的次数，参考 LabelGuard
+	MetricsLabelDropped *prometheus.CounterVec
+
+	// LogDropped 发送到 log agent 时被丢弃的日志条数，reason 为 buffer_full/write_error
+	LogDropped *prometheus.CounterVec
+
+	// HealthCheckUp 依赖检查结果，1 为正常，0 为失败
+	HealthCheckUp *prometheus.GaugeVec
+
+	// HTTPRetryCount 调用下游 http 服务的重试次数，kind 为 retry/hedge
+	HTTPRetryCount *prometheus.CounterVec
+
+	// HTTPBreakerState 下游 http 服务的熔断器状态，0 关闭，1 半开，2 打开
+	HTTPBreakerState *prometheus.GaugeVec
+
+	// HTTPBreakerRejected 熔断器打开时被拒绝的请求数
+	HTTPBreakerRejected *prometheus.CounterVec
+
 	// TODO goroutine num / GC
 )
 
@@ -65,13 +98,14 @@ var (
 var buckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
 
 func init() {
-	// sum(rate(nautilus_rpc_qps_count [1m])) by (path)
+	// route 为 gin 路由模板，例如 /v1/author/:author_id/articles，没有匹配到路由时为 unmatched
+	// sum(rate(nautilus_rpc_qps_count [1m])) by (route)
 	RPCQPSCount = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace:   "nautilus",
 		Name:        "rpc_qps_count",
 		Help:        "RPC QPS count",
 		ConstLabels: map[string]string{"app": conf.AppID, "env": conf.Env},
-	}, []string{"path", "code"})
+	}, []string{"method", "route", "code"})
 	prometheus.MustRegister(RPCQPSCount)
 
 	RPCDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
@@ -80,7 +114,7 @@ func init() {
 		Help:        "RPC latency distributions",
 		Buckets:     buckets,
 		ConstLabels: map[string]string{"app": conf.AppID, "env": conf.Env},
-	}, []string{"path", "code"})
+	}, []string{"method", "route", "code"})
 	prometheus.MustRegister(RPCDurationSeconds)
 
 	DBDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
@@ -110,13 +144,15 @@ func init() {
 	}, []string{"url", "status"})
 	prometheus.MustRegister(HTTPDurationSeconds)
 
+	// server 和 client 拦截器都会记录，需要按 side 区分，否则同一个方法的耗时会叠加
+	// histogram_quantile(0.99, sum(rate(nautilus_grpc_duration_seconds_bucket{side="server"} [1m])) by (le, service))
 	GRPCDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Namespace:   "nautilus",
 		Name:        "grpc_duration_seconds",
 		Help:        "GRPC latency distributions",
 		Buckets:     buckets,
 		ConstLabels: map[string]string{"app": conf.AppID},
-	}, []string{"service", "status"})
+	}, []string{"side", "service", "status"})
 	prometheus.MustRegister(GRPCDurationSeconds)
 
 	DBMaxOpenConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
@@ -174,4 +210,100 @@ func init() {
 		ConstLabels: map[string]string{"app": conf.AppID},
 	}, []string{"name"})
 	prometheus.MustRegister(DBMaxLifetimeClosed)
+
+	// sum(rate(nautilus_ratelimit_count{result="limited"} [1m])) by (policy)
+	RateLimitCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "nautilus",
+		Name:        "ratelimit_count",
+		Help:        "rate limit results",
+		ConstLabels: map[string]string{"app": conf.AppID, "env": conf.Env},
+	}, []string{"policy", "result"})
+	prometheus.MustRegister(RateLimitCount)
+
+	LoadShedLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   "nautilus",
+		Name:        "loadshed_limit",
+		Help:        "adaptive concurrency limit",
+		ConstLabels: map[string]string{"app": conf.AppID, "env": conf.Env},
+	}, []string{"name"})
+	prometheus.MustRegister(LoadShedLimit)
+
+	LoadShedInflight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   "nautilus",
+		Name:        "loadshed_inflight",
+		Help:        "inflight requests",
+		ConstLabels: map[string]string{"app": conf.AppID, "env": conf.Env},
+	}, []string{"name"})
+	prometheus.MustRegister(LoadShedInflight)
+
+	LoadShedDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "nautilus",
+		Name:        "loadshed_dropped_count",
+		Help:        "requests dropped by load shedding",
+		ConstLabels: map[string]string{"app": conf.AppID, "env": conf.Env},
+	}, []string{"name", "priority"})
+	prometheus.MustRegister(LoadShedDropped)
+
+	// 告警: sum(increase(nautilus_panic_count [5m])) by (app, kind) > 0
+	PanicCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "nautilus",
+		Name:        "panic_count",
+		Help:        "recovered panics",
+		ConstLabels: map[string]string{"app": conf.AppID, "env": conf.Env},
+	}, []string{"kind"})
+	prometheus.MustRegister(PanicCount)
+
+	// 告警: sum(increase(nautilus_metrics_label_dropped_count [5m])) by (app, metric) > 0
+	MetricsLabelDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "nautilus",
+		Name:        "metrics_label_dropped_count",
+		Help:        "label values dropped by cardinality guard",
+		ConstLabels: map[string]string{"app": conf.AppID, "env": conf.Env},
+	}, []string{"metric"})
+	prometheus.MustRegister(MetricsLabelDropped)
+
+	// 告警: sum(increase(nautilus_log_dropped_count [5m])) by (app, reason) > 0
+	LogDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "nautilus",
+		Name:        "log_dropped_count",
+		Help:        "logs dropped by log agent shipper",
+		ConstLabels: map[string]string{"app": conf.AppID, "env": conf.Env},
+	}, []string{"reason"})
+	prometheus.MustRegister(LogDropped)
+
+	// 告警: min(nautilus_health_check_up) by (app, name) == 0
+	HealthCheckUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   "nautilus",
+		Name:        "health_check_up",
+		Help:        "dependency health check result",
+		ConstLabels: map[string]string{"app": conf.AppID, "env": conf.Env},
+	}, []string{"name"})
+	prometheus.MustRegister(HealthCheckUp)
+
+	// sum(rate(nautilus_http_retry_count [1m])) by (service, kind)
+	HTTPRetryCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "nautilus",
+		Name:        "http_retry_count",
+		Help:        "outbound http retries and hedged requests",
+		ConstLabels: map[string]string{"app": conf.AppID, "env": conf.Env},
+	}, []string{"service", "kind"})
+	prometheus.MustRegister(HTTPRetryCount)
+
+	// 告警: max(nautilus_http_breaker_state) by (app, service) == 2
+	HTTPBreakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   "nautilus",
+		Name:        "http_breaker_state",
+		Help:        "outbound http circuit breaker state, 0 closed, 1 half-open, 2 open",
+		ConstLabels: map[string]string{"app": conf.AppID, "env": conf.Env},
+	}, []string{"service"})
+	prometheus.MustRegister(HTTPBreakerState)
+
+	// sum(rate(nautilus_http_breaker_rejected_count [1m])) by (service)
+	HTTPBreakerRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "nautilus",
+		Name:        "http_breaker_rejected_count",
+		Help:        "outbound http requests rejected by circuit breaker",
+		ConstLabels: map[string]string{"app": conf.AppID, "env": conf.Env},
+	}, []string{"service"})
+	prometheus.MustRegister(HTTPBreakerRejected)
 }
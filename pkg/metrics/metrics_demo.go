@@ -72,8 +72,8 @@ func _demo() {
 
 	// nautilus_rpc_qps_count{app_id="nautilus.server",code="200",env="prod",path="/api/user.v1.info/order"} 1
 	// nautilus_rpc_qps_count{app_id="nautilus.server",code="200",env="prod",path="/api/user.v1.info/profile"} 1
-	RPCQPSCount.With(prometheus.Labels{"path": "/api/user.v1.info/profile", "code": "200"}).Inc()
-	RPCQPSCount.With(prometheus.Labels{"path": "/api/user.v1.info/order", "code": "200"}).Inc()
+	RPCQPSCountDemo.With(prometheus.Labels{"path": "/api/user.v1.info/profile", "code": "200"}).Inc()
+	RPCQPSCountDemo.With(prometheus.Labels{"path": "/api/user.v1.info/order", "code": "200"}).Inc()
 
 	//nautilus_rpc_duration_seconds_bucket{app_id="nautilus.server",code="200",env="prod",path="/api/user.v1.info/profile",le="0.005"} 7
 	//nautilus_rpc_duration_seconds_bucket{app_id="nautilus.server",code="200",env="prod",path="/api/user.v1.info/profile",le="0.01"} 14
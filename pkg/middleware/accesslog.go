@@ -0,0 +1,294 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"mime"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"nautilus/pkg/conf"
+	"nautilus/pkg/ctxkit"
+	"nautilus/pkg/log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redacted 脱敏后的值
+const redacted = "***"
+
+// defaultRedactFields 默认脱敏的字段
+var defaultRedactFields = []string{"password", "phone", "token", "access_token", "refresh_token", "secret"}
+
+// AccessLogConfig 访问日志配置
+type AccessLogConfig struct {
+	// Sample 默认采样率，0~1
+	Sample float64
+	// Routes 单个接口的采样率，key 为 gin 路由
+	Routes map[string]float64
+	// BodyLimit 请求和响应 body 最多记录的字节数，超出部分截断，0 表示不记录 body
+	BodyLimit int
+	// Redact 需要脱敏的字段，不区分大小写，作用于 JSON/form body 和 query
+	Redact []string
+}
+
+// AccessLogConfigFromConf 从配置中读取访问日志配置
+//
+//	ACCESS_LOG_SAMPLE         默认采样率，默认 1
+//	ACCESS_LOG_SAMPLE_ROUTES  单个接口的采样率，格式为 ${route}=${rate}，逗号分隔
+//	ACCESS_LOG_BODY_LIMIT     body 最多记录的字节数，默认 1024
+//	ACCESS_LOG_REDACT         脱敏字段，默认 password,phone,token,access_token,refresh_token,secret
+func AccessLogConfigFromConf() AccessLogConfig {
+	cfg := AccessLogConfig{
+		Sample:    1,
+		Routes:    map[string]float64{},
+		BodyLimit: 1024,
+		Redact:    defaultRedactFields,
+	}
+
+	if conf.Get("ACCESS_LOG_SAMPLE") != "" {
+		cfg.Sample = conf.GetFloat64("ACCESS_LOG_SAMPLE")
+	}
+	if conf.Get("ACCESS_LOG_BODY_LIMIT") != "" {
+		cfg.BodyLimit = int(conf.GetInt64("ACCESS_LOG_BODY_LIMIT"))
+	}
+	if fields := conf.GetStrings("ACCESS_LOG_REDACT"); len(fields) > 0 {
+		cfg.Redact = fields
+	}
+
+	for _, v := range conf.GetStrings("ACCESS_LOG_SAMPLE_ROUTES") {
+		kv := strings.SplitN(strings.TrimSpace(v), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		rate, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			continue
+		}
+		cfg.Routes[strings.TrimSpace(kv[0])] = rate
+	}
+
+	return cfg
+}
+
+// AccessLog 访问日志中间件，按接口采样记录请求和响应
+// 日志消息固定为 access，字段固定为
+//
+//	log_type method route path query status cost req_size resp_size user_agent uid req_body resp_body
+//
+// 没有值的字段也会输出，方便日志系统按照固定的结构解析
+// 5xx 的请求不受采样率限制，但是只有被采样的请求才记录 body；只记录 JSON/form/text 类型的 body
+// 需要放在 NewTraceID 之后、Recovery 之前，日志中才有 trace_id，并且能记录到 panic 返回的 500
+//
+//	router.Use(middleware.NewTraceID())
+//	router.Use(middleware.AccessLog(middleware.AccessLogConfigFromConf()))
+//	router.Use(middleware.Recovery())
+func AccessLog(cfg AccessLogConfig) gin.HandlerFunc {
+	redactor := newRedactor(cfg.Redact)
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		route := c.FullPath()
+
+		rate, ok := cfg.Routes[route]
+		if !ok {
+			rate = cfg.Sample
+		}
+		sampled := rate > 0 && (rate >= 1 || rand.Float64() < rate)
+
+		var reqBody *bodyReader
+		var respBody *bodyWriter
+		if sampled && cfg.BodyLimit > 0 {
+			if c.Request.Body != nil {
+				reqBody = &bodyReader{ReadCloser: c.Request.Body, limit: cfg.BodyLimit}
+				c.Request.Body = reqBody
+			}
+
+			respBody = &bodyWriter{ResponseWriter: c.Writer, limit: cfg.BodyLimit}
+			c.Writer = respBody
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if !sampled && status < 500 {
+			return
+		}
+
+		ctx := c.Request.Context()
+		reqSize := c.Request.ContentLength
+		if reqBody != nil && reqBody.n > reqSize {
+			reqSize = reqBody.n
+		}
+		respSize := c.Writer.Size()
+		if respSize < 0 {
+			respSize = 0
+		}
+
+		fields := log.Fields{
+			"log_type":   "access",
+			"method":     c.Request.Method,
+			"route":      route,
+			"path":       c.Request.URL.Path,
+			"query":      redactor.query(c.Request.URL.RawQuery),
+			"status":     status,
+			"cost":       time.Since(start).Seconds(),
+			"req_size":   reqSize,
+			"resp_size":  respSize,
+			"user_agent": c.Request.UserAgent(),
+			"uid":        ctxkit.GetUID(ctx),
+			"req_body":   "",
+			"resp_body":  "",
+		}
+		if reqBody != nil {
+			fields["req_body"] = redactor.body(c.ContentType(), reqBody.buf.Bytes(), reqBody.truncated)
+		}
+		if respBody != nil {
+			c.Writer = respBody.ResponseWriter
+			fields["resp_body"] = redactor.body(contentType(c.Writer.Header().Get("Content-Type")), respBody.buf.Bytes(), respBody.truncated)
+		}
+
+		log.Get(ctx).WithFields(fields).Info("access")
+	}
+}
+
+// bodyReader 记录 handler 读取的请求 body 的前 limit 个字节，不影响 handler 读取
+type bodyReader struct {
+	io.ReadCloser
+	limit     int
+	buf       bytes.Buffer
+	n         int64
+	truncated bool
+}
+
+func (r *bodyReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	r.truncated = r.truncated || capture(&r.buf, p[:n], r.limit)
+	return n, err
+}
+
+// bodyWriter 记录响应 body 的前 limit 个字节
+type bodyWriter struct {
+	gin.ResponseWriter
+	limit     int
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (w *bodyWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.truncated = w.truncated || capture(&w.buf, b[:n], w.limit)
+	return n, err
+}
+
+func (w *bodyWriter) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	w.truncated = w.truncated || capture(&w.buf, []byte(s[:n]), w.limit)
+	return n, err
+}
+
+// capture 最多写入 limit 个字节，有内容被丢弃时返回 true
+func capture(buf *bytes.Buffer, b []byte, limit int) bool {
+	left := limit - buf.Len()
+	if left >= len(b) {
+		buf.Write(b)
+		return false
+	}
+
+	if left > 0 {
+		buf.Write(b[:left])
+	}
+	return true
+}
+
+// redactor 按照字段名脱敏
+type redactor struct {
+	fields map[string]bool
+	// json 匹配 JSON 中的 "field": value，body 被截断时也能脱敏
+	json *regexp.Regexp
+}
+
+func newRedactor(fields []string) *redactor {
+	r := &redactor{fields: map[string]bool{}}
+
+	var names []string
+	for _, f := range fields {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" {
+			continue
+		}
+		r.fields[f] = true
+		names = append(names, regexp.QuoteMeta(f))
+	}
+
+	if len(names) > 0 {
+		r.json = regexp.MustCompile(`(?i)("(?:` + strings.Join(names, "|") + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+	}
+
+	return r
+}
+
+// body 按照 Content-Type 脱敏，其他类型的 body 不记录
+func (r *redactor) body(contentType string, b []byte, truncated bool) string {
+	if len(b) == 0 {
+		return ""
+	}
+
+	var s string
+	switch {
+	case strings.Contains(contentType, "json"):
+		s = string(b)
+		if r.json != nil {
+			s = r.json.ReplaceAllString(s, `${1}"`+redacted+`"`)
+		}
+	case contentType == gin.MIMEPOSTForm:
+		s = r.query(string(b))
+	case strings.HasPrefix(contentType, "text/"):
+		s = string(b)
+	default:
+		return ""
+	}
+
+	if truncated {
+		s += "...(truncated)"
+	}
+	return s
+}
+
+// query 脱敏 a=1&password=2 格式的参数，保持参数顺序
+func (r *redactor) query(raw string) string {
+	if raw == "" || len(r.fields) == 0 {
+		return raw
+	}
+
+	parts := strings.Split(raw, "&")
+	for i, p := range parts {
+		kv := strings.SplitN(p, "=", 2)
+		key, err := url.QueryUnescape(kv[0])
+		if err != nil {
+			key = kv[0]
+		}
+
+		if len(kv) == 2 && r.fields[strings.ToLower(key)] {
+			parts[i] = kv[0] + "=" + redacted
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// contentType 去掉 Content-Type 中的参数，例如 charset
+func contentType(v string) string {
+	t, _, err := mime.ParseMediaType(v)
+	if err != nil {
+		return v
+	}
+
+	return t
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"nautilus/pkg/log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactor(t *testing.T) {
+	r := newRedactor([]string{"password", "Phone", "token"})
+
+	assert.Equal(t, `{"name":"a","password":"***","Phone":"***","n":{"token":"***"},"age":1}`,
+		r.body("application/json", []byte(`{"name":"a","password":"p\"1","Phone":13800000000,"n":{"token":"t"},"age":1}`), false))
+	// 截断的 JSON 也能脱敏
+	assert.Equal(t, `{"name":"a","password":"***"...(truncated)`, r.body("application/json", []byte(`{"name":"a","password":"abc`), true))
+	assert.Equal(t, "name=a&password=***", r.body(gin.MIMEPOSTForm, []byte("name=a&password=p"), false))
+	assert.Equal(t, "plain", r.body("text/plain", []byte("plain"), false))
+	assert.Equal(t, "", r.body("application/octet-stream", []byte{1, 2}, false))
+	assert.Equal(t, "a=1&token=***&b", r.query("a=1&token=t&b"))
+}
+
+func TestAccessLog(t *testing.T) {
+	hook := test.NewLocal(log.Get(context.Background()).Logger)
+	defer hook.Reset()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(AccessLog(AccessLogConfig{
+		Sample:    1,
+		Routes:    map[string]float64{"/skip/:id": 0},
+		BodyLimit: 64,
+		Redact:    []string{"password", "token"},
+	}))
+	r.POST("/login/:id", func(c *gin.Context) {
+		var req map[string]interface{}
+		_ = c.BindJSON(&req)
+		c.JSON(http.StatusOK, gin.H{"token": "secret", "name": req["name"]})
+	})
+	r.GET("/skip/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/fail/:id", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+
+	body := `{"name":"nautilus","password":"123456"}`
+	req := httptest.NewRequest(http.MethodPost, "/login/1?password=x&a=1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "test")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.JSONEq(t, `{"token":"secret","name":"nautilus"}`, w.Body.String())
+
+	entries := accessEntries(hook)
+	if assert.Len(t, entries, 1) {
+		e := entries[0]
+		assert.Equal(t, "POST", e["method"])
+		assert.Equal(t, "/login/:id", e["route"])
+		assert.Equal(t, "/login/1", e["path"])
+		assert.Equal(t, "password=***&a=1", e["query"])
+		assert.Equal(t, http.StatusOK, e["status"])
+		assert.Equal(t, int64(len(body)), e["req_size"])
+		assert.Equal(t, w.Body.Len(), e["resp_size"])
+		assert.Equal(t, "test", e["user_agent"])
+		assert.Equal(t, int64(0), e["uid"])
+		assert.Equal(t, `{"name":"nautilus","password":"***"}`, e["req_body"])
+		assert.Equal(t, `{"name":"nautilus","token":"***"}`, e["resp_body"])
+	}
+
+	// 接口采样率为 0 时不记录
+	hook.Reset()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/skip/1", nil))
+	assert.Len(t, accessEntries(hook), 0)
+
+	// body 截断
+	hook.Reset()
+	long := `{"name":"` + strings.Repeat("a", 100) + `"}`
+	req = httptest.NewRequest(http.MethodPost, "/login/1", bytes.NewBufferString(long))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	entries = accessEntries(hook)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, long[:64]+"...(truncated)", entries[0]["req_body"])
+		assert.Equal(t, int64(len(long)), entries[0]["req_size"])
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	hook := test.NewLocal(log.Get(context.Background()).Logger)
+	defer hook.Reset()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(AccessLog(AccessLogConfig{Sample: 0, BodyLimit: 64}))
+	r.GET("/ok", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	r.GET("/fail", func(c *gin.Context) { c.String(http.StatusInternalServerError, "fail") })
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+	assert.Len(t, accessEntries(hook), 0)
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+	entries := accessEntries(hook)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, http.StatusInternalServerError, entries[0]["status"])
+		assert.Equal(t, "", entries[0]["resp_body"])
+	}
+
+	// 字段固定，方便日志系统解析
+	b, err := json.Marshal(entries[0])
+	assert.Nil(t, err)
+	for _, k := range []string{"log_type", "method", "route", "path", "query", "status", "cost", "req_size",
+		"resp_size", "user_agent", "uid", "req_body", "resp_body"} {
+		assert.Contains(t, string(b), `"`+k+`"`)
+	}
+}
+
+func accessEntries(hook *test.Hook) (entries []logrus.Fields) {
+	for _, e := range hook.AllEntries() {
+		if e.Message == "access" {
+			entries = append(entries, e.Data)
+		}
+	}
+	return
+}
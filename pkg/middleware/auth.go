@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"nautilus/pkg/jwt"
+	"nautilus/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Revoked 判断登录会话是否已经注销
+type Revoked = jwt.Revoked
+
+// Auth jwt 鉴权中间件
+// 校验 Authorization: Bearer ${access_token}，把 uid、角色和会话 id 写入 ctxkit
+// revoked 为 nil 时不检查 token 是否已经注销
+//
+//	r.Use(middleware.Auth(admin.IsRevoked))
+//	uid := ctxkit.GetUID(ctx)
+func Auth(revoked Revoked) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authenticate(c, revoked) {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// authenticate 校验 token，失败时返回错误并终止请求
+func authenticate(c *gin.Context, revoked Revoked) bool {
+	token := jwt.BearerToken(c.GetHeader("Authorization"))
+	ctx, err := jwt.Authenticate(c.Request.Context(), token, revoked)
+	if err != nil {
+		response.Abort(c, err)
+		return false
+	}
+
+	c.Request = c.Request.WithContext(ctx)
+	return true
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nautilus/pkg/ctxkit"
+	"nautilus/pkg/jwt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuth(t *testing.T) {
+	assert.Nil(t, jwt.SetKeys(jwt.NewHMACKey("k1", []byte("0123456789abcdef0123456789abcdef"))))
+	p, err := jwt.NewPair(10, "admin", "sid")
+	assert.Nil(t, err)
+
+	revoked := func(ctx context.Context, c *jwt.Claims) (bool, error) {
+		return c.Id != "sid", nil
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Auth(revoked))
+	r.GET("/me", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		c.JSON(http.StatusOK, gin.H{"uid": ctxkit.GetUID(ctx), "sid": ctxkit.GetTokenID(ctx)})
+	})
+
+	other, err := jwt.NewPair(10, "admin", "other")
+	assert.Nil(t, err)
+
+	cases := []struct {
+		header string
+		code   int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer abc", http.StatusUnauthorized},
+		{"Bearer " + p.RefreshToken, http.StatusUnauthorized},
+		{"Bearer " + other.AccessToken, http.StatusUnauthorized},
+		{"Bearer " + p.AccessToken, http.StatusOK},
+		{"bearer " + p.AccessToken, http.StatusOK},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		if c.header != "" {
+			req.Header.Set("Authorization", c.header)
+		}
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, c.code, w.Code, c.header)
+		if c.code == http.StatusOK {
+			assert.JSONEq(t, `{"uid":10,"sid":"sid"}`, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"nautilus/pkg/conf"
+	"nautilus/pkg/ctxkit"
+	"nautilus/pkg/log"
+	"nautilus/pkg/useragent"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Client 解析客户端信息写入 ctxkit，包括真实 ip、平台、设备型号和版本
+// 只有直连的对端在 TRUSTED_PROXIES(CIDR 或者 ip，逗号分隔) 中时才信任 X-Forwarded-For/X-Real-IP，
+// 否则使用对端地址，避免客户端伪造 header
+// 平台、设备和版本从 User-Agent 中解析，app 版本的标识为 UA_APP_NAME(默认 APP_ID)，
+// 需要放在 Sign 之前，这样请求参数中显式传入的 platform/version/device 会覆盖 UA 解析的结果
+//
+//	router.Use(middleware.Client())
+//	ip := ctxkit.GetAccessIP(ctx)
+func Client() gin.HandlerFunc {
+	trusted := parseCIDRs(conf.GetStrings("TRUSTED_PROXIES"))
+	app := conf.Get("UA_APP_NAME")
+	if app == "" {
+		app = conf.AppID
+	}
+
+	return func(c *gin.Context) {
+		info := useragent.Parse(c.Request.UserAgent(), app)
+
+		ctx := c.Request.Context()
+		ctx = ctxkit.WithAccessIP(ctx, clientIP(c.Request, trusted))
+		ctx = ctxkit.WithPlatform(ctx, info.Platform)
+		ctx = ctxkit.WithDevice(ctx, info.Device)
+		ctx = ctxkit.WithVersion(ctx, info.Version)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// clientIP 获取客户端真实 ip
+// X-Forwarded-For 从右往左跳过可信代理，第一个不可信的地址即为客户端 ip，
+// 左边的地址可以被客户端任意伪造，不能直接取第一个
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	remote, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		remote = strings.TrimSpace(r.RemoteAddr)
+	}
+
+	if !isTrusted(net.ParseIP(remote), trusted) {
+		return remote
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		ips := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(ips) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(ips[i]))
+			if ip == nil {
+				// 格式错误说明这一段不是可信代理写入的，不再往左查找
+				break
+			}
+
+			remote = ip.String()
+			if !isTrusted(ip, trusted) {
+				return remote
+			}
+		}
+
+		return remote
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+
+	return remote
+}
+
+func isTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseCIDRs 解析可信代理，单个 ip 当作 /32 或者 /128
+func parseCIDRs(s []string) (nets []*net.IPNet) {
+	for _, v := range s {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		if !strings.Contains(v, "/") {
+			if ip := net.ParseIP(v); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 8*net.IPv4len
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			log.Get(context.Background()).Warnf("invalid trusted proxy %q: %v", v, err)
+			continue
+		}
+		nets = append(nets, n)
+	}
+
+	return
+}
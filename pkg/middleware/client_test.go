@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"nautilus/pkg/ctxkit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := parseCIDRs([]string{"10.0.0.0/8", "192.168.1.1", "invalid"})
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		realIP string
+		want   string
+	}{
+		{name: "direct", remote: "1.1.1.1:1234", want: "1.1.1.1"},
+		{name: "spoofed xff from untrusted peer", remote: "1.1.1.1:1234", xff: []string{"8.8.8.8"}, want: "1.1.1.1"},
+		{name: "spoofed x-real-ip from untrusted peer", remote: "1.1.1.1:1234", realIP: "8.8.8.8", want: "1.1.1.1"},
+		{name: "trusted proxy", remote: "10.0.0.1:80", xff: []string{"2.2.2.2"}, want: "2.2.2.2"},
+		{name: "spoofed xff behind proxy", remote: "10.0.0.1:80", xff: []string{"8.8.8.8, 2.2.2.2"}, want: "2.2.2.2"},
+		{name: "proxy chain", remote: "10.0.0.1:80", xff: []string{"8.8.8.8, 2.2.2.2, 192.168.1.1", "10.0.0.2"}, want: "2.2.2.2"},
+		{name: "all trusted", remote: "10.0.0.1:80", xff: []string{"10.0.0.3, 10.0.0.2"}, want: "10.0.0.3"},
+		{name: "garbage in xff", remote: "10.0.0.1:80", xff: []string{"8.8.8.8, unknown, 10.0.0.2"}, want: "10.0.0.2"},
+		{name: "x-real-ip", remote: "192.168.1.1:80", realIP: "3.3.3.3", want: "3.3.3.3"},
+		{name: "ipv6", remote: "[::1]:80", xff: []string{"8.8.8.8"}, want: "::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			assert.Equal(t, tt.want, clientIP(req, trusted))
+		})
+	}
+}
+
+func TestClient(t *testing.T) {
+	os.Setenv("TRUSTED_PROXIES", "10.0.0.0/8")
+	defer os.Unsetenv("TRUSTED_PROXIES")
+	os.Setenv("UA_APP_NAME", "nautilus")
+	defer os.Unsetenv("UA_APP_NAME")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Client())
+	r.GET("/", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		c.JSON(http.StatusOK, gin.H{
+			"ip":       ctxkit.GetAccessIP(ctx),
+			"platform": ctxkit.GetPlatform(ctx),
+			"device":   ctxkit.GetDevice(ctx),
+			"version":  ctxkit.GetVersion(ctx),
+		})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:80"
+	req.Header.Set("X-Forwarded-For", "2.2.2.2")
+	req.Header.Set("User-Agent", "nautilus/1.2.0 (iPhone; CPU iPhone OS 15_0 like Mac OS X)")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"ip":"2.2.2.2","platform":"ios","device":"iPhone","version":"1.2.0"}`, w.Body.String())
+}
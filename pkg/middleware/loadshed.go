@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"nautilus/pkg/errors"
+	"nautilus/pkg/loadshed"
+	"nautilus/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errOverloaded = errors.NewServiceUnavailable().WithReason("OVERLOADED")
+
+// LoadShed 自适应并发限制中间件，超过并发限制的请求直接返回 503，避免请求堆积到超时
+// priorities 为接口路由对应的优先级，没有配置的接口为 normal，critical 的接口永远不会被拒绝
+// 需要放在 Timeout 之前，超时的请求也要计入耗时；放在 NewTraceID 之后，503 响应中才会带上 trace_id
+//
+//	l := loadshed.NewLimiter("http", loadshed.FromConf())
+//	router.Use(middleware.NewTraceID())
+//	router.Use(middleware.LoadShed(l, loadshed.PrioritiesFromConf()))
+func LoadShed(l *loadshed.Limiter, priorities map[string]loadshed.Priority) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := priorities[c.FullPath()]
+		if !ok {
+			p = loadshed.PriorityNormal
+		}
+
+		release, ok := l.Acquire(p)
+		if !ok {
+			c.Header("Retry-After", "1")
+			response.Abort(c, errOverloaded)
+			return
+		}
+		defer release()
+
+		c.Next()
+	}
+}
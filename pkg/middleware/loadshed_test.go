@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"nautilus/pkg/loadshed"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadShed(t *testing.T) {
+	l := loadshed.NewLimiter("middleware_test", loadshed.Options{InitialLimit: 1, MinLimit: 1})
+
+	block := make(chan struct{})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(LoadShed(l, map[string]loadshed.Priority{"/health": loadshed.PriorityCritical}))
+	r.GET("/slow", func(c *gin.Context) {
+		<-block
+		c.Status(http.StatusOK)
+	})
+	r.GET("/fast", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	done := make(chan int)
+	go func() { done <- serve("/slow").Code }()
+	for l.Inflight() == 0 {
+		runtime.Gosched()
+	}
+
+	w := serve("/fast")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, serve("/health").Code)
+
+	close(block)
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, http.StatusOK, serve("/fast").Code)
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"time"
 
 	"nautilus/pkg/ctxkit"
@@ -13,6 +14,23 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// rpcRoutes 限制 RPCQPSCount/RPCDurationSeconds 中 route 的数量
+// route 使用 gin 的路由模板，正常情况下数量是固定的，超过上限说明路由定义有问题
+var rpcRoutes = metrics.NewLabelGuard("rpc", 0)
+
+// methods 标准的 http method，其他 method 统计为 OTHER
+var methods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
 func Logging() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
@@ -23,29 +41,45 @@ func Logging() gin.HandlerFunc {
 			duration := time.Since(start)
 			path := c.Request.URL.Path
 			status := c.Writer.Status()
+			route := metricRoute(c)
 
-			// TODO params
+			// 请求参数和响应内容由 AccessLog 按需记录
 			log.Get(ctx).WithFields(log.Fields{
 				"path":   path,
+				"route":  route,
 				"status": status,
 				"cost":   duration.Seconds(),
 			}).Info("new rpc")
 
-			// 爬虫随便访问的url有可能导致prometheus报错
-			// prometheus统计的url中不能带数字
-			if status != 404 {
-				metrics.RPCDurationSeconds.With(prometheus.Labels{
-					"path": path,
-					"code": fmt.Sprint(c.Writer.Status()),
-				}).Observe(duration.Seconds())
-
-				metrics.RPCQPSCount.With(prometheus.Labels{
-					"path": path,
-					"code": fmt.Sprint(c.Writer.Status()),
-				}).Inc()
+			// 使用路由模板作为 label，不能使用原始的 url，否则 url 中的 id 会导致 prometheus 序列数量爆炸
+			labels := prometheus.Labels{
+				"method": metricMethod(c.Request.Method),
+				"route":  route,
+				"code":   fmt.Sprint(status),
 			}
+			metrics.RPCDurationSeconds.With(labels).Observe(duration.Seconds())
+			metrics.RPCQPSCount.With(labels).Inc()
 		}()
 
 		c.Next()
 	}
 }
+
+// metricRoute 返回 gin 的路由模板，没有匹配到路由时返回 unmatched，例如爬虫随便访问的 url
+func metricRoute(c *gin.Context) string {
+	route := c.FullPath()
+	if route == "" {
+		return metrics.Unmatched
+	}
+
+	return rpcRoutes.Value(route)
+}
+
+// metricMethod 非标准的 method 统一为 OTHER
+func metricMethod(method string) string {
+	if methods[method] {
+		return method
+	}
+
+	return "OTHER"
+}
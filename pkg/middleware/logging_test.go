@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nautilus/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggingMetricLabels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Logging())
+	r.GET("/v1/author/:author_id/articles", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	count := func(method, route, code string) float64 {
+		return testutil.ToFloat64(metrics.RPCQPSCount.WithLabelValues(method, route, code))
+	}
+	route := "/v1/author/:author_id/articles"
+	before := count(http.MethodGet, route, "200")
+	unmatched := count(http.MethodGet, metrics.Unmatched, "404")
+	other := count("OTHER", metrics.Unmatched, "404")
+
+	for _, id := range []string{"1", "2", "3"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/author/"+id+"/articles", nil))
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/wp-login.php", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("FOO", "/v1/author/1/articles", nil))
+
+	// 不同的 id 统计到同一个路由模板下
+	assert.Equal(t, before+3, count(http.MethodGet, route, "200"))
+	assert.Equal(t, unmatched+1, count(http.MethodGet, metrics.Unmatched, "404"))
+	assert.Equal(t, other+1, count("OTHER", metrics.Unmatched, "404"))
+}
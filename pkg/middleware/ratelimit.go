@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+
+	"nautilus/pkg/ctxkit"
+	"nautilus/pkg/errors"
+	"nautilus/pkg/jwt"
+	"nautilus/pkg/log"
+	"nautilus/pkg/metrics"
+	"nautilus/pkg/ratelimit"
+	"nautilus/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RateLimit 限流中间件，策略参考 ratelimit.FromConf
+// 同一个接口可以匹配多个策略，任意一个策略超限即返回 429，并通过 Retry-After 告诉客户端多久之后重试
+// ip 取自 Client 中间件，需要放在 Client 之后；appkey 取不到时按 ip 限流
+// uid 在接口鉴权之前取不到，会直接解析 access token，token 无效时按 ip 限流
+// 限流器出错时放行请求，避免 redis 故障导致所有接口不可用
+//
+//	policies, err := ratelimit.FromConf()
+//	router.Use(middleware.RateLimit(ratelimit.NewLocal(), policies...))
+func RateLimit(l ratelimit.Limiter, policies ...ratelimit.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		route := c.FullPath()
+
+		for _, p := range policies {
+			if !p.Match(route) {
+				continue
+			}
+
+			res, err := l.Allow(ctx, p.Name+":"+limitKey(c, p.Key, route), p.Rate)
+			if err != nil {
+				log.Get(ctx).Warnf("ratelimit policy %s err: %v", p.Name, err)
+				rateLimitCount(p.Name, "error")
+				continue
+			}
+
+			if !res.Allowed {
+				rateLimitCount(p.Name, "limited")
+				retry := int64(math.Ceil(res.RetryAfter.Seconds()))
+				if retry < 1 {
+					retry = 1
+				}
+				c.Header("Retry-After", strconv.FormatInt(retry, 10))
+				response.Abort(c, errors.NewTooManyRequests("too many requests").
+					WithReason("RATE_LIMITED").WithMetadata("policy", p.Name))
+				return
+			}
+
+			rateLimitCount(p.Name, "allowed")
+		}
+
+		c.Next()
+	}
+}
+
+// limitKey 限流维度的值
+func limitKey(c *gin.Context, key, route string) string {
+	ctx := c.Request.Context()
+
+	switch key {
+	case ratelimit.KeyRoute:
+		return c.Request.Method + " " + route
+	case ratelimit.KeyAppkey:
+		if appkey := ctxkit.GetAppkey(ctx); appkey != "" {
+			return "appkey:" + appkey
+		}
+	case ratelimit.KeyUID:
+		if uid := ctxkit.GetUID(ctx); uid != 0 {
+			return "uid:" + strconv.FormatInt(uid, 10)
+		}
+
+		if token := jwt.BearerToken(c.GetHeader("Authorization")); token != "" {
+			if claims, err := jwt.Verify(token, jwt.TypeAccess); err == nil {
+				return "uid:" + strconv.FormatUint(claims.UID, 10)
+			}
+		}
+	}
+
+	ip := ctxkit.GetAccessIP(ctx)
+	if ip == "" {
+		ip = c.ClientIP()
+	}
+
+	return "ip:" + ip
+}
+
+func rateLimitCount(policy, result string) {
+	metrics.RateLimitCount.With(prometheus.Labels{"policy": policy, "result": result}).Inc()
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nautilus/pkg/jwt"
+	"nautilus/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	assert.Nil(t, jwt.SetKeys(jwt.NewHMACKey("k1", []byte("0123456789abcdef0123456789abcdef"))))
+	p, err := jwt.NewPair(10, "admin", "sid")
+	assert.Nil(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Client())
+	r.Use(RateLimit(ratelimit.NewLocal(),
+		ratelimit.Policy{Name: "login", Route: "/login", Key: ratelimit.KeyIP, Rate: ratelimit.Rate{Limit: 1, Period: time.Minute}},
+		ratelimit.Policy{Name: "user", Route: "*", Key: ratelimit.KeyUID, Rate: ratelimit.Rate{Limit: 2, Period: time.Second}},
+	))
+	r.POST("/login", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/me", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	serve := func(method, path, ip, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/login", "1.1.1.1", "").Code)
+	w := serve(http.MethodPost, "/login", "1.1.1.1", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"code":429,"msg":"too many requests","data":null}`, w.Body.String())
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/login", "2.2.2.2", "").Code)
+
+	// 同一个用户换 ip 也共享配额
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/me", "3.3.3.3", p.AccessToken).Code)
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/me", "4.4.4.4", p.AccessToken).Code)
+	w = serve(http.MethodGet, "/me", "5.5.5.5", p.AccessToken)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// 未登录按 ip 限流
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/me", "3.3.3.3", "").Code)
+}
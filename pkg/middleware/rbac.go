@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"nautilus/api/auth"
+	"nautilus/pkg/rbac"
+	"nautilus/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authorize 根据 proto 中声明的鉴权规则生成中间件，通过生成代码的 Register${Service}HTTPServerWithAuth 注入
+// public 接口不需要登录，其余接口校验 token，声明了 permission 的接口再检查角色是否拥有权限
+//
+//	e := rbac.NewEnforcer(rbac.FromConf())
+//	pension_v0.RegisterAdminServiceHTTPServerWithAuth(r, srv, response.Writer{}, middleware.Authorize(admin.IsRevoked, e))
+func Authorize(revoked Revoked, e *rbac.Enforcer) func(rule *auth.Rule) gin.HandlerFunc {
+	return func(rule *auth.Rule) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			if rule.GetPublic() {
+				c.Next()
+				return
+			}
+
+			if !authenticate(c, revoked) {
+				return
+			}
+
+			if err := e.Check(c.Request.Context(), rule); err != nil {
+				response.Abort(c, err)
+				return
+			}
+
+			c.Next()
+		}
+	}
+}
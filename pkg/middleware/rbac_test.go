@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nautilus/api/auth"
+	"nautilus/pkg/jwt"
+	"nautilus/pkg/rbac"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorize(t *testing.T) {
+	assert.Nil(t, jwt.SetKeys(jwt.NewHMACKey("k1", []byte("0123456789abcdef0123456789abcdef"))))
+	admin, _ := jwt.NewPair(10, "admin", "sid")
+	super, _ := jwt.NewPair(1, "super", "sid")
+
+	e := rbac.NewEnforcer(func(ctx context.Context) ([]rbac.Policy, error) {
+		return []rbac.Policy{{Role: "super", Permission: "*"}}, nil
+	})
+	assert.Nil(t, e.Load(context.TODO()))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	authorize := Authorize(nil, e)
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	r.GET("/public", authorize(&auth.Rule{Public: true}), ok)
+	r.GET("/login", authorize(nil), ok)
+	r.GET("/create", authorize(&auth.Rule{Permission: "admin:create"}), ok)
+
+	cases := []struct {
+		path  string
+		token string
+		code  int
+	}{
+		{"/public", "", http.StatusOK},
+		{"/login", "", http.StatusUnauthorized},
+		{"/login", admin.AccessToken, http.StatusOK},
+		{"/create", "", http.StatusUnauthorized},
+		{"/create", admin.AccessToken, http.StatusForbidden},
+		{"/create", super.AccessToken, http.StatusOK},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, c.code, w.Code, c.path)
+	}
+}
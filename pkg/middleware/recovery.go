@@ -1,20 +1,43 @@
 package middleware
 
 import (
-	"context"
-	"runtime/debug"
+	"net/http"
 
-	"nautilus/pkg/log"
+	"nautilus/pkg/errors"
+	"nautilus/pkg/recovery"
+	"nautilus/pkg/response"
+
+	"github.com/gin-gonic/gin"
 )
 
-// Recovery panic handler
-func Recovery(ctx context.Context) {
-	if err := recover(); err != nil {
+// Recovery panic 处理中间件，记录堆栈日志和监控，把 span 标记为出错，并返回 500
+// 需要放在 NewTraceID 之后，响应和日志中才会带上 trace_id；响应已经写出时只记录不再返回
+// 后台协程中的 panic 使用 recovery.SafeGo 处理
+//
+//	router.Use(middleware.NewTraceID())
+//	router.Use(middleware.Recovery())
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+
+			// 标准库用于中断响应的 panic，交给 net/http 处理
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			recovery.Handle(c.Request.Context(), recovery.KindHTTP, p)
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
 
-		stack := string(debug.Stack())
-		// 这里为了不影响请求处理流程，使用了协程，原则上每个请求的处理过程中不开goroutine
-		log.Get(ctx).Errorf("err: [%+v]\n%s", err, stack)
+			response.Abort(c, errors.NewInternal())
+		}()
 
-		// 内部panic返回500错误
+		c.Next()
 	}
 }
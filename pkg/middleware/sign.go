@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"nautilus/pkg/conf"
+	"nautilus/pkg/errors"
+	"nautilus/pkg/response"
+	"nautilus/pkg/sign"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errInvalidSign = errors.NewAuthorization("invalid sign").WithReason("SIGN_INVALID")
+	errSignExpired = errors.NewAuthorization("request is expired").WithReason("SIGN_EXPIRED")
+	errReplayed    = errors.NewAuthorization("request is replayed").WithReason("NONCE_REPLAYED")
+)
+
+// Sign 请求签名校验中间件，签名算法参考 pkg/sign
+// 签名覆盖 access_key/platform/version/device，校验通过之后才把公共参数写入 ctxkit
+// ts 和服务器时间相差超过 SIGN_TS_WINDOW(默认5分钟) 的请求直接拒绝，nonce 在时间窗口内只能使用一次
+// body 超过 SIGN_MAX_BODY(默认1MB) 时在计算签名之前返回 413
+// nonces 为 nil 时使用内存存储
+func Sign(nonces sign.NonceStore) gin.HandlerFunc {
+	if nonces == nil {
+		nonces = sign.NewMemoryNonceStore()
+	}
+
+	return func(c *gin.Context) {
+		p := sign.FromRequest(c.Request)
+
+		secret := conf.Get("SIGN_APP_" + strings.ToUpper(p.Appkey) + "_SECRET")
+		if p.Appkey == "" || p.Sign == "" || p.Nonce == "" || secret == "" {
+			response.Abort(c, errInvalidSign)
+			return
+		}
+
+		window := signWindow()
+		ts, ok := p.Time()
+		if d := time.Since(ts); !ok || d > window || d < -window {
+			response.Abort(c, errSignExpired)
+			return
+		}
+
+		// 签名需要完整的 body，先限制大小，避免未校验的请求读取任意大的 body
+		max := signMaxBody()
+		if c.Request.ContentLength > max {
+			response.Abort(c, errors.NewPayloadTooLarge(max, c.Request.ContentLength))
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, max))
+		if err != nil {
+			// 没有 Content-Length 的请求读到 max 字节之后才能发现超出限制
+			if int64(len(body)) >= max {
+				response.Abort(c, errors.NewPayloadTooLarge(max, c.Request.ContentLength))
+				return
+			}
+
+			response.Abort(c, errors.Wrap(err, errors.BadRequest, "read body failed"))
+			return
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		if !sign.Equal(p.Sign, sign.Compute(secret, sign.Canonical(c.Request, body, p))) {
+			response.Abort(c, errInvalidSign)
+			return
+		}
+
+		// 超过时间窗口的请求已经被拒绝，nonce 只需要保存两倍的窗口时间
+		if !nonces.Use(p.Appkey+":"+p.Nonce, 2*window) {
+			response.Abort(c, errReplayed)
+			return
+		}
+
+		// 签名覆盖了 access_key/platform/version/device，校验通过之后才写入 ctxkit
+		c.Request = c.Request.WithContext(p.WithContext(c.Request.Context()))
+
+		c.Next()
+	}
+}
+
+// signWindow 签名时间窗口，配置 SIGN_TS_WINDOW
+func signWindow() time.Duration {
+	if d := conf.GetDuration("SIGN_TS_WINDOW"); d > 0 {
+		return d
+	}
+
+	return 5 * time.Minute
+}
+
+// signMaxBody 签名请求 body 的最大字节数，配置 SIGN_MAX_BODY
+func signMaxBody() int64 {
+	if n := conf.GetInt64("SIGN_MAX_BODY"); n > 0 {
+		return n
+	}
+
+	return 1 << 20
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"nautilus/pkg/ctxkit"
+	"nautilus/pkg/sign"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	os.Setenv("SIGN_APP_TEST_SECRET", "secret")
+	defer os.Unsetenv("SIGN_APP_TEST_SECRET")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Sign(nil))
+	r.POST("/articles", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		c.JSON(http.StatusOK, gin.H{
+			"appkey":   ctxkit.GetAppkey(ctx),
+			"platform": ctxkit.GetPlatform(ctx),
+			"version":  ctxkit.GetVersion(ctx),
+		})
+	})
+
+	newRequest := func(body, nonce string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/articles?b=2&a=1&platform=ios&version=1.0.0", strings.NewReader(body))
+		sign.SignRequest(req, []byte(body), "test", "secret", nonce)
+		return req
+	}
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(newRequest(`{"title":"a"}`, "n1"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"appkey":"test","platform":"ios","version":"1.0.0"}`, w.Body.String())
+
+	// 重放
+	w = serve(newRequest(`{"title":"a"}`, "n1"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "replayed")
+
+	// 篡改 body
+	req := newRequest(`{"title":"a"}`, "n2")
+	req.Body = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"title":"b"}`)).Body
+	assert.Equal(t, http.StatusUnauthorized, serve(req).Code)
+
+	// 伪造身份相关的 header
+	req = newRequest(`{}`, "n6")
+	req.Header.Set("X-Platform", "android")
+	assert.Equal(t, http.StatusUnauthorized, serve(req).Code)
+	req = newRequest(`{}`, "n7")
+	req.Header.Set("X-Access-Key", "forged")
+	assert.Equal(t, http.StatusUnauthorized, serve(req).Code)
+
+	// 签名时带上的 header 可以通过校验
+	req = httptest.NewRequest(http.MethodPost, "/articles", strings.NewReader(`{}`))
+	req.Header.Set("X-Platform", "android")
+	req.Header.Set("X-Version", "2.0.0")
+	sign.SignRequest(req, []byte(`{}`), "test", "secret", "n8")
+	w = serve(req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"appkey":"test","platform":"android","version":"2.0.0"}`, w.Body.String())
+
+	// 篡改 query
+	req = newRequest(`{}`, "n3")
+	req.URL.RawQuery += "&c=3"
+	assert.Equal(t, http.StatusUnauthorized, serve(req).Code)
+
+	// 过期
+	req = newRequest(`{}`, "n4")
+	req.Header.Set("X-Ts", strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+	w = serve(req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "expired")
+
+	// 未知 appkey
+	req = httptest.NewRequest(http.MethodPost, "/articles", strings.NewReader(`{}`))
+	sign.SignRequest(req, []byte(`{}`), "unknown", "secret", "n5")
+	assert.Equal(t, http.StatusUnauthorized, serve(req).Code)
+}
+
+func TestSign_MaxBody(t *testing.T) {
+	os.Setenv("SIGN_APP_TEST_SECRET", "secret")
+	os.Setenv("SIGN_MAX_BODY", "16")
+	defer os.Unsetenv("SIGN_APP_TEST_SECRET")
+	defer os.Unsetenv("SIGN_MAX_BODY")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Sign(nil))
+	r.POST("/articles", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	serve := func(body, nonce string, chunked bool) int {
+		req := httptest.NewRequest(http.MethodPost, "/articles", strings.NewReader(body))
+		sign.SignRequest(req, []byte(body), "test", "secret", nonce)
+		if chunked {
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(`{"title":"a"}`, "m1", false))
+
+	// Content-Length 超出限制，不读取 body
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve(`{"title":"aaaaaaaaaa"}`, "m2", false))
+
+	// 没有 Content-Length 时读取到限制之后拒绝
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve(`{"title":"aaaaaaaaaa"}`, "m3", true))
+	assert.Equal(t, http.StatusOK, serve(`{"title":"a"}`, "m4", true))
+}
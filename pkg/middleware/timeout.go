@@ -1,159 +1,299 @@
 package middleware
 
 import (
-	"bytes"
+	"bufio"
 	"context"
-	"encoding/json"
-	"fmt"
+	"errors"
+	"net"
 	"net/http"
-	"runtime/debug"
+	"strconv"
 	"sync"
 	"time"
 
-	"nautilus/pkg/errors"
-	"nautilus/pkg/trace"
+	"nautilus/pkg/deadline"
+	xerrors "nautilus/pkg/errors"
+	"nautilus/pkg/response"
 
 	"github.com/gin-gonic/gin"
 )
 
-// timeoutWriter implements http.Writer
-// 可以参考标准库 http.timeoutHandler
+var errTimeout = xerrors.NewRequestTimeout("timeout").WithReason("TIMEOUT")
+
+var (
+	_ http.Flusher       = (*timeoutWriter)(nil)
+	_ http.Hijacker      = (*timeoutWriter)(nil)
+	_ http.CloseNotifier = (*timeoutWriter)(nil)
+)
+
+// Timeout 超时控制
+// timeout 为默认超时时间，routes 为单个接口的超时时间，key 为 gin 路由，可以通过 deadline.Routes 从 proto 和配置中读取
+// 超时时间为 0 的接口不限制，例如流式接口；请求头中带有 grpc-timeout/X-Request-Timeout 时，取更短的时间
+//
+// handler 在当前协程中执行，超时后 ctx 被取消，同时由定时器直接写出 408 并 Flush，不需要等 handler 返回，
+// 之后 handler 的写入都会被丢弃；
+// handler 已经开始写响应时不会再返回 408。响应不做缓冲，Flush/Hijack/CloseNotify 直接透传
+// handler panic 时由外层的 Recovery 返回 500
+// 需要放在 NewTraceID 之后，超时响应中才会带上 trace_id
+//
+//	router.Use(middleware.NewTraceID())
+//	router.Use(middleware.Timeout(5*time.Second, deadline.Routes()))
+func Timeout(timeout time.Duration, routes map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		d := timeout
+		if v, ok := routes[c.FullPath()]; ok {
+			d = v
+		}
+		if v, ok := deadline.FromHeader(c.Request.Header); ok && (d <= 0 || v < d) {
+			d = v
+		}
+		if d <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+
+		r := c.Request.WithContext(ctx)
+		c.Request = r
+
+		tw := &timeoutWriter{ResponseWriter: c.Writer, r: r, h: c.Writer.Header().Clone(), code: http.StatusOK}
+		c.Writer = tw
+
+		// 定时器只操作 timeoutWriter，不使用 gin.Context，handler 返回之后 gin.Context 会被回收复用
+		timer := time.AfterFunc(d, tw.timeout)
+		defer func() {
+			timer.Stop()
+			tw.finish()
+			c.Writer = tw.ResponseWriter
+
+			// gin 的 c.JSON 等方法写入失败时会 panic，超时之后的写入失败不算 panic
+			if p := recover(); p != nil {
+				if err, ok := p.(error); ok && errors.Is(err, http.ErrHandlerTimeout) {
+					c.Abort()
+					return
+				}
+
+				panic(p)
+			}
+		}()
+
+		c.Next()
+	}
+}
+
+// timeoutWriter 超时之前透传 handler 的写入，超时之后丢弃
+// handler 修改的 header 在第一次写入时复制到底层 ResponseWriter，避免和定时器并发读写同一个 header
+// ctx 超时和定时器触发的先后顺序不确定，handler 在 ctx 超时之后写入时也按照超时处理
 type timeoutWriter struct {
 	gin.ResponseWriter
+	// r 带有超时 ctx 的请求，只读
+	r *http.Request
 
-	h    http.Header
-	wbuf bytes.Buffer
+	mu sync.Mutex
+	h  http.Header
+	// code handler 设置的状态码，第一次写入时才真正写出，和 gin 的行为一致
+	code int
+	// committed 响应头已经写出
+	committed bool
+	timedOut  bool
+	hijacked  bool
+	// finished handler 已经返回，定时器不能再写入
+	finished bool
+}
 
-	mu          sync.Mutex
-	timeout     bool
-	wroteHeader bool
-	code        int
+// Header handler 使用的 header
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
 }
 
-// Write the response
+// WriteHeader 记录状态码
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if !tw.committed && !tw.timedOut {
+		tw.code = code
+	}
+}
+
+// WriteHeaderNow 写出响应头
+func (tw *timeoutWriter) WriteHeaderNow() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if !tw.expired() {
+		tw.commit()
+	}
+}
+
+// Write 超时之后返回 http.ErrHandlerTimeout
 func (tw *timeoutWriter) Write(b []byte) (int, error) {
 	tw.mu.Lock()
 	defer tw.mu.Unlock()
 
-	if tw.timeout {
-		return 0, nil
+	if tw.expired() {
+		return 0, http.ErrHandlerTimeout
 	}
 
-	return tw.wbuf.Write(b)
+	tw.commit()
+	return tw.ResponseWriter.Write(b)
 }
 
-// WriteHeader In http.ResponseWriter interface
-func (tw *timeoutWriter) WriteHeader(code int) {
-	checkWriteHeaderCode(code)
+// WriteString 超时之后返回 http.ErrHandlerTimeout
+func (tw *timeoutWriter) WriteString(s string) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	tw.commit()
+	return tw.ResponseWriter.WriteString(s)
+}
+
+// Status 超时之后为 408
+func (tw *timeoutWriter) Status() int {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.committed || tw.timedOut {
+		return tw.ResponseWriter.Status()
+	}
 
+	return tw.code
+}
+
+// Size 已经写出的 body 大小
+func (tw *timeoutWriter) Size() int {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	return tw.ResponseWriter.Size()
+}
+
+// Written 是否已经写出响应头
+func (tw *timeoutWriter) Written() bool {
 	tw.mu.Lock()
 	defer tw.mu.Unlock()
 
-	if tw.timeout || tw.wroteHeader {
+	return tw.committed || tw.timedOut
+}
+
+// Flush 流式响应，超时之后不再写出
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.expired() {
 		return
 	}
 
-	tw.writeHeader(code)
+	tw.commit()
+	tw.ResponseWriter.Flush()
 }
 
-// writeHeader set that the header has been written
-func (tw *timeoutWriter) writeHeader(code int) {
-	tw.wroteHeader = true
-	tw.code = code
+// Hijack 接管连接之后超时不再写入响应
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.expired() {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	if tw.committed {
+		return nil, nil, errors.New("response already written")
+	}
+
+	conn, rw, err := tw.ResponseWriter.Hijack()
+	if err == nil {
+		tw.hijacked = true
+	}
+
+	return conn, rw, err
 }
 
-// Header "relays" the header, h, set in struct
-// In http.ResponseWriter interface
-func (tw *timeoutWriter) Header() http.Header {
-	return tw.h
+// CloseNotify 透传底层连接的关闭通知，不受超时影响
+func (tw *timeoutWriter) CloseNotify() <-chan bool {
+	return tw.ResponseWriter.CloseNotify()
 }
 
-// SetTimeout sets timedOut field to true
-func (tw *timeoutWriter) SetTimeout() {
-	tw.timeout = true
+// commit 把 header 和状态码写出，调用方需要持有锁
+func (tw *timeoutWriter) commit() {
+	if tw.committed {
+		return
+	}
+	tw.committed = true
+
+	dst := tw.ResponseWriter.Header()
+	for k, vv := range tw.h {
+		dst[k] = vv
+	}
+	tw.ResponseWriter.WriteHeader(tw.code)
+	tw.ResponseWriter.WriteHeaderNow()
 }
 
-func checkWriteHeaderCode(code int) {
-	if code < 100 || code > 999 {
-		panic(fmt.Sprintf("invalid write header code: %v", code))
+// timeout 定时器触发，响应还没有写出时返回 408
+func (tw *timeoutWriter) timeout() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if !tw.finished {
+		tw.expire()
 	}
 }
 
-// Timeout 超时控制
-// 超时时间由调用方控制，默认返回 timeout
-// 搬自: https://github.com/JacobSNGoodwin/memrizr/blob/master/account/handler/middleware/timeout.go
-func Timeout(timeout time.Duration) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// set Gin's writer as our custom writer
-		tw := &timeoutWriter{
-			ResponseWriter: c.Writer,
-			h:              make(http.Header),
-		}
-		c.Writer = tw
+// expired ctx 已经超时则按照超时处理，调用方需要持有锁
+func (tw *timeoutWriter) expired() bool {
+	if !tw.timedOut && errors.Is(tw.r.Context().Err(), context.DeadlineExceeded) {
+		tw.expire()
+	}
 
-		// wrap the request context with a timeout
-		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
-		defer cancel()
+	return tw.timedOut
+}
 
-		// update gin request context
-		c.Request = c.Request.WithContext(ctx)
+// expire 标记超时，响应还没有写出时返回 408，调用方需要持有锁
+func (tw *timeoutWriter) expire() {
+	if tw.hijacked || tw.timedOut {
+		return
+	}
 
-		finished := make(chan struct{})        // to indicate handler finished
-		panicChan := make(chan interface{}, 1) // used to handle panics if we can't recover
+	if !tw.committed {
+		tw.write(errTimeout)
+	}
+	tw.timedOut = true
+}
 
-		go func() {
-			defer func() {
-				if p := recover(); p != nil {
-					panicChan <- p
-				}
-			}()
+// write 直接写出错误响应，调用方需要持有锁
+// 只使用底层 ResponseWriter 中已有的 header，例如 NewTraceID 设置的 x-trace-id
+// 带上 Content-Length 并 Flush，客户端在 handler 返回之前就能读到完整的响应
+func (tw *timeoutWriter) write(err error) {
+	status, body := response.Marshal(tw.r, err)
 
-			c.Next() // calls subsequent middleware(s) and handler
-			finished <- struct{}{}
-		}()
+	h := tw.ResponseWriter.Header()
+	h.Set("Content-Type", "application/json; charset=utf-8")
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	tw.ResponseWriter.WriteHeader(status)
+	_, _ = tw.ResponseWriter.Write(body)
+	tw.ResponseWriter.Flush()
+	tw.committed = true
+}
 
-		select {
-		case rec := <-panicChan:
-			// if we cannot recover from panic,
-			// send internal server error
-			e := errors.NewInternal()
-			tw.ResponseWriter.WriteHeader(e.Status())
-			tw.ResponseWriter.Header().Set("x-trace-id", trace.GetTraceID(c.Request.Context()))
-			eResp, _ := json.Marshal(gin.H{
-				"code": -1,
-				"msg":  e.Error(),
-			})
-			fmt.Println(rec)
-			fmt.Println(string(debug.Stack()))
-			tw.ResponseWriter.Write(eResp)
-		case <-finished:
-			// if finished, set headers and write resp
-			tw.mu.Lock()
-			defer tw.mu.Unlock()
-			// map Headers from tw.Header() (written to by gin)
-			// to tw.ResponseWriter for response
-			dst := tw.ResponseWriter.Header()
-			for k, vv := range tw.Header() {
-				dst[k] = vv
-			}
-			tw.ResponseWriter.WriteHeader(tw.code)
-			// tw.wbuf will have been written to already when gin writes to tw.Write()
-			tw.ResponseWriter.Write(tw.wbuf.Bytes())
-		case <-ctx.Done():
-			// timeout has occurred, send errTimeout and write headers
-			tw.mu.Lock()
-			defer tw.mu.Unlock()
-			et := errors.NewRequestTimeout("timeout")
-			// ResponseWriter from gin
-			tw.ResponseWriter.Header().Set("Content-Type", "application/json")
-			tw.ResponseWriter.WriteHeader(et.Status())
-			eResp, _ := json.Marshal(gin.H{
-				"code": -1,
-				"msg":  et.Error(),
-			})
-			tw.ResponseWriter.Write(eResp)
-			c.Abort()
-			tw.SetTimeout()
-		}
+// finish handler 已经返回，响应还没有写出时把 header 和状态码交给底层 ResponseWriter，
+// 由 gin 在所有中间件执行完之后写出
+func (tw *timeoutWriter) finish() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.finished = true
+	if tw.committed || tw.hijacked {
+		return
+	}
+
+	dst := tw.ResponseWriter.Header()
+	for k, vv := range tw.h {
+		dst[k] = vv
 	}
+	tw.ResponseWriter.WriteHeader(tw.code)
 }
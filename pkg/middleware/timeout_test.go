@@ -0,0 +1,274 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTimeoutRouter(timeout time.Duration, routes map[string]time.Duration) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Writer.Header().Set("x-trace-id", "trace")
+		c.Next()
+	})
+	r.Use(Timeout(timeout, routes))
+	return r
+}
+
+func serveTimeout(r http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestTimeout(t *testing.T) {
+	r := newTimeoutRouter(50*time.Millisecond, map[string]time.Duration{"/unlimited": 0, "/short": 10 * time.Millisecond})
+
+	r.GET("/fast", func(c *gin.Context) {
+		c.Header("x-custom", "1")
+		c.JSON(http.StatusCreated, gin.H{"ok": true})
+	})
+	r.GET("/empty", func(c *gin.Context) {
+		c.Header("x-custom", "1")
+		c.Status(http.StatusNoContent)
+	})
+	// handler 不检查 ctx，超时之后的写入被丢弃
+	r.GET("/ignore", func(c *gin.Context) {
+		time.Sleep(100 * time.Millisecond)
+		c.Header("x-custom", "1")
+		c.String(http.StatusOK, "late")
+	})
+	// handler 检查 ctx
+	r.GET("/ctx", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		c.String(http.StatusOK, c.Request.Context().Err().Error())
+	})
+	slow := func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+			c.String(http.StatusGatewayTimeout, "canceled")
+		case <-time.After(30 * time.Millisecond):
+			c.String(http.StatusOK, "done")
+		}
+	}
+	r.GET("/unlimited", slow)
+	r.GET("/short", slow)
+	r.GET("/slow", slow)
+
+	w := serveTimeout(r, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "1", w.Header().Get("x-custom"))
+	assert.Equal(t, "trace", w.Header().Get("x-trace-id"))
+	assert.JSONEq(t, `{"ok":true}`, w.Body.String())
+
+	w = serveTimeout(r, httptest.NewRequest(http.MethodGet, "/empty", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "1", w.Header().Get("x-custom"))
+
+	for _, path := range []string{"/ignore", "/ctx"} {
+		w = serveTimeout(r, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusRequestTimeout, w.Code, path)
+		assert.Equal(t, "trace", w.Header().Get("x-trace-id"), path)
+		assert.Empty(t, w.Header().Get("x-custom"), path)
+
+		var b struct{ Msg string }
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &b), path)
+		assert.Equal(t, "timeout", b.Msg, path)
+	}
+
+	// 路由超时时间
+	assert.Equal(t, "done", serveTimeout(r, httptest.NewRequest(http.MethodGet, "/unlimited", nil)).Body.String())
+	assert.Equal(t, http.StatusRequestTimeout, serveTimeout(r, httptest.NewRequest(http.MethodGet, "/short", nil)).Code)
+	assert.Equal(t, "done", serveTimeout(r, httptest.NewRequest(http.MethodGet, "/slow", nil)).Body.String())
+
+	// 上游传递的超时时间只会缩短
+	for _, h := range [][2]string{{"X-Request-Timeout", "10"}, {"X-Request-Timeout", "10ms"}, {"Grpc-Timeout", "10m"}} {
+		req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+		req.Header.Set(h[0], h[1])
+		assert.Equal(t, http.StatusRequestTimeout, serveTimeout(r, req).Code, h)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	req.Header.Set("X-Request-Timeout", "1s")
+	assert.Equal(t, "done", serveTimeout(r, req).Body.String())
+}
+
+func TestTimeoutPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Writer.Header().Set("x-trace-id", "trace")
+		c.Next()
+	})
+	r.Use(Recovery())
+	r.Use(Timeout(time.Second, nil))
+	r.GET("/panic", func(c *gin.Context) {
+		c.Header("x-custom", "1")
+		panic("boom")
+	})
+
+	w := serveTimeout(r, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "trace", w.Header().Get("x-trace-id"))
+	assert.JSONEq(t, `{"code":500,"msg":"Internal server error.","data":null}`, w.Body.String())
+}
+
+func TestTimeoutStream(t *testing.T) {
+	r := newTimeoutRouter(time.Second, nil)
+	next := make(chan struct{})
+	r.GET("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain")
+		c.String(http.StatusOK, "first\n")
+		c.Writer.Flush()
+		<-next
+		c.String(http.StatusOK, "second\n")
+	})
+
+	s := httptest.NewServer(r)
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/stream")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "trace", resp.Header.Get("x-trace-id"))
+
+	// 第一段在 handler 返回之前就能读到
+	br := bufio.NewReader(resp.Body)
+	line, err := br.ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "first\n", line)
+
+	close(next)
+	rest, err := ioutil.ReadAll(br)
+	assert.Nil(t, err)
+	assert.Equal(t, "second\n", string(rest))
+}
+
+func TestTimeoutStreamExpired(t *testing.T) {
+	r := newTimeoutRouter(50*time.Millisecond, nil)
+	written := make(chan error, 1)
+	r.GET("/stream", func(c *gin.Context) {
+		c.String(http.StatusOK, "first\n")
+		c.Writer.Flush()
+		<-c.Request.Context().Done()
+		time.Sleep(10 * time.Millisecond)
+		_, err := c.Writer.Write([]byte("late\n"))
+		written <- err
+	})
+
+	s := httptest.NewServer(r)
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/stream")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	// 已经开始写响应，超时之后不再返回 408，后续写入被丢弃
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, "first\n", string(body))
+	assert.Equal(t, http.ErrHandlerTimeout, <-written)
+}
+
+func TestTimeoutHijack(t *testing.T) {
+	r := newTimeoutRouter(20*time.Millisecond, nil)
+	r.GET("/hijack", func(c *gin.Context) {
+		conn, rw, err := c.Writer.Hijack()
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		time.Sleep(50 * time.Millisecond)
+		rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		rw.Flush()
+	})
+
+	s := httptest.NewServer(r)
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/hijack")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, "hijacked", string(body))
+}
+
+func TestTimeoutCloseNotify(t *testing.T) {
+	notified := make(chan bool, 1)
+	r := newTimeoutRouter(time.Second, nil)
+	r.GET("/notify", func(c *gin.Context) {
+		_, ok := c.Writer.(*timeoutWriter)
+		assert.True(t, ok)
+
+		// 客户端断开连接时 handler 能收到通知
+		select {
+		case <-c.Writer.(http.CloseNotifier).CloseNotify():
+			notified <- true
+		case <-time.After(500 * time.Millisecond):
+			notified <- false
+		}
+	})
+
+	s := httptest.NewServer(r)
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, s.URL+"/notify", nil)
+	_, err := http.DefaultClient.Do(req)
+	assert.Error(t, err)
+	assert.True(t, <-notified)
+}
+
+// TestTimeoutServer 真实的 http server，408 在 handler 返回之前到达客户端
+func TestTimeoutServer(t *testing.T) {
+	r := newTimeoutRouter(20*time.Millisecond, nil)
+
+	release := make(chan struct{})
+	returned := make(chan struct{})
+	r.GET("/block", func(c *gin.Context) {
+		defer close(returned)
+		<-release
+	})
+
+	s := httptest.NewServer(r)
+	defer s.Close()
+	defer close(release)
+
+	// handler 一直阻塞，408 没有写出时客户端超时
+	client := &http.Client{Timeout: time.Second}
+	resp, err := client.Get(s.URL + "/block")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+
+	select {
+	case <-returned:
+		t.Fatal("handler returned before the timeout response")
+	default:
+	}
+
+	assert.Equal(t, http.StatusRequestTimeout, resp.StatusCode)
+	assert.Equal(t, "trace", resp.Header.Get("x-trace-id"))
+	assert.Equal(t, int64(len(body)), resp.ContentLength)
+
+	var b struct{ Msg string }
+	assert.Nil(t, json.Unmarshal(body, &b))
+	assert.Equal(t, "timeout", b.Msg)
+}
@@ -0,0 +1,372 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"nautilus/pkg/httprule"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+const (
+	// envelopeName 统一响应结构 {code,msg,data}
+	envelopeName = "Response"
+	// errorName 错误响应结构，带 trace_id 和 details
+	errorName = "ErrorResponse"
+)
+
+// Generate 根据 proto 文件描述生成 OpenAPI 3 文档
+//
+// 接口路径和 method 来自 google.api.http 注解，path/query 参数名来自 @inject_tag 注入的 uri/form tag，
+// 参数约束来自 binding tag，所有响应都包装在 {code,msg,data} 结构中
+//
+//	doc := openapi.Generate("demo", "v0", demo_v0.File_demo_v0_demo_proto)
+func Generate(title, version string, files ...protoreflect.FileDescriptor) *Document {
+	g := &generator{
+		doc: &Document{
+			OpenAPI: "3.0.3",
+			Info:    Info{Title: title, Version: version},
+			Paths:   map[string]PathItem{},
+			Components: Components{Schemas: map[string]*Schema{
+				envelopeName: envelopeSchema(),
+				errorName:    errorSchema(),
+			}},
+		},
+	}
+
+	for _, fd := range files {
+		services := fd.Services()
+		for i := 0; i < services.Len(); i++ {
+			g.addService(services.Get(i))
+		}
+	}
+
+	return g.doc
+}
+
+type generator struct {
+	doc *Document
+}
+
+// addService 添加 service 下所有带 google.api.http 注解的接口
+func (g *generator) addService(sd protoreflect.ServiceDescriptor) {
+	tag := string(sd.Name())
+	g.doc.Tags = append(g.doc.Tags, Tag{Name: tag})
+
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		for j, r := range httprule.Rules(md) {
+			method, path := httprule.Pattern(r)
+			if path == "" {
+				continue
+			}
+
+			op := g.operation(tag, md, method, path)
+			op.OperationID = fmt.Sprintf("%s_%s_%d", sd.Name(), md.Name(), j)
+
+			path = httprule.OpenAPIPath(path)
+			item, ok := g.doc.Paths[path]
+			if !ok {
+				item = PathItem{}
+				g.doc.Paths[path] = item
+			}
+			item[strings.ToLower(method)] = op
+		}
+	}
+}
+
+// operation 生成单个接口描述
+// GET/DELETE 请求参数从 query 中绑定，其他请求从 JSON body 中绑定，和生成的 gin 代码保持一致
+func (g *generator) operation(tag string, md protoreflect.MethodDescriptor, method, path string) *Operation {
+	op := &Operation{
+		Tags: []string{tag},
+		Responses: map[string]*Response{
+			"200": {
+				Description: "OK",
+				Content: jsonContent(&Schema{AllOf: []*Schema{
+					ref(envelopeName),
+					{Properties: map[string]*Schema{"data": g.messageRef(md.Output())}},
+				}}),
+			},
+			"default": {
+				Description: "Error",
+				Content:     jsonContent(ref(errorName)),
+			},
+		},
+	}
+
+	pathVars := map[string]bool{}
+	for _, v := range httprule.Vars(path) {
+		pathVars[v] = true
+	}
+
+	fields := md.Input().Fields()
+	tags := goTags(md.Input())
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		st := tags[string(fd.Name())]
+
+		uri := tagName(st.Get("uri"))
+		if uri == "" {
+			uri = string(fd.Name())
+		}
+		if pathVars[uri] {
+			schema := g.fieldSchema(fd)
+			applyBinding(schema, st.Get("binding"))
+			op.Parameters = append(op.Parameters, &Parameter{Name: uri, In: "path", Required: true, Schema: schema})
+			continue
+		}
+
+		if method != "GET" && method != "DELETE" {
+			continue
+		}
+
+		if (fd.IsList() && fd.Kind() == protoreflect.MessageKind) || fd.IsMap() {
+			continue
+		}
+
+		name := tagName(st.Get("form"))
+		if name == "" {
+			name = goName(fd)
+		}
+
+		schema := g.fieldSchema(fd)
+		required := applyBinding(schema, st.Get("binding"))
+		op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "query", Required: required, Schema: schema})
+	}
+
+	if method != "GET" && method != "DELETE" {
+		op.RequestBody = &RequestBody{Required: true, Content: jsonContent(g.messageRef(md.Input()))}
+	}
+
+	return op
+}
+
+// messageRef 返回 message 的引用，schema 定义放到 components 中
+func (g *generator) messageRef(md protoreflect.MessageDescriptor) *Schema {
+	name := string(md.FullName())
+	if _, ok := g.doc.Components.Schemas[name]; ok {
+		return ref(name)
+	}
+
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	// 先占位，防止递归引用
+	g.doc.Components.Schemas[name] = schema
+
+	fields := md.Fields()
+	tags := goTags(md)
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		fs := g.fieldSchema(fd)
+		if applyBinding(fs, tags[string(fd.Name())].Get("binding")) {
+			schema.Required = append(schema.Required, jsonName(fd))
+		}
+
+		schema.Properties[jsonName(fd)] = fs
+	}
+
+	return ref(name)
+}
+
+// fieldSchema 字段类型描述，JSON 编码使用 encoding/json，int64 仍为数字
+func (g *generator) fieldSchema(fd protoreflect.FieldDescriptor) *Schema {
+	if fd.IsMap() {
+		return &Schema{Type: "object", AdditionalProperties: g.kindSchema(fd.MapValue())}
+	}
+
+	if fd.IsList() {
+		return &Schema{Type: "array", Items: g.kindSchema(fd)}
+	}
+
+	return g.kindSchema(fd)
+}
+
+// kindSchema 单个值的类型描述
+func (g *generator) kindSchema(fd protoreflect.FieldDescriptor) *Schema {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return &Schema{Type: "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return &Schema{Type: "integer", Format: "int32"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return &Schema{Type: "integer", Format: "int64"}
+	case protoreflect.FloatKind:
+		return &Schema{Type: "number", Format: "float"}
+	case protoreflect.DoubleKind:
+		return &Schema{Type: "number", Format: "double"}
+	case protoreflect.StringKind:
+		return &Schema{Type: "string"}
+	case protoreflect.BytesKind:
+		return &Schema{Type: "string", Format: "byte"}
+	case protoreflect.EnumKind:
+		// encoding/json 把枚举编码为数字
+		values := fd.Enum().Values()
+		desc := make([]string, 0, values.Len())
+		for i := 0; i < values.Len(); i++ {
+			desc = append(desc, fmt.Sprintf("%d: %s", values.Get(i).Number(), values.Get(i).Name()))
+		}
+		return &Schema{Type: "integer", Format: "int32", Description: strings.Join(desc, ", ")}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return g.messageRef(fd.Message())
+	default:
+		return &Schema{}
+	}
+}
+
+// goTags 返回 proto 字段名对应的 Go struct tag，@inject_tag 注入的 form/uri/binding 都在其中
+func goTags(md protoreflect.MessageDescriptor) map[string]reflect.StructTag {
+	tags := map[string]reflect.StructTag{}
+
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(md.FullName())
+	if err != nil {
+		return tags
+	}
+
+	t := reflect.TypeOf(mt.Zero().Interface())
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return tags
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		for _, s := range strings.Split(f.Tag.Get("protobuf"), ",") {
+			if strings.HasPrefix(s, "name=") {
+				tags[strings.TrimPrefix(s, "name=")] = f.Tag
+			}
+		}
+	}
+
+	return tags
+}
+
+// applyBinding 把 binding tag 中的规则转换为 schema 约束，返回字段是否必填
+func applyBinding(s *Schema, binding string) (required bool) {
+	if binding == "" || s.Ref != "" {
+		return false
+	}
+
+	isString := s.Type == "string"
+	for _, rule := range strings.Split(binding, ",") {
+		kv := strings.SplitN(rule, "=", 2)
+		var param string
+		if len(kv) == 2 {
+			param = kv[1]
+		}
+
+		switch kv[0] {
+		case "required":
+			required = true
+		case "min", "gte":
+			if isString {
+				s.MinLength = parseUint(param)
+			} else {
+				s.Minimum = parseFloat(param)
+			}
+		case "max", "lte":
+			if isString {
+				s.MaxLength = parseUint(param)
+			} else {
+				s.Maximum = parseFloat(param)
+			}
+		case "len":
+			if isString {
+				s.MinLength, s.MaxLength = parseUint(param), parseUint(param)
+			}
+		case "oneof":
+			s.Enum = strings.Fields(param)
+		}
+	}
+
+	return required
+}
+
+// envelopeSchema 统一响应结构
+func envelopeSchema() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code": {Type: "integer", Description: "业务错误码，0 表示成功"},
+			"msg":  {Type: "string"},
+			"data": {Type: "object", Nullable: true},
+		},
+		Required: []string{"code", "msg", "data"},
+	}
+}
+
+// errorSchema 错误响应结构
+func errorSchema() *Schema {
+	return &Schema{
+		AllOf: []*Schema{
+			ref(envelopeName),
+			{
+				Properties: map[string]*Schema{
+					"trace_id": {Type: "string"},
+					"details": {
+						Type:        "array",
+						Description: "google.rpc 错误详情，例如 google.rpc.BadRequest",
+						Items:       &Schema{Type: "object"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func jsonContent(s *Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: s}}
+}
+
+// tagName 取 tag 中逗号前的名字
+func tagName(tag string) string {
+	return strings.SplitN(tag, ",", 2)[0]
+}
+
+// jsonName encoding/json 使用的字段名，protoc-gen-go 生成的 json tag 为 proto 字段名
+func jsonName(fd protoreflect.FieldDescriptor) string {
+	return string(fd.Name())
+}
+
+// goName 没有 form tag 时 gin 使用 Go 字段名绑定
+func goName(fd protoreflect.FieldDescriptor) string {
+	var b strings.Builder
+	for _, s := range strings.Split(string(fd.Name()), "_") {
+		if s == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(s[:1]) + s[1:])
+	}
+
+	return b.String()
+}
+
+func parseFloat(s string) *float64 {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil
+	}
+
+	return &f
+}
+
+func parseUint(s string) *uint64 {
+	u, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	return &u
+}
@@ -0,0 +1,43 @@
+package openapi
+
+import (
+	"testing"
+
+	demo_v0 "nautilus/api/demo/v0"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	doc := Generate("demo", "v0", demo_v0.File_demo_v0_demo_proto)
+
+	assert.Equal(t, []Tag{{Name: "BlogService"}}, doc.Tags)
+	assert.Len(t, doc.Paths, 2)
+
+	// additional_bindings
+	get := doc.Paths["/v1/author/{author_id}/articles"]["get"]
+	assert.NotNil(t, get)
+	assert.NotNil(t, doc.Paths["/v1/articles"]["get"])
+
+	params := map[string]*Parameter{}
+	for _, p := range get.Parameters {
+		params[p.Name] = p
+	}
+	assert.Equal(t, "path", params["author_id"].In)
+	assert.True(t, params["author_id"].Required)
+	assert.Equal(t, "query", params["page_size"].In)
+	assert.Equal(t, float64(1), *params["page_size"].Schema.Minimum)
+	assert.Equal(t, float64(100), *params["page_size"].Schema.Maximum)
+
+	// post 请求参数在 body 中
+	post := doc.Paths["/v1/author/{author_id}/articles"]["post"]
+	assert.NotNil(t, post)
+	assert.Len(t, post.Parameters, 1)
+	assert.Equal(t, "#/components/schemas/Article", post.RequestBody.Content["application/json"].Schema.Ref)
+
+	// {code,msg,data} 响应结构
+	assert.Equal(t, "#/components/schemas/Response", get.Responses["200"].Content["application/json"].Schema.AllOf[0].Ref)
+	resp := doc.Components.Schemas["GetArticlesResp"]
+	assert.Equal(t, "array", resp.Properties["articles"].Type)
+	assert.Equal(t, "#/components/schemas/Article", resp.Properties["articles"].Items.Ref)
+}
@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// bucket 令牌桶
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// full 令牌桶补满的时间，之后可以删除
+	full time.Time
+}
+
+// localLimiter 进程内令牌桶，多实例部署时每个实例单独计数
+type localLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+
+	// lastGC 上次清理空闲令牌桶的时间
+	lastGC time.Time
+}
+
+// NewLocal 创建进程内令牌桶限流器
+func NewLocal() Limiter {
+	return &localLimiter{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+// Allow 取一个令牌，令牌按照 Limit/Period 的速率补充，最多 Burst 个
+func (l *localLimiter) Allow(_ context.Context, key string, rate Rate) (Result, error) {
+	burst := float64(rate.Burst)
+	if burst <= 0 {
+		burst = float64(rate.Limit)
+	}
+	// 每纳秒补充的令牌数
+	speed := float64(rate.Limit) / float64(rate.Period)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.gc(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+float64(now.Sub(b.last))*speed)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		b.full = now.Add(time.Duration((burst - b.tokens) / speed))
+		return Result{Allowed: true}, nil
+	}
+
+	return Result{RetryAfter: time.Duration(math.Ceil((1 - b.tokens) / speed))}, nil
+}
+
+// gc 每分钟清理一次已经补满的令牌桶，避免 ip 之类的 key 无限增长
+// 补满的令牌桶删除后重新创建结果相同
+func (l *localLimiter) gc(now time.Time) {
+	if now.Sub(l.lastGC) < time.Minute {
+		return
+	}
+
+	for k, b := range l.buckets {
+		if now.After(b.full) {
+			delete(l.buckets, k)
+		}
+	}
+	l.lastGC = now
+}
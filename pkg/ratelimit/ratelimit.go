@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"nautilus/pkg/conf"
+)
+
+// 限流维度
+const (
+	// KeyRoute 按接口限流，所有调用方共享配额
+	KeyRoute = "route"
+	// KeyIP 按客户端 ip 限流
+	KeyIP = "ip"
+	// KeyAppkey 按 appkey 限流
+	KeyAppkey = "appkey"
+	// KeyUID 按登录用户限流，未登录时按 ip 限流
+	KeyUID = "uid"
+)
+
+// Rate 限流速率，Period 内最多 Limit 个请求
+// Burst 为令牌桶容量，只对本地限流生效，为 0 时等于 Limit
+type Rate struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+// ParseRate 解析速率，格式为 ${limit}/${period}，period 可以是 s/m/h 或者 time.Duration
+//
+//	10/s  100/m  1000/h  5/10s
+func ParseRate(s string) (r Rate, err error) {
+	parts := strings.SplitN(strings.TrimSpace(s), "/", 2)
+	if len(parts) != 2 {
+		return r, fmt.Errorf("ratelimit: invalid rate %q", s)
+	}
+
+	if r.Limit, err = strconv.Atoi(parts[0]); err != nil || r.Limit <= 0 {
+		return r, fmt.Errorf("ratelimit: invalid rate %q", s)
+	}
+
+	switch parts[1] {
+	case "s":
+		r.Period = time.Second
+	case "m":
+		r.Period = time.Minute
+	case "h":
+		r.Period = time.Hour
+	default:
+		if r.Period, err = time.ParseDuration(parts[1]); err != nil || r.Period <= 0 {
+			return r, fmt.Errorf("ratelimit: invalid rate %q", s)
+		}
+	}
+
+	return r, nil
+}
+
+// Result 限流结果
+type Result struct {
+	Allowed bool
+	// RetryAfter 被限流时，多久之后可以重试
+	RetryAfter time.Duration
+}
+
+// Limiter 限流器，key 为限流维度的值，例如 ip
+type Limiter interface {
+	Allow(ctx context.Context, key string, rate Rate) (Result, error)
+}
+
+// Policy 限流策略
+type Policy struct {
+	Name string
+	// Route 接口路由，和 gin 中注册的路由一致，例如 /api/v0/user/:id，* 表示所有接口
+	Route string
+	// Key 限流维度，route/ip/appkey/uid
+	Key  string
+	Rate Rate
+}
+
+// Match 判断策略是否作用于路由
+func (p Policy) Match(route string) bool {
+	return p.Route == "*" || p.Route == route
+}
+
+// FromConf 从配置中加载限流策略
+// RATELIMIT_POLICIES 为策略名称列表，每个策略的配置为
+//
+//	RATELIMIT_POLICY_${NAME}_ROUTE  接口路由，* 表示所有接口
+//	RATELIMIT_POLICY_${NAME}_KEY    限流维度 route/ip/appkey/uid，默认 ip
+//	RATELIMIT_POLICY_${NAME}_RATE   速率，例如 10/s
+//	RATELIMIT_POLICY_${NAME}_BURST  令牌桶容量，默认等于速率
+func FromConf() (policies []Policy, err error) {
+	for _, name := range conf.GetStrings("RATELIMIT_POLICIES") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		prefix := "RATELIMIT_POLICY_" + strings.ToUpper(name) + "_"
+		p := Policy{
+			Name:  name,
+			Route: conf.Get(prefix + "ROUTE"),
+			Key:   conf.Get(prefix + "KEY"),
+		}
+		if p.Route == "" {
+			return nil, fmt.Errorf("ratelimit: policy %s has no route", name)
+		}
+
+		switch p.Key {
+		case "":
+			p.Key = KeyIP
+		case KeyRoute, KeyIP, KeyAppkey, KeyUID:
+		default:
+			return nil, fmt.Errorf("ratelimit: policy %s has unknown key %q", name, p.Key)
+		}
+
+		if p.Rate, err = ParseRate(conf.Get(prefix + "RATE")); err != nil {
+			return nil, fmt.Errorf("ratelimit: policy %s: %w", name, err)
+		}
+		p.Rate.Burst = int(conf.GetInt64(prefix + "BURST"))
+
+		policies = append(policies, p)
+	}
+
+	return
+}
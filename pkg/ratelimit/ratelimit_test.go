@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRate(t *testing.T) {
+	r, err := ParseRate("10/s")
+	assert.Nil(t, err)
+	assert.Equal(t, Rate{Limit: 10, Period: time.Second}, r)
+
+	r, err = ParseRate("5/10s")
+	assert.Nil(t, err)
+	assert.Equal(t, Rate{Limit: 5, Period: 10 * time.Second}, r)
+
+	for _, s := range []string{"", "10", "0/s", "a/s", "10/x"} {
+		_, err = ParseRate(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestFromConf(t *testing.T) {
+	os.Setenv("RATELIMIT_POLICIES", "login,global")
+	os.Setenv("RATELIMIT_POLICY_LOGIN_ROUTE", "/api/v0/admin/login")
+	os.Setenv("RATELIMIT_POLICY_LOGIN_RATE", "5/m")
+	os.Setenv("RATELIMIT_POLICY_GLOBAL_ROUTE", "*")
+	os.Setenv("RATELIMIT_POLICY_GLOBAL_KEY", "uid")
+	os.Setenv("RATELIMIT_POLICY_GLOBAL_RATE", "100/s")
+	os.Setenv("RATELIMIT_POLICY_GLOBAL_BURST", "200")
+	defer func() {
+		for _, k := range []string{"POLICIES", "POLICY_LOGIN_ROUTE", "POLICY_LOGIN_RATE", "POLICY_GLOBAL_ROUTE",
+			"POLICY_GLOBAL_KEY", "POLICY_GLOBAL_RATE", "POLICY_GLOBAL_BURST"} {
+			os.Unsetenv("RATELIMIT_" + k)
+		}
+	}()
+
+	policies, err := FromConf()
+	assert.Nil(t, err)
+	assert.Equal(t, []Policy{
+		{Name: "login", Route: "/api/v0/admin/login", Key: KeyIP, Rate: Rate{Limit: 5, Period: time.Minute}},
+		{Name: "global", Route: "*", Key: KeyUID, Rate: Rate{Limit: 100, Period: time.Second, Burst: 200}},
+	}, policies)
+
+	os.Setenv("RATELIMIT_POLICY_GLOBAL_KEY", "device")
+	_, err = FromConf()
+	assert.NotNil(t, err)
+}
+
+func TestLocal(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLocal().(*localLimiter)
+	l.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	rate := Rate{Limit: 2, Period: time.Second, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		res, err := l.Allow(ctx, "a", rate)
+		assert.Nil(t, err)
+		assert.True(t, res.Allowed)
+	}
+
+	res, _ := l.Allow(ctx, "a", rate)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+
+	// 其他 key 不受影响
+	res, _ = l.Allow(ctx, "b", rate)
+	assert.True(t, res.Allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	res, _ = l.Allow(ctx, "a", rate)
+	assert.True(t, res.Allowed)
+	res, _ = l.Allow(ctx, "a", rate)
+	assert.False(t, res.Allowed)
+
+	// 补满之后清理
+	now = now.Add(2 * time.Minute)
+	res, _ = l.Allow(ctx, "c", rate)
+	assert.True(t, res.Allowed)
+	assert.Len(t, l.buckets, 1)
+}
+
+func TestRedis(t *testing.T) {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer client.Close()
+
+	now := time.Unix(100, 0)
+	l := NewRedis(client).(*redisLimiter)
+	l.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	rate := Rate{Limit: 2, Period: time.Second}
+
+	for i := 0; i < 2; i++ {
+		res, err := l.Allow(ctx, "a", rate)
+		assert.Nil(t, err)
+		assert.True(t, res.Allowed)
+		now = now.Add(100 * time.Millisecond)
+	}
+
+	res, err := l.Allow(ctx, "a", rate)
+	assert.Nil(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 800*time.Millisecond, res.RetryAfter)
+
+	// 第一个请求离开窗口
+	now = now.Add(800 * time.Millisecond)
+	res, _ = l.Allow(ctx, "a", rate)
+	assert.True(t, res.Allowed)
+	res, _ = l.Allow(ctx, "a", rate)
+	assert.False(t, res.Allowed)
+
+	assert.True(t, s.Exists("ratelimit:a"))
+}
@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// slidingWindow 滑动窗口日志，每个请求是 zset 中的一个成员，score 为请求时间(毫秒)
+// 先删除窗口之外的请求，窗口内请求数小于 limit 时记录本次请求，否则返回最早的请求离开窗口的时间
+var slidingWindow = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+if redis.call('ZCARD', key) < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, window)
+	return {1, 0}
+end
+
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+return {0, tonumber(oldest[2]) + window - now}
+`)
+
+// redisLimiter 基于 redis 的滑动窗口限流，多个实例共享配额
+type redisLimiter struct {
+	client redis.Scripter
+	prefix string
+	now    func() time.Time
+}
+
+// NewRedis 创建 redis 滑动窗口限流器，key 会加上 ratelimit: 前缀
+// 请求时间取各实例的本地时间，实例之间的时钟误差需要远小于限流周期
+func NewRedis(client redis.Scripter) Limiter {
+	return &redisLimiter{client: client, prefix: "ratelimit:", now: time.Now}
+}
+
+// Allow 滑动窗口内请求数不超过 Limit，不支持 Burst
+func (l *redisLimiter) Allow(ctx context.Context, key string, rate Rate) (Result, error) {
+	now := l.now()
+	member := fmt.Sprintf("%d-%d", now.UnixNano(), rand.Int63())
+
+	v, err := slidingWindow.Run(ctx, l.client, []string{l.prefix + key},
+		now.UnixNano()/int64(time.Millisecond), rate.Period.Milliseconds(), rate.Limit, member).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	if len(v) != 2 {
+		return Result{}, fmt.Errorf("ratelimit: unexpected script result %v", v)
+	}
+
+	return Result{Allowed: v[0] == 1, RetryAfter: time.Duration(v[1]) * time.Millisecond}, nil
+}
@@ -0,0 +1,148 @@
+package rbac
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"nautilus/api/auth"
+	"nautilus/pkg/conf"
+	"nautilus/pkg/ctxkit"
+	"nautilus/pkg/errors"
+	"nautilus/pkg/log"
+)
+
+// 接口需要的权限通过 proto 中的 (nautilus.auth.rule) 声明，
+// 角色拥有哪些权限由策略决定，策略可以配置在配置文件中，也可以存储在数据库中
+//
+//	# 角色列表
+//	RBAC_ROLES = "super,admin"
+//	# 角色拥有的权限，* 表示所有权限，admin:* 表示 admin: 开头的权限
+//	RBAC_ROLE_SUPER = "*"
+//	RBAC_ROLE_ADMIN = "admin:read,admin:logout"
+
+var errForbidden = errors.NewForbidden("permission denied").WithReason("PERMISSION_DENIED")
+
+// Policy 角色拥有的权限
+type Policy struct {
+	Role       string `db:"role"`
+	Permission string `db:"permission"`
+}
+
+// Loader 加载策略
+type Loader func(ctx context.Context) ([]Policy, error)
+
+// FromConf 从配置中加载策略
+func FromConf() Loader {
+	return func(ctx context.Context) ([]Policy, error) {
+		var ps []Policy
+		for _, role := range conf.GetStrings("RBAC_ROLES") {
+			role = strings.TrimSpace(role)
+			for _, p := range conf.GetStrings("RBAC_ROLE_" + strings.ToUpper(role)) {
+				if p = strings.TrimSpace(p); p != "" {
+					ps = append(ps, Policy{Role: role, Permission: p})
+				}
+			}
+		}
+
+		return ps, nil
+	}
+}
+
+// Enforcer 根据策略判断角色是否拥有权限
+type Enforcer struct {
+	loaders []Loader
+
+	mu    sync.RWMutex
+	roles map[string][]string
+}
+
+// NewEnforcer 创建 Enforcer，多个 loader 的策略会合并
+// 创建后需要调用 Load 加载策略
+func NewEnforcer(loaders ...Loader) *Enforcer {
+	return &Enforcer{loaders: loaders, roles: map[string][]string{}}
+}
+
+// Load 重新加载策略，任意一个 loader 出错时保留原来的策略
+func (e *Enforcer) Load(ctx context.Context) error {
+	roles := map[string][]string{}
+	for _, load := range e.loaders {
+		ps, err := load(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range ps {
+			roles[p.Role] = append(roles[p.Role], p.Permission)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.roles = roles
+	return nil
+}
+
+// Watch 定时重新加载策略，直到 ctx 结束
+func (e *Enforcer) Watch(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := e.Load(ctx); err != nil {
+				log.Get(ctx).WithError(err).Error("reload rbac policies failed")
+			}
+		}
+	}
+}
+
+// Allow 判断角色是否拥有权限
+func (e *Enforcer) Allow(role, permission string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, p := range e.roles[role] {
+		if match(p, permission) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check 根据接口的鉴权规则检查 ctx 中的登录用户，需要先经过 jwt.Authenticate
+// 未登录返回 401，没有权限返回 403
+func (e *Enforcer) Check(ctx context.Context, rule *auth.Rule) error {
+	if rule.GetPublic() {
+		return nil
+	}
+
+	if ctxkit.GetUID(ctx) == 0 {
+		return errors.NewAuthorization("login required").WithReason("LOGIN_REQUIRED")
+	}
+
+	permission := rule.GetPermission()
+	if permission == "" || e.Allow(ctxkit.GetRole(ctx), permission) {
+		return nil
+	}
+
+	return errForbidden.WithMetadata("permission", permission)
+}
+
+// match 判断策略中的权限是否匹配，支持 * 和 admin:* 前缀匹配
+func match(pattern, permission string) bool {
+	if pattern == "*" || pattern == permission {
+		return true
+	}
+
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(permission, pattern[:len(pattern)-1])
+	}
+
+	return false
+}
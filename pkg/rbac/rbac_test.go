@@ -0,0 +1,60 @@
+package rbac
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"nautilus/api/auth"
+	_ "nautilus/api/pension/v0"
+	"nautilus/pkg/ctxkit"
+	"nautilus/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func policies(ps ...Policy) Loader {
+	return func(ctx context.Context) ([]Policy, error) {
+		return ps, nil
+	}
+}
+
+func TestAllow(t *testing.T) {
+	e := NewEnforcer(
+		policies(Policy{Role: "super", Permission: "*"}),
+		policies(Policy{Role: "admin", Permission: "admin:read"}, Policy{Role: "admin", Permission: "article:*"}),
+	)
+	assert.False(t, e.Allow("super", "admin:create"))
+	assert.Nil(t, e.Load(context.TODO()))
+
+	assert.True(t, e.Allow("super", "admin:create"))
+	assert.True(t, e.Allow("admin", "admin:read"))
+	assert.True(t, e.Allow("admin", "article:delete"))
+	assert.False(t, e.Allow("admin", "admin:create"))
+	assert.False(t, e.Allow("", "admin:read"))
+}
+
+func TestCheck(t *testing.T) {
+	e := NewEnforcer(policies(Policy{Role: "super", Permission: "*"}))
+	assert.Nil(t, e.Load(context.TODO()))
+
+	rule := &auth.Rule{Permission: "admin:create"}
+	ctx := context.TODO()
+	assert.Nil(t, e.Check(ctx, &auth.Rule{Public: true}))
+	assert.Equal(t, http.StatusUnauthorized, errors.Status(e.Check(ctx, nil)))
+
+	ctx = ctxkit.WithRole(ctxkit.WithUID(ctx, 1), "admin")
+	assert.Nil(t, e.Check(ctx, nil))
+	assert.Equal(t, http.StatusForbidden, errors.Status(e.Check(ctx, rule)))
+
+	ctx = ctxkit.WithRole(ctx, "super")
+	assert.Nil(t, e.Check(ctx, rule))
+}
+
+func TestMethodRule(t *testing.T) {
+	assert.True(t, MethodRule("/AdminService/Login").GetPublic())
+	assert.Equal(t, "admin:create", MethodRule("/AdminService/CreateAdmin").GetPermission())
+	assert.Nil(t, MethodRule("/AdminService/Logout"))
+	assert.Nil(t, MethodRule("/AdminService/Unknown"))
+	assert.Nil(t, MethodRule("bad"))
+}
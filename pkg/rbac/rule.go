@@ -0,0 +1,54 @@
+package rbac
+
+import (
+	"strings"
+	"sync"
+
+	"nautilus/api/auth"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// rules grpc full method 对应的鉴权规则缓存
+var rules sync.Map
+
+// MethodRule 返回 grpc 方法在 proto 中声明的鉴权规则，没有声明时返回 nil
+// fullMethod 格式为 /package.Service/Method，HTTP 接口的规则由生成代码在注册路由时传入
+func MethodRule(fullMethod string) *auth.Rule {
+	if v, ok := rules.Load(fullMethod); ok {
+		return v.(*auth.Rule)
+	}
+
+	rule := lookup(fullMethod)
+	rules.Store(fullMethod, rule)
+	return rule
+}
+
+// lookup 从全局注册的 proto 描述中查找方法选项
+func lookup(fullMethod string) *auth.Rule {
+	name := strings.TrimPrefix(fullMethod, "/")
+	i := strings.LastIndex(name, "/")
+	if i < 0 {
+		return nil
+	}
+
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name[:i]))
+	if err != nil {
+		return nil
+	}
+
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil
+	}
+
+	md := sd.Methods().ByName(protoreflect.Name(name[i+1:]))
+	if md == nil {
+		return nil
+	}
+
+	rule, _ := proto.GetExtension(md.Options(), auth.E_Rule).(*auth.Rule)
+	return rule
+}
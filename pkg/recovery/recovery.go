@@ -0,0 +1,64 @@
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"nautilus/pkg/log"
+	"nautilus/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// panic 来源，作为监控指标的 kind
+const (
+	KindHTTP      = "http"
+	KindGRPC      = "grpc"
+	KindGoroutine = "goroutine"
+)
+
+// Handle 处理 recover 得到的 panic：记录堆栈日志，把 span 标记为出错，并增加 panic 计数
+// 需要在 defer 中调用，堆栈才包含 panic 的位置
+//
+//	defer func() {
+//		if p := recover(); p != nil {
+//			recovery.Handle(ctx, recovery.KindHTTP, p)
+//		}
+//	}()
+func Handle(ctx context.Context, kind string, p interface{}) {
+	stack := string(debug.Stack())
+
+	log.Get(ctx).WithFields(log.Fields{
+		"kind":  kind,
+		"panic": fmt.Sprint(p),
+		"stack": stack,
+	}).Error("panic recovered")
+
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(fmt.Errorf("panic: %v", p), trace.WithAttributes(semconv.ExceptionStacktraceKey.String(stack)))
+	span.SetStatus(codes.Error, fmt.Sprintf("panic: %v", p))
+
+	metrics.PanicCount.With(prometheus.Labels{"kind": kind}).Inc()
+}
+
+// SafeGo 启动后台协程，panic 时记录日志和监控，不会导致进程退出
+// 后台协程的生命周期一般比请求长，需要时由调用方传入和请求无关的 ctx
+//
+//	recovery.SafeGo(ctx, func(ctx context.Context) {
+//		_ = sendNotify(ctx, uid)
+//	})
+func SafeGo(ctx context.Context, fn func(ctx context.Context)) {
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				Handle(ctx, KindGoroutine, p)
+			}
+		}()
+
+		fn(ctx)
+	}()
+}
@@ -0,0 +1,59 @@
+package recovery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"nautilus/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestHandle(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	ctx, span := tp.Tracer("test").Start(context.Background(), "panic")
+
+	counter := metrics.PanicCount.With(prometheus.Labels{"kind": KindHTTP})
+	before := testutil.ToFloat64(counter)
+
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				Handle(ctx, KindHTTP, p)
+			}
+		}()
+		panic("boom")
+	}()
+	span.End()
+
+	assert.Equal(t, before+1, testutil.ToFloat64(counter))
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "panic: boom", spans[0].Status().Description)
+	assert.Len(t, spans[0].Events(), 1)
+}
+
+func TestSafeGo(t *testing.T) {
+	counter := metrics.PanicCount.With(prometheus.Labels{"kind": KindGoroutine})
+	before := testutil.ToFloat64(counter)
+
+	done := make(chan struct{})
+	SafeGo(context.Background(), func(ctx context.Context) {
+		defer close(done)
+		panic("boom")
+	})
+	<-done
+
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(counter) == before+1
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,115 @@
+package response
+
+import (
+	"strings"
+	"sync"
+
+	"nautilus/pkg/conf"
+)
+
+// defaultLang 默认语言，可以通过 RESP_DEFAULT_LANG 配置
+const defaultLang = "zh"
+
+var (
+	mu sync.RWMutex
+
+	// messages 业务错误码文案，格式 lang => code => msg
+	messages = map[string]map[int]string{}
+
+	// builtin 内置文案
+	builtin = map[string]map[string]string{
+		"zh": {
+			msgOK:      "成功",
+			msgUnknown: "未知错误",
+			msgParams:  "参数错误",
+		},
+		"en": {
+			msgOK:      "success",
+			msgUnknown: "unknown error",
+			msgParams:  "invalid params",
+		},
+	}
+)
+
+const (
+	msgOK      = "ok"
+	msgUnknown = "unknown"
+	msgParams  = "params"
+)
+
+// RegisterMessages 注册错误码文案，同一个 code 重复注册会覆盖
+// 一般在 init() 中调用
+//
+//	response.RegisterMessages("en", map[int]string{10001: "user not found"})
+func RegisterMessages(lang string, msgs map[int]string) {
+	lang = strings.ToLower(lang)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	m, ok := messages[lang]
+	if !ok {
+		m = map[int]string{}
+		messages[lang] = m
+	}
+
+	for code, msg := range msgs {
+		m[code] = msg
+	}
+}
+
+// localize 查找 code 对应语言的文案
+func localize(lang string, code int) (string, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	msg, ok := messages[lang][code]
+	return msg, ok
+}
+
+// text 内置文案，找不到对应语言时使用默认语言
+func text(lang, key string) string {
+	if msg, ok := builtin[lang][key]; ok {
+		return msg
+	}
+
+	return builtin[defaultLang][key]
+}
+
+// language 根据 Accept-Language 选择语言，没有匹配时使用默认语言
+// Accept-Language: en-US,en;q=0.9,zh;q=0.8
+func language(acceptLanguage string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, tag := range strings.Split(acceptLanguage, ",") {
+		tag = strings.ToLower(strings.TrimSpace(strings.SplitN(tag, ";", 2)[0]))
+		if tag == "" {
+			continue
+		}
+
+		if supported(tag) {
+			return tag
+		}
+
+		if primary := strings.SplitN(tag, "-", 2)[0]; supported(primary) {
+			return primary
+		}
+	}
+
+	if lang := strings.ToLower(conf.Get("RESP_DEFAULT_LANG")); lang != "" {
+		return lang
+	}
+
+	return defaultLang
+}
+
+// supported 是否支持该语言
+func supported(lang string) bool {
+	_, ok := messages[lang]
+	if !ok {
+		_, ok = builtin[lang]
+	}
+
+	return ok
+}
@@ -0,0 +1,166 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"nautilus/pkg/ctxkit"
+	xerrors "nautilus/pkg/errors"
+	"nautilus/pkg/validate"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	// CodeOK 成功
+	CodeOK = 0
+	// CodeUnknown 未知错误
+	CodeUnknown = -1
+	// CodeParams 参数错误
+	CodeParams = 400
+)
+
+// Writer 项目统一的响应处理，返回 {code,msg,data} 格式
+// 实现了生成代码中 resp 的接口，可以通过 Register${Service}HTTPServerWithResp 注入
+//
+//	demo_v0.RegisterBlogServiceHTTPServerWithResp(router, &DemoServer{}, response.Writer{})
+type Writer struct{}
+
+// body 响应结构体，出错时带上 trace_id 方便排查
+// details 为 google.rpc 错误详情，例如参数错误时的 google.rpc.BadRequest
+type body struct {
+	Code    int               `json:"code"`
+	Msg     string            `json:"msg"`
+	Data    interface{}       `json:"data"`
+	TraceID string            `json:"trace_id,omitempty"`
+	Details []json.RawMessage `json:"details,omitempty"`
+}
+
+// iCode 业务错误，pkg/errors.Error 实现了该接口
+type iCode interface {
+	HTTPCode() int
+	Msg() string
+	Code() int
+}
+
+// Success 返回成功信息
+func (w Writer) Success(c *gin.Context, data interface{}) {
+	c.JSON(http.StatusOK, body{Code: CodeOK, Msg: text(language(c.GetHeader("Accept-Language")), msgOK), Data: data})
+}
+
+// Error 返回错误信息
+// 业务错误按照错误码查找本地化文案，找不到时使用错误自带的信息
+func (w Writer) Error(c *gin.Context, err error) {
+	if err != nil {
+		_ = c.Error(err)
+	}
+
+	status, b := errorBody(c.Request, err)
+	c.JSON(status, b)
+}
+
+// Marshal 生成错误响应的状态码和 JSON，格式和 Writer.Error 一致
+// 用于不能使用 gin.Context 的地方，例如超时后在其他协程中返回错误
+func Marshal(r *http.Request, err error) (status int, data []byte) {
+	status, b := errorBody(r, err)
+	data, _ = json.Marshal(b)
+	return
+}
+
+// errorBody 错误响应，带上 trace_id
+func errorBody(r *http.Request, err error) (int, body) {
+	lang := language(r.Header.Get("Accept-Language"))
+	status := http.StatusInternalServerError
+	code := CodeUnknown
+	msg := text(lang, msgUnknown)
+
+	var e iCode
+	if err != nil && errors.As(err, &e) {
+		status = e.HTTPCode()
+		code = e.Code()
+
+		if m, ok := localize(lang, code); ok {
+			msg = m
+		} else if m := e.Msg(); m != "" {
+			msg = m
+		}
+	}
+
+	return status, body{
+		Code:    code,
+		Msg:     msg,
+		TraceID: ctxkit.GetTraceID(r.Context()),
+		Details: details(err),
+	}
+}
+
+// ParamsError 参数错误，返回每个字段的校验失败信息
+func (w Writer) ParamsError(c *gin.Context, err error) {
+	e := validate.FromParams(err, params(c))
+	_ = c.Error(e)
+
+	w.abort(c, http.StatusBadRequest, CodeParams, text(language(c.GetHeader("Accept-Language")), msgParams), details(e))
+}
+
+// params 请求的 query/form/uri 参数，用于定位类型错误的字段
+func params(c *gin.Context) url.Values {
+	values := url.Values{}
+	for k, vs := range c.Request.URL.Query() {
+		values[k] = vs
+	}
+	for k, vs := range c.Request.PostForm {
+		values[k] = append(values[k], vs...)
+	}
+	for _, p := range c.Params {
+		values.Add(p.Key, p.Value)
+	}
+
+	return values
+}
+
+// Abort 中间件中直接返回错误，不再执行后续 handler
+func Abort(c *gin.Context, err error) {
+	Writer{}.Error(c, err)
+	c.Abort()
+}
+
+// abort 返回错误信息，带上 trace_id
+func (w Writer) abort(c *gin.Context, status, code int, msg string, details []json.RawMessage) {
+	c.JSON(status, body{
+		Code:    code,
+		Msg:     msg,
+		TraceID: ctxkit.GetTraceID(c.Request.Context()),
+		Details: details,
+	})
+}
+
+// details 把错误详情编码为 JSON，格式和 grpc-gateway 一致
+//
+//	{"@type": "type.googleapis.com/google.rpc.BadRequest", "fieldViolations": [...]}
+func details(err error) []json.RawMessage {
+	var e *xerrors.Error
+	if !errors.As(err, &e) {
+		return nil
+	}
+
+	var ds []json.RawMessage
+	for _, d := range e.Details {
+		a, err := anypb.New(d)
+		if err != nil {
+			continue
+		}
+
+		b, err := protojson.Marshal(a)
+		if err != nil {
+			continue
+		}
+
+		ds = append(ds, b)
+	}
+
+	return ds
+}
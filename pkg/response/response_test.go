@@ -0,0 +1,94 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	demo_v0 "nautilus/api/demo/v0"
+	"nautilus/pkg/ctxkit"
+	"nautilus/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func do(t *testing.T, lang string, handler gin.HandlerFunc) (int, body) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(ctxkit.WithTraceID(c.Request.Context(), "trace-1"))
+		handler(c)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", lang)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var b body
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &b))
+	return w.Code, b
+}
+
+func TestWriter(t *testing.T) {
+	RegisterMessages("en", map[int]string{10001: "user not found"})
+
+	status, b := do(t, "en-US,en;q=0.9", func(c *gin.Context) {
+		Writer{}.Success(c, "data")
+	})
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, body{Code: CodeOK, Msg: "success", Data: "data"}, b)
+
+	// 业务错误，使用本地化文案
+	status, b = do(t, "en", func(c *gin.Context) {
+		Writer{}.Error(c, errors.NewNotFound("user", "1").WithCode(10001))
+	})
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, body{Code: 10001, Msg: "user not found", TraceID: "trace-1"}, b)
+
+	// 没有本地化文案时使用错误自带的信息
+	status, b = do(t, "zh-CN", func(c *gin.Context) {
+		Writer{}.Error(c, errors.NewBadRequest("bad title"))
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, body{Code: 400, Msg: "bad title", TraceID: "trace-1"}, b)
+
+	// 非业务错误
+	status, b = do(t, "", func(c *gin.Context) {
+		Writer{}.Error(c, http.ErrBodyNotAllowed)
+	})
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Equal(t, body{Code: CodeUnknown, Msg: "未知错误", TraceID: "trace-1"}, b)
+}
+
+func TestParamsError(t *testing.T) {
+	status, b := do(t, "en", func(c *gin.Context) {
+		var in demo_v0.GetArticlesReq
+		c.Request.URL.RawQuery = "page=0&page_size=1000"
+		Writer{}.ParamsError(c, c.ShouldBindQuery(&in))
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, CodeParams, b.Code)
+	assert.Equal(t, "invalid params", b.Msg)
+	assert.Len(t, b.Details, 1)
+	assert.JSONEq(t, `{
+		"@type": "type.googleapis.com/google.rpc.BadRequest",
+		"fieldViolations": [{"field": "page_size", "description": "must be less than or equal to 100"}]
+	}`, string(b.Details[0]))
+}
+
+func TestParamsError_Type(t *testing.T) {
+	status, b := do(t, "en", func(c *gin.Context) {
+		var in demo_v0.GetArticlesReq
+		c.Request.URL.RawQuery = "title=foo&page=abc"
+		Writer{}.ParamsError(c, c.ShouldBindQuery(&in))
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Len(t, b.Details, 1)
+	assert.JSONEq(t, `{
+		"@type": "type.googleapis.com/google.rpc.BadRequest",
+		"fieldViolations": [{"field": "page", "description": "must be an integer"}]
+	}`, string(b.Details[0]))
+}
@@ -0,0 +1,50 @@
+package sign
+
+import (
+	"sync"
+	"time"
+)
+
+// NonceStore 记录使用过的 nonce，防止请求重放
+type NonceStore interface {
+	// Use 标记 nonce 已使用，ttl 内重复使用返回 false
+	Use(key string, ttl time.Duration) bool
+}
+
+// memoryNonceStore 内存 nonce 存储，多实例部署时需要替换为共享存储
+type memoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	now    func() time.Time
+
+	// lastGC 上次清理过期 nonce 的时间
+	lastGC time.Time
+}
+
+// NewMemoryNonceStore 创建内存 nonce 存储
+func NewMemoryNonceStore() NonceStore {
+	return &memoryNonceStore{nonces: map[string]time.Time{}, now: time.Now}
+}
+
+// Use 标记 nonce 已使用
+func (s *memoryNonceStore) Use(key string, ttl time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastGC) > ttl {
+		for k, expire := range s.nonces {
+			if now.After(expire) {
+				delete(s.nonces, k)
+			}
+		}
+		s.lastGC = now
+	}
+
+	if expire, ok := s.nonces[key]; ok && now.Before(expire) {
+		return false
+	}
+
+	s.nonces[key] = now.Add(ttl)
+	return true
+}
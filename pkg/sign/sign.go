@@ -0,0 +1,188 @@
+package sign
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"nautilus/pkg/ctxkit"
+)
+
+// 请求签名
+//
+// 客户端在 header 或者 query 中带上 appkey/ts/nonce/sign，header 优先
+// sign = hex(hmac_sha256(secret, canonical))，canonical 为以下内容用 \n 拼接:
+//
+//	METHOD
+//	PATH
+//	排序后的 query，不包含 sign，格式为 a=1&b=2
+//	appkey
+//	ts
+//	nonce
+//	access_key
+//	platform
+//	version
+//	device
+//	hex(sha256(body))
+//
+// access_key/platform/version/device 同样可以放在 header 中，签名使用 header 优先之后的值，没有时为空行
+// secret 按照 appkey 配置，格式为 SIGN_APP_${APPKEY}_SECRET
+
+// 参数名，header 为 X-${Name}，query 为 ${name}
+const (
+	Appkey    = "appkey"
+	TS        = "ts"
+	Nonce     = "nonce"
+	Sign      = "sign"
+	AccessKey = "access_key"
+	Platform  = "platform"
+	Version   = "version"
+	Device    = "device"
+)
+
+// Params 请求中的公共参数
+type Params struct {
+	Appkey    string
+	TS        string
+	Nonce     string
+	Sign      string
+	AccessKey string
+	Platform  string
+	Version   string
+	Device    string
+}
+
+// FromRequest 从 header 或者 query 中提取公共参数
+func FromRequest(r *http.Request) Params {
+	q := r.URL.Query()
+	get := func(name string) string {
+		if v := r.Header.Get(header(name)); v != "" {
+			return v
+		}
+
+		return q.Get(name)
+	}
+
+	return Params{
+		Appkey:    get(Appkey),
+		TS:        get(TS),
+		Nonce:     get(Nonce),
+		Sign:      get(Sign),
+		AccessKey: get(AccessKey),
+		Platform:  get(Platform),
+		Version:   get(Version),
+		Device:    get(Device),
+	}
+}
+
+// WithContext 把公共参数写入 ctxkit，需要在签名校验通过之后调用
+func (p Params) WithContext(ctx context.Context) context.Context {
+	ctx = ctxkit.WithAppkey(ctx, p.Appkey)
+	ctx = ctxkit.WithTS(ctx, p.TS)
+	ctx = ctxkit.WithSign(ctx, p.Sign)
+	ctx = ctxkit.WithAccessKey(ctx, p.AccessKey)
+	if p.Platform != "" {
+		ctx = ctxkit.WithPlatform(ctx, p.Platform)
+	}
+	if p.Version != "" {
+		ctx = ctxkit.WithVersion(ctx, p.Version)
+	}
+	if p.Device != "" {
+		ctx = ctxkit.WithDevice(ctx, p.Device)
+	}
+
+	return ctx
+}
+
+// Time 解析时间戳，单位秒
+func (p Params) Time() (time.Time, bool) {
+	ts, err := strconv.ParseInt(p.TS, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(ts, 0), true
+}
+
+// Canonical 生成待签名的字符串
+func Canonical(r *http.Request, body []byte, p Params) string {
+	q := r.URL.Query()
+	q.Del(Sign)
+
+	sum := sha256.Sum256(body)
+	return strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		canonicalQuery(q),
+		p.Appkey,
+		p.TS,
+		p.Nonce,
+		p.AccessKey,
+		p.Platform,
+		p.Version,
+		p.Device,
+		hex.EncodeToString(sum[:]),
+	}, "\n")
+}
+
+// Compute 计算签名
+func Compute(secret, canonical string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(canonical))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Equal 常量时间比较签名，避免时序攻击
+func Equal(a, b string) bool {
+	return hmac.Equal([]byte(a), []byte(b))
+}
+
+// SignRequest 客户端给请求签名，设置 X-Appkey/X-Ts/X-Nonce/X-Sign header
+// body 需要和实际发送的内容一致，access_key/platform/version/device 需要在签名之前设置好
+func SignRequest(r *http.Request, body []byte, appkey, secret, nonce string) {
+	p := FromRequest(r)
+	p.Appkey, p.TS, p.Nonce = appkey, strconv.FormatInt(time.Now().Unix(), 10), nonce
+	p.Sign = Compute(secret, Canonical(r, body, p))
+
+	r.Header.Set(header(Appkey), p.Appkey)
+	r.Header.Set(header(TS), p.TS)
+	r.Header.Set(header(Nonce), p.Nonce)
+	r.Header.Set(header(Sign), p.Sign)
+}
+
+// canonicalQuery 按照 key 排序，同一个 key 的多个值也排序
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		vs := append([]string(nil), q[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(k))
+			b.WriteByte('=')
+			b.WriteString(url.QueryEscape(v))
+		}
+	}
+
+	return b.String()
+}
+
+// header 参数对应的 header 名，例如 access_key => X-Access-Key
+func header(name string) string {
+	return "X-" + strings.ReplaceAll(name, "_", "-")
+}
@@ -0,0 +1,27 @@
+package sign
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonical(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/articles?page=1&b=2&b=1&sign=xxx&title=a%20b", nil)
+	p := Params{Appkey: "test", TS: "1600000000", Nonce: "n1", Platform: "ios", Version: "1.0.0"}
+
+	assert.Equal(t, "GET\n/v1/articles\nb=1&b=2&page=1&title=a+b\ntest\n1600000000\nn1\n\nios\n1.0.0\n\n"+
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", Canonical(r, nil, p))
+}
+
+func TestFromRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/?appkey=query&access_key=token&device=iPhone", nil)
+	r.Header.Set("X-Appkey", "header")
+
+	p := FromRequest(r)
+	assert.Equal(t, "header", p.Appkey)
+	assert.Equal(t, "token", p.AccessKey)
+	assert.Equal(t, "iPhone", p.Device)
+}
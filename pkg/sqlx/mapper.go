@@ -79,6 +79,42 @@ func Get(ctx context.Context, name string) *DB {
 	return v.(*DB)
 }
 
+// Open 使用指定的驱动创建名为 name 的 DB 连接池，之后通过 Get(ctx, name) 获取
+// 一般用于单元测试，例如使用 sqlite 内存数据库代替 mysql
+//
+//	import _ "github.com/mattn/go-sqlite3"
+//	db, err := sqlx.Open("pension", "sqlite3", "file::memory:?cache=shared")
+func Open(name, driverName, dsn string) (*DB, error) {
+	sdb, err := sqlx.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &DB{sdb}
+
+	rwl.Lock()
+	defer rwl.Unlock()
+	if old, ok := dbs[name]; ok {
+		old.Close()
+	}
+	dbs[name] = db
+
+	return db, nil
+}
+
+// All 已经创建的所有 DB 连接池，key 为配置名字，用于健康检查等
+func All() map[string]*DB {
+	rwl.RLock()
+	defer rwl.RUnlock()
+
+	m := make(map[string]*DB, len(dbs))
+	for name, db := range dbs {
+		m[name] = db
+	}
+
+	return m
+}
+
 // MustBegin 封装 sqlx.DB.MustBegin
 func (db *DB) MustBegin() *Tx {
 	tx := db.DB.MustBegin()
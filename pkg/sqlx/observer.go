@@ -14,6 +14,9 @@ import (
 	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
+// logger sqlx 的日志，可以通过 LOG_LEVEL_SQLX 单独设置日志等级
+var logger = log.Named("sqlx")
+
 // observer 拦截器：观察所有sql执行情况
 // 执行SQL前会回调对应的函数
 // 实现自 github.com/ngrok/sqlmw::Interceptor
@@ -117,7 +120,7 @@ func (o observer) StmtExecContext(ctx context.Context, stmt driver.StmtExecConte
 	result, err = stmt.ExecContext(ctx, args)
 	d := time.Since(s)
 
-	log.Get(ctx).Debugf("[sqlx] name: %s exec stmt: %s, args: %v, cost: %v",
+	logger.Get(ctx).Debugf("[sqlx] name: %s exec stmt: %s, args: %v, cost: %v",
 		o.name, query, values(args), d)
 
 	table, cmd := parseSQL(query)
@@ -143,7 +146,7 @@ func (o observer) StmtQueryContext(ctx context.Context, stmt driver.StmtQueryCon
 	rows, err = stmt.QueryContext(ctx, args)
 	d := time.Since(s)
 
-	log.Get(ctx).Debugf("[sqlx] name: %s, query stmt: %s, args: %v, cost: %v",
+	logger.Get(ctx).Debugf("[sqlx] name: %s, query stmt: %s, args: %v, cost: %v",
 		o.name, query, values(args), d)
 
 	table, cmd := parseSQL(query)
@@ -167,7 +170,7 @@ func (o observer) ConnBeginTx(ctx context.Context, conn driver.ConnBeginTx, txOp
 	tx, err = conn.BeginTx(ctx, txOpts)
 	d := time.Since(s)
 
-	log.Get(ctx).Debugf("[sqlx] name: %s, begin, cost: %v", o.name, d)
+	logger.Get(ctx).Debugf("[sqlx] name: %s, begin, cost: %v", o.name, d)
 	sqlDurations.WithLabelValues(o.name, "", "begin").Observe(d.Seconds())
 	onSpanErr(span, err)
 	return
@@ -185,7 +188,7 @@ func (o observer) TxCommit(ctx context.Context, tx driver.Tx) (err error) {
 	err = tx.Commit()
 	d := time.Since(s)
 
-	log.Get(ctx).Debugf("[sqlx] name: %s, commit, cost: %v", o.name, d)
+	logger.Get(ctx).Debugf("[sqlx] name: %s, commit, cost: %v", o.name, d)
 	sqlDurations.WithLabelValues(o.name, "", "commit").Observe(d.Seconds())
 	onSpanErr(span, err)
 	return
@@ -203,7 +206,7 @@ func (o observer) TxRollback(ctx context.Context, tx driver.Tx) (err error) {
 	err = tx.Rollback()
 	d := time.Since(s)
 
-	log.Get(ctx).Debugf("[sqldb] name:%s, rollback, cost: %v", o.name, d)
+	logger.Get(ctx).Debugf("[sqldb] name:%s, rollback, cost: %v", o.name, d)
 
 	sqlDurations.WithLabelValues(o.name, "", "rollback").Observe(d.Seconds())
 	onSpanErr(span, err)
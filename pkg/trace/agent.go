@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"nautilus/pkg/conf"
 	"nautilus/pkg/log"
@@ -19,57 +22,95 @@ import (
 )
 
 const (
-	kindJaeger = "jaeger"
-	kindZipkin = "zipkin"
+	kindJaeger   = "jaeger"
+	kindZipkin   = "zipkin"
+	kindOTLPGRPC = "otlp-grpc"
+	kindOTLPHTTP = "otlp-http"
 )
 
-// tp 全局TracerProvider
-var tp *sdktrace.TracerProvider
+var (
+	// tp 全局TracerProvider
+	tp *sdktrace.TracerProvider
+	mu sync.Mutex
 
-func init() {
-	ctx := context.TODO()
-	config := &Config{
-		Name:     "miniapp",
-		Endpoint: conf.Get("OTEL_AGENT_ENDPOINT"),
-		Sampler:  conf.GetFloat64("OTEL_AGENT_SAMPLE"),
-		Batcher:  conf.Get("OTEL_AGENT_BATCH"),
-	}
+	// lastExport 最近一次上报的结果，参考 ExportError
+	lastExport atomic.Value
+)
 
-	if err := startAgent(ctx, config); err != nil {
-		log.Get(ctx).Errorf("[otel] init agent err: %v", err)
-	}
+// exportResult atomic.Value 不能保存 nil，包一层
+type exportResult struct {
+	err error
 }
 
-// startAgent 创建一个 Tracer Provider
-func startAgent(ctx context.Context, c *Config) error {
+// Init 创建全局的 TracerProvider，设置 propagator，需要在 main 中创建 gin/grpc server 之前调用
+// 重复调用时会先停止之前的 TracerProvider；Endpoint 为空时只生成 trace id，不上报
+//
+//	if err := trace.Init(ctx, trace.ConfigFromConf()); err != nil {
+//		panic(err)
+//	}
+//	defer trace.Stop()
+func Init(ctx context.Context, c *Config) error {
+	c.setDefaults()
+
+	s, err := newSampler(c)
+	if err != nil {
+		return err
+	}
+
 	opts := []sdktrace.TracerProviderOption{
-		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.Sampler))),
-		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceNameKey.String(c.Name))),
+		sdktrace.WithSampler(s),
+		sdktrace.WithResource(newResource(c)),
 	}
 
 	if len(c.Endpoint) > 0 {
-		exporter, err := createExporter(c.Batcher, c.Endpoint)
+		exporter, err := createExporter(ctx, c)
 		if err != nil {
 			return err
 		}
 
-		opts = append(opts, sdktrace.WithBatcher(exporter))
+		opts = append(opts, sdktrace.WithBatcher(statusExporter{exporter}))
 	}
+	lastExport.Store(exportResult{})
 
-	tp = sdktrace.NewTracerProvider(opts...)
-	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{}, propagation.Baggage{}))
+	provider := sdktrace.NewTracerProvider(opts...)
+
+	mu.Lock()
+	old := tp
+	tp = provider
+	mu.Unlock()
+	if old != nil {
+		_ = old.Shutdown(ctx)
+	}
+
+	otel.SetTracerProvider(provider)
+	propagators := []propagation.TextMapPropagator{propagation.TraceContext{}, propagation.Baggage{}}
+	if c.B3 {
+		propagators = append(propagators, b3Propagator{})
+	}
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagators...))
 	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
-		log.Get(ctx).Errorf("[otel] error: %v", err)
+		log.Get(context.Background()).Errorf("[otel] error: %v", err)
 	}))
 
 	return nil
 }
 
-// createExporter 选择opentelemetry的后端，目前只支持jaeger/zipkin
-func createExporter(batcher string, endpoint string) (exporter sdktrace.SpanExporter, err error) {
-	switch batcher {
+// newResource 服务信息，所有 span 共享
+func newResource(c *Config) *resource.Resource {
+	return resource.NewSchemaless(
+		semconv.ServiceNameKey.String(c.Name),
+		semconv.ServiceVersionKey.String(conf.Version),
+		semconv.ServiceInstanceIDKey.String(conf.Hostname),
+		semconv.DeploymentEnvironmentKey.String(conf.Env),
+		semconv.HostNameKey.String(conf.Hostname),
+		semconv.CloudAvailabilityZoneKey.String(conf.Zone),
+	)
+}
+
+// createExporter 选择opentelemetry的后端，支持jaeger/zipkin/otlp-grpc/otlp-http
+func createExporter(ctx context.Context, c *Config) (exporter sdktrace.SpanExporter, err error) {
+	endpoint := c.Endpoint
+	switch c.Batcher {
 	case kindJaeger:
 		var opt jaeger.EndpointOption
 		if strings.HasPrefix(endpoint, "http") {
@@ -87,18 +128,49 @@ func createExporter(batcher string, endpoint string) (exporter sdktrace.SpanExpo
 		return jaeger.New(opt)
 	case kindZipkin:
 		return zipkin.New(endpoint)
+	case kindOTLPGRPC:
+		return newOTLPGRPC(ctx, endpoint, c.Headers)
+	case kindOTLPHTTP:
+		return newOTLPHTTP(ctx, endpoint, c.Headers)
 	default:
-		return nil, fmt.Errorf("unsupport exporter: %s", batcher)
+		return nil, fmt.Errorf("unsupport exporter: %s", c.Batcher)
 	}
 }
 
-// Stop tracer provider shutdown
+// statusExporter 记录每次上报的结果，用于健康检查
+type statusExporter struct {
+	sdktrace.SpanExporter
+}
+
+// ExportSpans 上报 span 并记录结果
+func (e statusExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	lastExport.Store(exportResult{err: err})
+	return err
+}
+
+// ExportError 最近一次上报 span 的错误，没有配置后端、还没有上报过或者上报成功时返回 nil
+// 参考 health.TraceExporter
+func ExportError() error {
+	r, _ := lastExport.Load().(exportResult)
+	return r.err
+}
+
+// Stop 上报缓冲的 span 之后停止 tracer provider，最多等待 10s
 func Stop() {
-	if tp == nil {
+	mu.Lock()
+	provider := tp
+	tp = nil
+	mu.Unlock()
+	if provider == nil {
 		return
 	}
 
-	tp.Shutdown(context.TODO())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := provider.Shutdown(ctx); err != nil {
+		log.Get(ctx).Errorf("[otel] shutdown err: %v", err)
+	}
 }
 
 // GetTraceID 提取trace id
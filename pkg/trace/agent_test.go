@@ -0,0 +1,148 @@
+package trace
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"nautilus/pkg/conf"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// exportedSpans 返回 resource 属性的 key 和 span 名
+func exportedSpans(req *coltracepb.ExportTraceServiceRequest) (keys []string, names []string) {
+	for _, rs := range req.ResourceSpans {
+		for _, kv := range rs.GetResource().GetAttributes() {
+			keys = append(keys, kv.Key)
+		}
+		for _, ils := range rs.InstrumentationLibrarySpans {
+			for _, span := range ils.Spans {
+				names = append(names, span.Name)
+			}
+		}
+	}
+	return
+}
+
+// traceService 接收 otlp/grpc 上报的 collector
+type traceService struct {
+	coltracepb.UnimplementedTraceServiceServer
+	reqs chan *coltracepb.ExportTraceServiceRequest
+	md   chan metadata.MD
+}
+
+func (s *traceService) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.md <- md
+	s.reqs <- req
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+func TestConfigFromConf(t *testing.T) {
+	c := ConfigFromConf()
+	assert.Equal(t, conf.AppID, c.Name)
+	assert.Equal(t, float64(1), c.Sampler)
+	assert.Empty(t, c.Routes)
+
+	os.Setenv("OTEL_AGENT_SAMPLE", "0")
+	os.Setenv("OTEL_SAMPLER", "ratelimit")
+	os.Setenv("OTEL_SAMPLER_RATE", "100/s")
+	os.Setenv("OTEL_SAMPLER_ROUTES", "/metrics=0, /api/v0/report=0.5,/bad=x")
+	os.Setenv("OTEL_AGENT_HEADERS", "authorization=Bearer x")
+	defer func() {
+		for _, k := range []string{"OTEL_AGENT_SAMPLE", "OTEL_SAMPLER", "OTEL_SAMPLER_RATE", "OTEL_SAMPLER_ROUTES", "OTEL_AGENT_HEADERS"} {
+			os.Unsetenv(k)
+		}
+	}()
+
+	c = ConfigFromConf()
+	assert.Equal(t, float64(0), c.Sampler)
+	assert.Equal(t, SamplerRateLimit, c.SamplerType)
+	assert.Equal(t, 100, c.Rate.Limit)
+	assert.Equal(t, map[string]float64{"/metrics": 0, "/api/v0/report": 0.5}, c.Routes)
+	assert.Equal(t, map[string]string{"authorization": "Bearer x"}, c.Headers)
+}
+
+func TestInitOTLPHTTP(t *testing.T) {
+	defer SetSampleRatio(SampleRatio())
+
+	reqs := make(chan *coltracepb.ExportTraceServiceRequest, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer x", r.Header.Get("Authorization"))
+
+		body, _ := ioutil.ReadAll(r.Body)
+		req := &coltracepb.ExportTraceServiceRequest{}
+		assert.Nil(t, proto.Unmarshal(body, req))
+		reqs <- req
+	}))
+	defer ts.Close()
+
+	err := Init(context.Background(), &Config{
+		Endpoint: ts.URL,
+		Batcher:  kindOTLPHTTP,
+		Headers:  map[string]string{"Authorization": "Bearer x"},
+		Sampler:  1,
+	})
+	assert.Nil(t, err)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "/api/v0/users")
+	span.SetStatus(codes.Error, "boom")
+	span.End()
+	Stop()
+
+	keys, names := exportedSpans(<-reqs)
+	assert.Equal(t, []string{"/api/v0/users"}, names)
+	assert.Subset(t, keys, []string{"service.name", "service.version", "deployment.environment", "host.name", "cloud.availability_zone"})
+}
+
+func TestInitOTLPGRPC(t *testing.T) {
+	defer SetSampleRatio(SampleRatio())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	svc := &traceService{
+		reqs: make(chan *coltracepb.ExportTraceServiceRequest, 1),
+		md:   make(chan metadata.MD, 1),
+	}
+	srv := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(srv, svc)
+	go srv.Serve(ln)
+	defer srv.Stop()
+
+	err = Init(context.Background(), &Config{
+		Endpoint: ln.Addr().String(),
+		Batcher:  kindOTLPGRPC,
+		Headers:  map[string]string{"token": "x"},
+		Sampler:  1,
+	})
+	assert.Nil(t, err)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "/demo.v1.Demo/Hello")
+	span.End()
+	Stop()
+
+	assert.Equal(t, []string{"x"}, (<-svc.md).Get("token"))
+	_, names := exportedSpans(<-svc.reqs)
+	assert.Equal(t, []string{"/demo.v1.Demo/Hello"}, names)
+}
+
+func TestInitInvalid(t *testing.T) {
+	defer SetSampleRatio(SampleRatio())
+
+	assert.NotNil(t, Init(context.Background(), &Config{Endpoint: "127.0.0.1:1", Batcher: "unknown", Sampler: 1}))
+	assert.NotNil(t, Init(context.Background(), &Config{SamplerType: "unknown"}))
+}
@@ -2,8 +2,8 @@ package trace
 
 import (
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/codes"
 	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"google.golang.org/grpc/codes"
 )
 
 const (
@@ -44,7 +44,7 @@ var (
 	DBSystemValue = semconv.DBSystemKey.String("mysql")
 )
 
-// StatusCodeAttr 根据给定的code返回KV
+// StatusCodeAttr 根据给定的grpc code返回KV
 func StatusCodeAttr(code codes.Code) attribute.KeyValue {
 	return GRPCStatusCodeKey.Int64(int64(code))
 }
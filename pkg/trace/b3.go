@@ -0,0 +1,71 @@
+package trace
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Zipkin 风格头信息，Envoy 使用这些头串联 trace
+// https://www.envoyproxy.io/docs/envoy/latest/intro/arch_overview/observability/tracing
+const (
+	b3TraceID = "x-b3-traceid"
+	b3SpanID  = "x-b3-spanid"
+	b3Sampled = "x-b3-sampled"
+)
+
+// b3Propagator B3 multi header 格式的 propagator，Config.B3 为 true 时和 TraceContext 一起使用
+// 只处理 traceid/spanid/sampled，不传递 parentspanid 和 debug flag
+type b3Propagator struct{}
+
+var _ propagation.TextMapPropagator = b3Propagator{}
+
+// Inject 实现 propagation.TextMapPropagator
+func (b3Propagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+
+	carrier.Set(b3TraceID, sc.TraceID().String())
+	carrier.Set(b3SpanID, sc.SpanID().String())
+	if sc.IsSampled() {
+		carrier.Set(b3Sampled, "1")
+	} else {
+		carrier.Set(b3Sampled, "0")
+	}
+}
+
+// Extract 实现 propagation.TextMapPropagator，已经有上游 span 时不覆盖
+func (b3Propagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+
+	traceID, err := trace.TraceIDFromHex(carrier.Get(b3TraceID))
+	if err != nil {
+		return ctx
+	}
+	spanID, err := trace.SpanIDFromHex(carrier.Get(b3SpanID))
+	if err != nil {
+		return ctx
+	}
+
+	var flags trace.TraceFlags
+	if s := carrier.Get(b3Sampled); s == "1" || s == "true" {
+		flags = trace.FlagsSampled
+	}
+
+	return trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: flags,
+		Remote:     true,
+	}))
+}
+
+// Fields 实现 propagation.TextMapPropagator
+func (b3Propagator) Fields() []string {
+	return []string{b3TraceID, b3SpanID, b3Sampled}
+}
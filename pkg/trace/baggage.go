@@ -0,0 +1,54 @@
+package trace
+
+import (
+	"context"
+	"strconv"
+
+	"nautilus/pkg/ctxkit"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// 通过 baggage 透传给下游的 ctxkit 字段
+const (
+	BaggageUID      = "uid"
+	BaggagePlatform = "platform"
+	BaggageAppkey   = "appkey"
+)
+
+// baggageFields 透传的字段，值为空时不透传
+var baggageFields = []struct {
+	key string
+	get func(ctx context.Context) string
+}{
+	{BaggageUID, func(ctx context.Context) string {
+		if uid := ctxkit.GetUID(ctx); uid != 0 {
+			return strconv.FormatInt(uid, 10)
+		}
+		return ""
+	}},
+	{BaggagePlatform, ctxkit.GetPlatform},
+	{BaggageAppkey, ctxkit.GetAppkey},
+}
+
+// WithBaggage 把 ctxkit 中的 uid/platform/appkey 加到 baggage 中，ctx 中已有的 baggage 会保留
+// 值中有 baggage 不允许的字符（例如空格、逗号）时不透传
+func WithBaggage(ctx context.Context) context.Context {
+	bag := baggage.FromContext(ctx)
+	for _, f := range baggageFields {
+		v := f.get(ctx)
+		if v == "" {
+			continue
+		}
+
+		m, err := baggage.NewMember(f.key, v)
+		if err != nil {
+			continue
+		}
+		if b, err := bag.SetMember(m); err == nil {
+			bag = b
+		}
+	}
+
+	return baggage.ContextWithBaggage(ctx, bag)
+}
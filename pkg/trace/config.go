@@ -1,17 +1,118 @@
 package trace
 
-// TracerName tracing name
-const TracerName = "miniapp"
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"nautilus/pkg/conf"
+	"nautilus/pkg/log"
+	"nautilus/pkg/ratelimit"
+)
+
+// 采样方式
+const (
+	// SamplerRatio 按比例采样，默认
+	SamplerRatio = "ratio"
+	// SamplerAlways 全部采样，等价于比例为 1
+	SamplerAlways = "always"
+	// SamplerNever 全部不采样，等价于比例为 0
+	SamplerNever = "never"
+	// SamplerRateLimit 按比例采样之后再限制每秒最多采样的 trace 数
+	SamplerRateLimit = "ratelimit"
+)
 
 // Config opentelemetry collect config
 type Config struct {
+	// Name 服务名，为空时使用 conf.AppID
 	Name     string `json:"name,optional"`
 	Endpoint string `json:"endpoint,optional"`
 
-	// Sampler 采样比例
+	// Batcher otel后端，支持jaeger/zipkin/otlp-grpc/otlp-http，为空时使用 jaeger
+	// registers the exporter with the TracerProvider
+	Batcher string `json:"batcher,default=jaeger,options=jaeger|zipkin|otlp-grpc|otlp-http"`
+	// Headers otlp 请求带上的 header，例如鉴权
+	Headers map[string]string `json:"headers,optional"`
+	// B3 是否同时使用 Zipkin 的 x-b3-* 头传递 trace，经过 Envoy 时需要
+	B3 bool `json:"b3,optional"`
+
+	// Sampler 采样比例，只对 SamplerRatio/SamplerRateLimit 生效
 	Sampler float64 `json:"sampler,default=1.0"`
+	// SamplerType 采样方式，为空时使用 SamplerRatio
+	SamplerType string `json:"sampler_type,default=ratio,options=ratio|always|never|ratelimit"`
+	// Rate SamplerRateLimit 的速率，例如 100/s
+	Rate ratelimit.Rate `json:"rate,optional"`
+	// Routes 按接口配置采样比例，key 为 span 名或者 http.route，优先于 Sampler
+	Routes map[string]float64 `json:"routes,optional"`
+}
 
-	// Batcher otel后端，只支持jaeger/zipkin
-	// registers the exporter with the TracerProvider
-	Batcher string `json:"batcher,default=jaeger,options=jaeger|zipkin"`
+// ConfigFromConf 从配置中读取 trace 配置，没有配置的项使用默认值
+//
+//	OTEL_AGENT_ENDPOINT  后端地址，为空时不上报
+//	OTEL_AGENT_BATCH     后端类型 jaeger/zipkin/otlp-grpc/otlp-http，默认 jaeger
+//	OTEL_AGENT_HEADERS   otlp 请求头，格式为 ${key}=${value}，逗号分隔
+//	OTEL_PROPAGATE_B3    是否同时传递 x-b3-* 头，默认 false
+//	OTEL_AGENT_SAMPLE    采样比例，默认 1
+//	OTEL_SAMPLER         采样方式 ratio/always/never/ratelimit，默认 ratio
+//	OTEL_SAMPLER_RATE    ratelimit 的速率，格式参考 ratelimit.ParseRate，例如 100/s
+//	OTEL_SAMPLER_ROUTES  按接口配置采样比例，格式为 ${route}=${ratio}，逗号分隔
+func ConfigFromConf() *Config {
+	c := &Config{
+		Name:        conf.AppID,
+		Endpoint:    conf.Get("OTEL_AGENT_ENDPOINT"),
+		Batcher:     conf.Get("OTEL_AGENT_BATCH"),
+		Headers:     map[string]string{},
+		B3:          conf.GetBool("OTEL_PROPAGATE_B3"),
+		Sampler:     1,
+		SamplerType: conf.Get("OTEL_SAMPLER"),
+		Routes:      map[string]float64{},
+	}
+
+	if conf.Get("OTEL_AGENT_SAMPLE") != "" {
+		c.Sampler = conf.GetFloat64("OTEL_AGENT_SAMPLE")
+	}
+	if s := conf.Get("OTEL_SAMPLER_RATE"); s != "" {
+		rate, err := ratelimit.ParseRate(s)
+		if err != nil {
+			log.Get(context.Background()).Warnf("[otel] invalid sampler rate %q: %v", s, err)
+		}
+		c.Rate = rate
+	}
+
+	for _, v := range conf.GetStrings("OTEL_AGENT_HEADERS") {
+		kv := strings.SplitN(strings.TrimSpace(v), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		c.Headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	for _, v := range conf.GetStrings("OTEL_SAMPLER_ROUTES") {
+		kv := strings.SplitN(strings.TrimSpace(v), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		ratio, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			log.Get(context.Background()).Warnf("[otel] invalid route sample ratio %q: %v", v, err)
+			continue
+		}
+		c.Routes[strings.TrimSpace(kv[0])] = ratio
+	}
+
+	return c
+}
+
+// setDefaults 填充为空的配置，Sampler 为 0 时表示不采样，不会被替换
+func (c *Config) setDefaults() {
+	if c.Name == "" {
+		c.Name = conf.AppID
+	}
+	if c.Batcher == "" {
+		c.Batcher = kindJaeger
+	}
+	if c.SamplerType == "" {
+		c.SamplerType = SamplerRatio
+	}
 }
@@ -0,0 +1,63 @@
+package trace
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"google.golang.org/grpc/credentials"
+)
+
+// otlpTimeout 单次上报的超时时间
+const otlpTimeout = 10 * time.Second
+
+// newOTLPHTTP 创建 otlp/http exporter，endpoint 为 collector 地址，例如 http://127.0.0.1:4318
+// 没有协议时使用 http，没有路径时上报到 /v1/traces
+func newOTLPHTTP(ctx context.Context, endpoint string, headers map[string]string) (*otlptrace.Exporter, error) {
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		endpoint = "http://" + endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("otlp: invalid endpoint %q: %v", endpoint, err)
+	}
+
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithHeaders(headers),
+		otlptracehttp.WithTimeout(otlpTimeout),
+	}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if u.Path != "" && u.Path != "/" {
+		opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+	}
+
+	return otlptrace.New(ctx, otlptracehttp.NewClient(opts...))
+}
+
+// newOTLPGRPC 创建 otlp/grpc exporter，endpoint 为 collector 地址，例如 127.0.0.1:4317
+// https:// 开头时使用 tls，否则不加密
+func newOTLPGRPC(ctx context.Context, endpoint string, headers map[string]string) (*otlptrace.Exporter, error) {
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithHeaders(headers),
+		otlptracegrpc.WithTimeout(otlpTimeout),
+	}
+	if strings.HasPrefix(endpoint, "https://") {
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(&tls.Config{})))
+	} else {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	endpoint = strings.TrimPrefix(strings.TrimPrefix(endpoint, "https://"), "http://")
+	opts = append(opts, otlptracegrpc.WithEndpoint(endpoint))
+
+	return otlptrace.New(ctx, otlptracegrpc.NewClient(opts...))
+}
@@ -0,0 +1,209 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"nautilus/pkg/ratelimit"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+// ratioSampler 可以在运行中修改采样比例的 TraceIDRatioBased
+type ratioSampler struct {
+	// ratio float64 的 bits
+	ratio uint64
+	// sampler 保存 samplerHolder，TraceIDRatioBased 根据比例返回不同类型的 Sampler，atomic.Value 只能保存同一种类型
+	sampler atomic.Value
+}
+
+type samplerHolder struct {
+	sdktrace.Sampler
+}
+
+// sampler 全局的比例采样器，Init 中根据配置包装之后使用，参考 newSampler
+var sampler = newRatioSampler(1)
+
+func newRatioSampler(ratio float64) *ratioSampler {
+	s := &ratioSampler{}
+	s.set(ratio)
+	return s
+}
+
+func (s *ratioSampler) set(ratio float64) {
+	atomic.StoreUint64(&s.ratio, math.Float64bits(ratio))
+	s.sampler.Store(samplerHolder{sdktrace.TraceIDRatioBased(ratio)})
+}
+
+func (s *ratioSampler) get() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&s.ratio))
+}
+
+// ShouldSample 实现 sdktrace.Sampler
+func (s *ratioSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return s.sampler.Load().(samplerHolder).ShouldSample(p)
+}
+
+// Description 实现 sdktrace.Sampler
+func (s *ratioSampler) Description() string {
+	return fmt.Sprintf("DynamicRatio{%g}", s.get())
+}
+
+// newSampler 根据配置创建采样器，有上游 span 时跟随上游
+//
+//	ParentBased(rateLimit(routes(ratio)))
+//
+// 比例采样器是全局的，运行中可以通过 SetSampleRatio 修改；always/never 等价于比例为 1/0
+func newSampler(c *Config) (sdktrace.Sampler, error) {
+	ratio := c.Sampler
+	switch c.SamplerType {
+	case SamplerRatio, SamplerRateLimit:
+	case SamplerAlways:
+		ratio = 1
+	case SamplerNever:
+		ratio = 0
+	default:
+		return nil, fmt.Errorf("trace: unsupport sampler %q", c.SamplerType)
+	}
+	if err := SetSampleRatio(ratio); err != nil {
+		return nil, err
+	}
+
+	var s sdktrace.Sampler = sampler
+	if len(c.Routes) > 0 {
+		routes := make(map[string]sdktrace.Sampler, len(c.Routes))
+		for route, r := range c.Routes {
+			if math.IsNaN(r) || r < 0 || r > 1 {
+				return nil, fmt.Errorf("trace: invalid sample ratio %v for route %s", r, route)
+			}
+			routes[route] = sdktrace.TraceIDRatioBased(r)
+		}
+		s = &routeSampler{routes: routes, fallback: s}
+	}
+
+	if c.SamplerType == SamplerRateLimit {
+		if c.Rate.Limit <= 0 || c.Rate.Period <= 0 {
+			return nil, fmt.Errorf("trace: ratelimit sampler requires rate")
+		}
+		s = &rateLimitSampler{rate: c.Rate, limiter: ratelimit.NewLocal(), next: s}
+	}
+
+	return sdktrace.ParentBased(s), nil
+}
+
+// routeSampler 按接口配置采样比例，优先使用 http.route 属性，没有时使用 span 名
+// gin 中间件的 span 名为路由，grpc 拦截器的 span 名为方法名
+type routeSampler struct {
+	routes   map[string]sdktrace.Sampler
+	fallback sdktrace.Sampler
+}
+
+// ShouldSample 实现 sdktrace.Sampler
+func (s *routeSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	route := p.Name
+	for _, attr := range p.Attributes {
+		if attr.Key == semconv.HTTPRouteKey {
+			route = attr.Value.AsString()
+			break
+		}
+	}
+
+	if rs, ok := s.routes[route]; ok {
+		return rs.ShouldSample(p)
+	}
+
+	return s.fallback.ShouldSample(p)
+}
+
+// Description 实现 sdktrace.Sampler
+func (s *routeSampler) Description() string {
+	routes := make([]string, 0, len(s.routes))
+	for route, rs := range s.routes {
+		routes = append(routes, route+"="+rs.Description())
+	}
+	sort.Strings(routes)
+
+	return fmt.Sprintf("Routes{%s,fallback:%s}", strings.Join(routes, ","), s.fallback.Description())
+}
+
+// rateLimitSampler next 采样的 trace 再按照速率限制，超过速率的不采样
+// 用于流量突增时保护 trace 后端，限流是单实例的
+type rateLimitSampler struct {
+	rate    ratelimit.Rate
+	limiter ratelimit.Limiter
+	next    sdktrace.Sampler
+}
+
+// ShouldSample 实现 sdktrace.Sampler
+func (s *rateLimitSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	res := s.next.ShouldSample(p)
+	if res.Decision != sdktrace.RecordAndSample {
+		return res
+	}
+
+	// 本地限流不会返回错误
+	if r, _ := s.limiter.Allow(context.Background(), "trace", s.rate); !r.Allowed {
+		res.Decision = sdktrace.Drop
+		res.Attributes = nil
+	}
+
+	return res
+}
+
+// Description 实现 sdktrace.Sampler
+func (s *rateLimitSampler) Description() string {
+	return fmt.Sprintf("RateLimit{%d/%s,%s}", s.rate.Limit, s.rate.Period, s.next.Description())
+}
+
+// SetSampleRatio 运行中修改采样比例，只影响没有上游 span 的请求，有上游 span 时跟随上游
+func SetSampleRatio(ratio float64) error {
+	if math.IsNaN(ratio) || ratio < 0 || ratio > 1 {
+		return fmt.Errorf("trace: invalid sample ratio %v", ratio)
+	}
+
+	sampler.set(ratio)
+	return nil
+}
+
+// SampleRatio 当前的采样比例
+func SampleRatio() float64 {
+	return sampler.get()
+}
+
+// Handler 运行中查看和修改采样比例的 http 接口，需要挂在内网或者管理端口上
+//
+//	GET  查看采样比例
+//	PUT  ?ratio=0.1 修改采样比例，配置变更之后不会自动恢复
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			ratio, err := strconv.ParseFloat(r.URL.Query().Get("ratio"), 64)
+			if err == nil {
+				err = SetSampleRatio(ratio)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"ratio": SampleRatio(),
+		})
+	})
+}
@@ -0,0 +1,121 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nautilus/pkg/ratelimit"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetSampleRatio(t *testing.T) {
+	defer SetSampleRatio(SampleRatio())
+
+	params := sdktrace.SamplingParameters{TraceID: traceID}
+
+	assert.Nil(t, SetSampleRatio(0))
+	assert.Equal(t, sdktrace.Drop, sampler.ShouldSample(params).Decision)
+	assert.Nil(t, SetSampleRatio(1))
+	assert.Equal(t, sdktrace.RecordAndSample, sampler.ShouldSample(params).Decision)
+	assert.Equal(t, "DynamicRatio{1}", sampler.Description())
+
+	assert.NotNil(t, SetSampleRatio(-1))
+	assert.NotNil(t, SetSampleRatio(1.5))
+	assert.Equal(t, float64(1), SampleRatio())
+}
+
+func TestSamplerHandler(t *testing.T) {
+	defer SetSampleRatio(SampleRatio())
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/?ratio=0.25", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"ratio":0.25}`, w.Body.String())
+	assert.Equal(t, 0.25, SampleRatio())
+
+	w = httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/?ratio=2", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestNewSampler(t *testing.T) {
+	defer SetSampleRatio(SampleRatio())
+
+	root := sdktrace.SamplingParameters{ParentContext: context.Background(), TraceID: traceID, Name: "/api/v0/users"}
+
+	s, err := newSampler(&Config{SamplerType: SamplerNever, Sampler: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, sdktrace.Drop, s.ShouldSample(root).Decision)
+
+	s, err = newSampler(&Config{SamplerType: SamplerAlways})
+	assert.Nil(t, err)
+	assert.Equal(t, sdktrace.RecordAndSample, s.ShouldSample(root).Decision)
+
+	// 有上游 span 时跟随上游
+	parent := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}))
+	s, err = newSampler(&Config{SamplerType: SamplerNever})
+	assert.Nil(t, err)
+	assert.Equal(t, sdktrace.RecordAndSample, s.ShouldSample(sdktrace.SamplingParameters{ParentContext: parent, TraceID: traceID}).Decision)
+
+	_, err = newSampler(&Config{SamplerType: "unknown"})
+	assert.NotNil(t, err)
+	_, err = newSampler(&Config{SamplerType: SamplerRatio, Sampler: 2})
+	assert.NotNil(t, err)
+	_, err = newSampler(&Config{SamplerType: SamplerRatio, Sampler: 1, Routes: map[string]float64{"/a": -1}})
+	assert.NotNil(t, err)
+	_, err = newSampler(&Config{SamplerType: SamplerRateLimit, Sampler: 1})
+	assert.NotNil(t, err)
+}
+
+func TestRouteSampler(t *testing.T) {
+	defer SetSampleRatio(SampleRatio())
+
+	s, err := newSampler(&Config{
+		SamplerType: SamplerRatio,
+		Sampler:     1,
+		Routes:      map[string]float64{"/metrics": 0, "/api/v0/report": 1},
+	})
+	assert.Nil(t, err)
+
+	params := func(name string, attrs ...attribute.KeyValue) sdktrace.SamplingParameters {
+		return sdktrace.SamplingParameters{ParentContext: context.Background(), TraceID: traceID, Name: name, Attributes: attrs}
+	}
+	assert.Equal(t, sdktrace.Drop, s.ShouldSample(params("/metrics")).Decision)
+	assert.Equal(t, sdktrace.RecordAndSample, s.ShouldSample(params("/api/v0/users")).Decision)
+	// http.route 优先于 span 名
+	assert.Equal(t, sdktrace.Drop, s.ShouldSample(params("GET", semconv.HTTPRouteKey.String("/metrics"))).Decision)
+
+	// 没有配置的接口使用全局比例，运行中修改后生效
+	assert.Nil(t, SetSampleRatio(0))
+	assert.Equal(t, sdktrace.Drop, s.ShouldSample(params("/api/v0/users")).Decision)
+	assert.Equal(t, sdktrace.RecordAndSample, s.ShouldSample(params("/api/v0/report")).Decision)
+}
+
+func TestRateLimitSampler(t *testing.T) {
+	defer SetSampleRatio(SampleRatio())
+
+	s, err := newSampler(&Config{
+		SamplerType: SamplerRateLimit,
+		Sampler:     1,
+		Rate:        ratelimit.Rate{Limit: 2, Period: time.Hour},
+	})
+	assert.Nil(t, err)
+
+	params := sdktrace.SamplingParameters{ParentContext: context.Background(), TraceID: traceID, Name: "/"}
+	assert.Equal(t, sdktrace.RecordAndSample, s.ShouldSample(params).Decision)
+	assert.Equal(t, sdktrace.RecordAndSample, s.ShouldSample(params).Decision)
+	assert.Equal(t, sdktrace.Drop, s.ShouldSample(params).Decision)
+}
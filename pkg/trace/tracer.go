@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/baggage"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
@@ -53,28 +54,9 @@ func Extract(ctx context.Context, p propagation.TextMapPropagator, metadata *met
 	return baggage.FromContext(ctx), trace.SpanContextFromContext(ctx)
 }
 
-// InjectHeader 注入 open tracing 头信息
-func InjectHeader(ctx trace.SpanContext, req *http.Request) {
-	//opentracing.GlobalTracer().Inject(
-	//	ctx,
-	//	opentracing.HTTPHeaders,
-	//	opentracing.HTTPHeadersCarrier(req.Header),
-	//)
-	//
-	//jctx, ok := ctx.(jaeger.SpanContext)
-	//if !ok {
-	//	return
-	//}
-
-	//// Envoy 使用 Zipkin 风格头信息
-	//// https://www.envoyproxy.io/docs/envoy/latest/intro/arch_overview/observability/tracing
-	//req.Header.Set("x-b3-traceid", jctx.TraceID().String())
-	//req.Header.Set("x-b3-spanid", jctx.SpanID().String())
-	//req.Header.Set("x-b3-parentspanid", jctx.ParentID().String())
-	//if jctx.IsSampled() {
-	//	req.Header.Set("x-b3-sampled", "1")
-	//}
-	//if jctx.IsDebug() {
-	//	req.Header.Set("x-b3-flags", "1")
-	//}
+// InjectHeader 把 ctx 中的 span 和 baggage 注入到请求头中，下游服务可以串联 trace
+// 使用全局的 TextMapPropagator，默认为 traceparent/tracestate/baggage，Config.B3 为 true 时还会带上 x-b3-* 头
+// ctxkit 中的 uid/platform/appkey 通过 baggage 透传，参考 WithBaggage
+func InjectHeader(ctx context.Context, req *http.Request) {
+	otel.GetTextMapPropagator().Inject(WithBaggage(ctx), propagation.HeaderCarrier(req.Header))
 }
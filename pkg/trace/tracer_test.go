@@ -3,10 +3,14 @@ package trace
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"testing"
 
+	"nautilus/pkg/ctxkit"
+
 	"github.com/magiconair/properties/assert"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc/metadata"
@@ -178,3 +182,32 @@ func TestExtractValidTraceContext(t *testing.T) {
 		})
 	}
 }
+
+func TestWithBaggage(t *testing.T) {
+	ctx := ctxkit.WithUID(context.Background(), 10)
+	ctx = ctxkit.WithPlatform(ctx, "ios")
+	ctx = ctxkit.WithAppkey(ctx, "bad value")
+
+	bag := baggage.FromContext(WithBaggage(ctx))
+	assert.Equal(t, "10", bag.Member(BaggageUID).Value())
+	assert.Equal(t, "ios", bag.Member(BaggagePlatform).Value())
+	// 不合法的值不透传
+	assert.Equal(t, "", bag.Member(BaggageAppkey).Key())
+}
+
+func TestB3Propagator(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	header := http.Header{}
+	b3Propagator{}.Inject(trace.ContextWithSpanContext(context.Background(), sc), propagation.HeaderCarrier(header))
+	assert.Equal(t, traceIDStr, header.Get("X-B3-Traceid"))
+	assert.Equal(t, spanIDStr, header.Get("X-B3-Spanid"))
+	assert.Equal(t, "1", header.Get("X-B3-Sampled"))
+
+	got := trace.SpanContextFromContext(b3Propagator{}.Extract(context.Background(), propagation.HeaderCarrier(header)))
+	assert.Equal(t, sc.WithRemote(true), got)
+}
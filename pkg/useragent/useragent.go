@@ -0,0 +1,107 @@
+package useragent
+
+import (
+	"regexp"
+	"strings"
+)
+
+// 平台，和 ctxkit.PlatformKey 的枚举一致
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformWeb     = "web"
+	PlatformPad     = "pad"
+)
+
+// Info 从 User-Agent 中解析出的客户端信息
+type Info struct {
+	// Platform 平台，枚举 [ios, android, web, pad]
+	Platform string
+	// Device 设备型号，web 为浏览器名称
+	Device string
+	// Version app 版本，没有 app 标识时为系统或浏览器版本
+	Version string
+}
+
+var (
+	iosVersion     = regexp.MustCompile(`OS (\d+(?:_\d+)*) like Mac OS X`)
+	androidVersion = regexp.MustCompile(`Android (\d+(?:\.\d+)*)`)
+	androidModel   = regexp.MustCompile(`Android [^;)]*;(?: [a-zA-Z]{2}[-_][a-zA-Z]{2};)? ([^;)]+?)(?: Build/[^;)]*)?[;)]`)
+
+	// browsers 按顺序匹配，Edge/Opera/微信的 UA 中也带有 Chrome/Safari
+	browsers = []struct {
+		name string
+		re   *regexp.Regexp
+	}{
+		{"WeChat", regexp.MustCompile(`MicroMessenger/([\d.]+)`)},
+		{"Edge", regexp.MustCompile(`Edg(?:e|A|iOS)?/([\d.]+)`)},
+		{"Opera", regexp.MustCompile(`(?:OPR|Opera)/([\d.]+)`)},
+		{"Firefox", regexp.MustCompile(`(?:Firefox|FxiOS)/([\d.]+)`)},
+		{"Chrome", regexp.MustCompile(`(?:Chrome|CriOS)/([\d.]+)`)},
+		{"Safari", regexp.MustCompile(`Version/([\d.]+).*Safari/`)},
+		{"IE", regexp.MustCompile(`(?:MSIE |Trident/.*rv:)([\d.]+)`)},
+	}
+)
+
+// Parse 解析 User-Agent
+// app 需要在 UA 中带上 ${app}/${version}，例如 nautilus/1.2.0 (iPhone; iOS 15_0 like Mac OS X)
+// app 为空或者 UA 中没有 app 标识时，version 取系统版本(ios/android/pad)或者浏览器版本(web)
+func Parse(ua, app string) (info Info) {
+	switch {
+	case strings.Contains(ua, "iPad"):
+		info.Platform, info.Device = PlatformPad, "iPad"
+		info.Version = strings.ReplaceAll(submatch(iosVersion, ua), "_", ".")
+	case strings.Contains(ua, "iPhone") || strings.Contains(ua, "iPod"):
+		info.Platform, info.Device = PlatformIOS, "iPhone"
+		if strings.Contains(ua, "iPod") {
+			info.Device = "iPod"
+		}
+		info.Version = strings.ReplaceAll(submatch(iosVersion, ua), "_", ".")
+	case strings.Contains(ua, "Android"):
+		// android 平板的 UA 中没有 Mobile
+		info.Platform = PlatformAndroid
+		if !strings.Contains(ua, "Mobile") {
+			info.Platform = PlatformPad
+		}
+		info.Device = strings.TrimSpace(submatch(androidModel, ua))
+		info.Version = submatch(androidVersion, ua)
+	default:
+		info.Platform = PlatformWeb
+		for _, b := range browsers {
+			if v := submatch(b.re, ua); v != "" {
+				info.Device, info.Version = b.name, v
+				break
+			}
+		}
+	}
+
+	if v := appVersion(ua, app); v != "" {
+		info.Version = v
+	}
+
+	return
+}
+
+// appVersion 查找 ${app}/${version}，app 名称不区分大小写
+func appVersion(ua, app string) string {
+	if app == "" {
+		return ""
+	}
+
+	prefix := strings.ToLower(app) + "/"
+	for _, f := range strings.Fields(ua) {
+		if len(f) > len(prefix) && strings.ToLower(f[:len(prefix)]) == prefix {
+			return f[len(prefix):]
+		}
+	}
+
+	return ""
+}
+
+func submatch(re *regexp.Regexp, s string) string {
+	if m := re.FindStringSubmatch(s); len(m) > 1 {
+		return m[1]
+	}
+
+	return ""
+}
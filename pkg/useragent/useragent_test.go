@@ -0,0 +1,59 @@
+package useragent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		ua   string
+		want Info
+	}{
+		{
+			ua:   "Mozilla/5.0 (iPhone; CPU iPhone OS 15_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/15.0 Mobile/15E148 Safari/604.1",
+			want: Info{Platform: PlatformIOS, Device: "iPhone", Version: "15.0"},
+		},
+		{
+			ua:   "Mozilla/5.0 (iPad; CPU OS 14_7_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148",
+			want: Info{Platform: PlatformPad, Device: "iPad", Version: "14.7.1"},
+		},
+		{
+			ua:   "Mozilla/5.0 (Linux; Android 11; Pixel 5 Build/RQ3A.210805.001) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/94.0.4606.71 Mobile Safari/537.36",
+			want: Info{Platform: PlatformAndroid, Device: "Pixel 5", Version: "11"},
+		},
+		{
+			ua:   "Mozilla/5.0 (Linux; U; Android 4.4.2; zh-cn; GT-I9500 Build/KOT49H) AppleWebKit/537.36 Mobile Safari/537.36",
+			want: Info{Platform: PlatformAndroid, Device: "GT-I9500", Version: "4.4.2"},
+		},
+		{
+			ua:   "Mozilla/5.0 (Linux; Android 10; SM-T510) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/94.0.4606.71 Safari/537.36",
+			want: Info{Platform: PlatformPad, Device: "SM-T510", Version: "10"},
+		},
+		{
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/94.0.4606.81 Safari/537.36 Edg/94.0.992.50",
+			want: Info{Platform: PlatformWeb, Device: "Edge", Version: "94.0.992.50"},
+		},
+		{
+			ua:   "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/15.0 Safari/605.1.15",
+			want: Info{Platform: PlatformWeb, Device: "Safari", Version: "15.0"},
+		},
+		{
+			ua:   "Mozilla/5.0 (X11; Linux x86_64; rv:93.0) Gecko/20100101 Firefox/93.0",
+			want: Info{Platform: PlatformWeb, Device: "Firefox", Version: "93.0"},
+		},
+		{
+			ua:   "Nautilus/2.3.1 (Linux; Android 11; Pixel 5) okhttp/4.9.0 Mobile",
+			want: Info{Platform: PlatformAndroid, Device: "Pixel 5", Version: "2.3.1"},
+		},
+		{
+			ua:   "",
+			want: Info{Platform: PlatformWeb},
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Parse(tt.ua, "nautilus"), tt.ua)
+	}
+}
@@ -0,0 +1,197 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	xerrors "nautilus/pkg/errors"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+// 校验规则通过 proto 中的 @inject_tag 声明，生成到结构体的 binding tag 中
+//
+//	// @inject_tag: form:"page" binding:"omitempty,min=1"
+//	int32 page = 2;
+//
+// HTTP 请求在 gin bind 时校验，grpc 请求通过 interceptor 调用 Struct 校验，两者共用同一个 validator
+
+// reason 参数错误提示
+const reason = "invalid params"
+
+func init() {
+	// 错误中的字段名使用 json tag，和接口文档保持一致
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(fieldName)
+	}
+}
+
+// fieldName 获取字段的 json 名字，没有 json tag 时使用字段名
+func fieldName(f reflect.StructField) string {
+	name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+	if name == "" || name == "-" {
+		return f.Name
+	}
+
+	return name
+}
+
+// Struct 根据 binding tag 校验请求参数
+// 校验失败返回 400 错误，携带 google.rpc.BadRequest 详情
+func Struct(v interface{}) error {
+	if err := binding.Validator.ValidateStruct(v); err != nil {
+		return FromError(err)
+	}
+
+	return nil
+}
+
+// FromError 把 gin bind/validator 的错误转换为带 google.rpc.BadRequest 详情的 *errors.Error
+func FromError(err error) *xerrors.Error {
+	return FromParams(err, nil)
+}
+
+// FromParams 和 FromError 一样，params 为请求的 query/form/uri 参数
+// gin 绑定 query/form/uri 时类型错误返回的 *strconv.NumError 不带字段名，根据参数值找到出错的字段
+func FromParams(err error, params url.Values) *xerrors.Error {
+	var ve validator.ValidationErrors
+	if errors.As(err, &ve) {
+		violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(ve))
+		for _, fe := range ve {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       fe.Field(),
+				Description: description(fe),
+			})
+		}
+
+		return xerrors.NewFieldViolations(reason, violations...).WithCause(err)
+	}
+
+	// JSON 参数类型错误，例如 {"page":"abc"}
+	var ue *json.UnmarshalTypeError
+	if errors.As(err, &ue) {
+		return xerrors.NewFieldViolations(reason, &errdetails.BadRequest_FieldViolation{
+			Field:       ue.Field,
+			Description: fmt.Sprintf("must be %s", ue.Type),
+		}).WithCause(err)
+	}
+
+	// query/form/uri 参数类型错误，例如 page=abc
+	var ne *strconv.NumError
+	if errors.As(err, &ne) {
+		return xerrors.NewFieldViolations(reason, &errdetails.BadRequest_FieldViolation{
+			Field:       lookup(params, ne.Num),
+			Description: numDescription(ne),
+		}).WithCause(err)
+	}
+
+	return xerrors.Wrap(err, xerrors.BadRequest, reason)
+}
+
+// lookup 返回值为 value 的参数名，有多个时按名字排序取第一个，找不到时返回空
+func lookup(params url.Values, value string) string {
+	var field string
+	for k, vs := range params {
+		for _, v := range vs {
+			if v == value && (field == "" || k < field) {
+				field = k
+			}
+		}
+	}
+
+	return field
+}
+
+// numDescription 数字/布尔类型解析失败的描述
+func numDescription(ne *strconv.NumError) string {
+	switch ne.Func {
+	case "ParseBool":
+		return "must be a boolean"
+	case "ParseFloat":
+		return "must be a number"
+	}
+
+	if errors.Is(ne.Err, strconv.ErrRange) {
+		return "is out of range"
+	}
+	return "must be an integer"
+}
+
+// paramNames 规则参数中引用的是 Go 字段名，例如 required_without=Phone，转换为错误中使用的 json 名字
+// proto 生成的结构体中 json 名字是字段名的 snake case，例如 RoleType => role_type
+func paramNames(param string) string {
+	names := strings.Fields(param)
+	for i, name := range names {
+		names[i] = snakeCase(name)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// snakeCase RoleType => role_type
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 && !unicode.IsUpper(rune(name[i-1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// description 生成字段校验失败的描述
+func description(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_with":
+		return fmt.Sprintf("is required when %s is present", paramNames(fe.Param()))
+	case "required_without":
+		return fmt.Sprintf("is required when %s is absent", paramNames(fe.Param()))
+	case "required_if":
+		// RoleType 1 => role_type is 1
+		params := strings.Fields(fe.Param())
+		conds := make([]string, 0, len(params)/2)
+		for i := 0; i+1 < len(params); i += 2 {
+			conds = append(conds, fmt.Sprintf("%s is %s", snakeCase(params[i]), params[i+1]))
+		}
+		return fmt.Sprintf("is required when %s", strings.Join(conds, " and "))
+	case "min", "gte":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("length must be at least %s", fe.Param())
+		}
+		return fmt.Sprintf("must be greater than or equal to %s", fe.Param())
+	case "max", "lte":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("length must be at most %s", fe.Param())
+		}
+		return fmt.Sprintf("must be less than or equal to %s", fe.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fe.Param())
+	case "lt":
+		return fmt.Sprintf("must be less than %s", fe.Param())
+	case "len":
+		return fmt.Sprintf("length must be %s", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of [%s]", fe.Param())
+	case "numeric":
+		return "must be numeric"
+	case "startswith":
+		return fmt.Sprintf("must start with %s", fe.Param())
+	default:
+		return fmt.Sprintf("failed on the '%s' rule", fe.Tag())
+	}
+}
@@ -0,0 +1,94 @@
+package validate
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"testing"
+
+	demo_v0 "nautilus/api/demo/v0"
+	pension_v0 "nautilus/api/pension/v0"
+	xerrors "nautilus/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func violations(t *testing.T, err error) map[string]string {
+	e := xerrors.FromError(err)
+	assert.Equal(t, xerrors.BadRequest, e.Type)
+
+	m := map[string]string{}
+	for _, v := range e.FieldViolations() {
+		m[v.Field] = v.Description
+	}
+
+	return m
+}
+
+func TestGetArticlesReq(t *testing.T) {
+	assert.Nil(t, Struct(&demo_v0.GetArticlesReq{}))
+	assert.Nil(t, Struct(&demo_v0.GetArticlesReq{Page: 1, PageSize: 100}))
+
+	err := Struct(&demo_v0.GetArticlesReq{Page: -1, PageSize: 101})
+	assert.Equal(t, map[string]string{
+		"page":      "must be greater than or equal to 1",
+		"page_size": "must be less than or equal to 100",
+	}, violations(t, err))
+}
+
+func TestLoginReq(t *testing.T) {
+	assert.Nil(t, Struct(&pension_v0.LoginReq{Username: "admin", Password: "123456"}))
+	assert.Nil(t, Struct(&pension_v0.LoginReq{Phone: "13800138000", Code: "1234"}))
+
+	err := Struct(&pension_v0.LoginReq{})
+	assert.Equal(t, map[string]string{
+		"username": "is required when phone is absent",
+		"phone":    "is required when username is absent",
+	}, violations(t, err))
+
+	err = Struct(&pension_v0.LoginReq{Username: "admin"})
+	assert.Equal(t, map[string]string{
+		"password": "is required when username is present",
+	}, violations(t, err))
+
+	err = Struct(&pension_v0.LoginReq{Phone: "2380013800", Code: "1234"})
+	assert.Equal(t, map[string]string{
+		"phone": "length must be 11",
+	}, violations(t, err))
+}
+
+func TestCreateAdminReq(t *testing.T) {
+	err := Struct(&pension_v0.CreateAdminReq{Username: "admin", Password: "123456", RoleType: 1})
+	assert.Equal(t, map[string]string{
+		"phone": "is required when role_type is 1",
+	}, violations(t, err))
+	// 超级管理员可以只有手机号
+	assert.Nil(t, Struct(&pension_v0.CreateAdminReq{Phone: "13800000000", RoleType: 1}))
+
+	// 普通管理员需要用户名和密码
+	err = Struct(&pension_v0.CreateAdminReq{})
+	assert.Equal(t, map[string]string{
+		"username": "is required when role_type is 0",
+		"password": "is required when role_type is 0",
+	}, violations(t, err))
+}
+
+func TestFromParams(t *testing.T) {
+	// gin 绑定 query 时的类型错误
+	_, err := strconv.ParseInt("abc", 10, 32)
+	assert.Equal(t, map[string]string{
+		"page": "must be an integer",
+	}, violations(t, FromParams(err, url.Values{"page": {"abc"}, "title": {"foo"}})))
+
+	_, err = strconv.ParseInt("99999999999", 10, 32)
+	assert.Equal(t, map[string]string{
+		"page_size": "is out of range",
+	}, violations(t, FromParams(err, url.Values{"page_size": {"99999999999"}})))
+
+	// JSON 类型错误
+	var req pension_v0.CreateAdminReq
+	err = json.Unmarshal([]byte(`{"role_type":"abc"}`), &req)
+	assert.Equal(t, map[string]string{
+		"role_type": "must be int32",
+	}, violations(t, FromError(err)))
+}
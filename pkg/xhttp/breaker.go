@@ -0,0 +1,145 @@
+package xhttp
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"nautilus/pkg/metrics"
+)
+
+// 熔断器状态，同时也是 HTTPBreakerState 的值
+const (
+	stateClosed   = 0
+	stateHalfOpen = 1
+	stateOpen     = 2
+)
+
+// 请求结果
+const (
+	resultSuccess = iota
+	resultFailure
+	// resultIgnore 调用方取消或者对冲请求被取消，不计入统计
+	resultIgnore
+)
+
+// errBreakerOpen 熔断器打开，请求没有发出
+var errBreakerOpen = errors.New("xhttp: circuit breaker open")
+
+// BreakerConfig 熔断配置，FailureRatio 为 0 时不熔断
+//
+// 关闭状态下统计 Window 内的请求，请求数不少于 MinRequests 且失败比例不低于 FailureRatio 时打开
+// 打开 OpenTimeout 之后进入半开状态，最多放行 HalfOpenRequests 个探测请求，全部成功时关闭，任意一个失败时重新打开
+// 网络错误和 5xx 为失败
+type BreakerConfig struct {
+	FailureRatio     float64
+	MinRequests      int
+	Window           time.Duration
+	OpenTimeout      time.Duration
+	HalfOpenRequests int
+}
+
+// breaker 熔断器，每个下游服务一个
+type breaker struct {
+	name string
+	cfg  BreakerConfig
+	now  func() time.Time
+
+	mu    sync.Mutex
+	state int
+	// gen 状态变化时加一，之前放行的请求的结果不再计入
+	gen uint64
+	// 关闭状态下窗口内的统计
+	windowStart time.Time
+	requests    int
+	failures    int
+	// 打开的时间
+	openedAt time.Time
+	// 半开状态下放行和成功的探测请求数
+	probes    int
+	successes int
+}
+
+func newBreaker(name string, cfg BreakerConfig) *breaker {
+	b := &breaker{name: name, cfg: cfg, now: time.Now}
+	b.windowStart = b.now()
+	metrics.HTTPBreakerState.WithLabelValues(name).Set(stateClosed)
+	return b
+}
+
+// allow 是否放行请求，放行时返回的 gen 需要传给 record
+func (b *breaker) allow() (gen uint64, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	switch b.state {
+	case stateOpen:
+		if now.Sub(b.openedAt) < b.cfg.OpenTimeout {
+			return 0, false
+		}
+		b.setState(stateHalfOpen, now)
+		fallthrough
+	case stateHalfOpen:
+		if b.probes >= b.cfg.HalfOpenRequests {
+			return 0, false
+		}
+		b.probes++
+	default:
+		if now.Sub(b.windowStart) >= b.cfg.Window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+	}
+
+	return b.gen, true
+}
+
+// record 记录请求结果
+func (b *breaker) record(gen uint64, result int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if gen != b.gen {
+		return
+	}
+
+	now := b.now()
+	switch b.state {
+	case stateHalfOpen:
+		switch result {
+		case resultFailure:
+			b.setState(stateOpen, now)
+		case resultSuccess:
+			if b.successes++; b.successes >= b.cfg.HalfOpenRequests {
+				b.setState(stateClosed, now)
+			}
+		default:
+			// 探测请求被取消，让出名额
+			b.probes--
+		}
+	case stateClosed:
+		if result == resultIgnore {
+			return
+		}
+		b.requests++
+		if result == resultFailure {
+			b.failures++
+		}
+		if b.requests >= b.cfg.MinRequests && float64(b.failures)/float64(b.requests) >= b.cfg.FailureRatio {
+			b.setState(stateOpen, now)
+		}
+	}
+}
+
+// setState 切换状态，重置统计
+func (b *breaker) setState(state int, now time.Time) {
+	b.state = state
+	b.gen++
+	b.windowStart, b.requests, b.failures = now, 0, 0
+	b.probes, b.successes = 0, 0
+	if state == stateOpen {
+		b.openedAt = now
+	}
+
+	metrics.HTTPBreakerState.WithLabelValues(b.name).Set(float64(state))
+}
@@ -10,7 +10,7 @@ import (
 	"strings"
 	"sync"
 
-	xtrace "miniapp/pkg/trace"
+	xtrace "nautilus/pkg/trace"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -95,7 +95,7 @@ func NewClientTrace(ctx context.Context, opts ...ClientTraceOption) *httptrace.C
 		opt.apply(ct)
 	}
 
-	ct.tr = ct.tracerProvider.Tracer("miniapp.httptrace", trace.WithInstrumentationVersion(xtrace.SemVersion()))
+	ct.tr = ct.tracerProvider.Tracer("nautilus.httptrace", trace.WithInstrumentationVersion(xtrace.SemVersion()))
 
 	return &httptrace.ClientTrace{
 		GetConn:              ct.getConn,
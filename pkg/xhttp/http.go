@@ -4,167 +4,418 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptrace"
+	"net/url"
 	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	"miniapp/pkg/log"
-	"miniapp/pkg/metrics"
+	xerrors "nautilus/pkg/errors"
+	"nautilus/pkg/log"
+	"nautilus/pkg/metrics"
+	xtrace "nautilus/pkg/trace"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
 	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 var digitsRE = regexp.MustCompile(`\b\d+\b`)
 
-type myClient struct {
-	cli *http.Client
+// httpURLs 限制 HTTPDurationSeconds 中 url 的数量，url 中除了数字之外还可能带有其他 id
+var httpURLs = metrics.NewLabelGuard("http", 0)
+
+// errResponseTooLarge 响应超过 Config.MaxResponseSize
+var errResponseTooLarge = errors.New("xhttp: response too large")
+
+var (
+	mu      sync.RWMutex
+	clients = map[string]*Client{}
+)
+
+// Client 下游 http 服务的客户端，每个下游服务一个，并发安全
+type Client struct {
+	name      string
+	config    Config
+	header    http.Header
+	transport http.RoundTripper
+	cli       *http.Client
+	tracer    oteltrace.Tracer
+	// breaker 没有配置熔断时为 nil
+	breaker *breaker
 }
 
-// Client http客户端接口
-type Client interface {
-	// DoGet Get方法, req表示query
-	DoGet(ctx context.Context, url string, header map[string]string, query map[string]string) (resp *http.Response, err error)
+// Response 响应，body 已经读取到内存中，不需要 close
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
 
-	// DoPost Post方法，req表示body
-	DoPost(ctx context.Context, url string, header map[string]string, body interface{}) (resp *http.Response, err error)
+// JSON 把响应解析到 v
+func (r *Response) JSON(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
 }
 
-// NewClient 创建一个HTTP client
-func NewClient(timeout time.Duration) Client {
-	return &myClient{
-		cli: &http.Client{
-			Timeout: timeout,
-		},
+// Get 根据下游服务名返回 Client，第一次调用时根据 XHTTP_${NAME}_* 配置创建，之后复用连接池
+// Get 是并发安全的，可以在多协程下使用
+func Get(name string) *Client {
+	mu.RLock()
+	c, ok := clients[name]
+	mu.RUnlock()
+	if ok {
+		return c
 	}
-}
 
-// DoGet 发起Get请求
-// 注意: 上层应用读取response之后，需要close
-func (c *myClient) DoGet(ctx context.Context, url string, header map[string]string, query map[string]string) (resp *http.Response, err error) {
-	tr := otel.Tracer("HTTP-Call")
-	ctx, span := tr.Start(ctx, "do-get-http")
-	defer span.End()
+	mu.Lock()
+	defer mu.Unlock()
+	if c, ok = clients[name]; !ok {
+		c = NewClient(name)
+		clients[name] = c
+	}
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		onSpanError(span, err)
-		return
+	return c
+}
+
+// NewClient 创建下游服务的客户端，name 为下游服务名，用于读取配置和记录日志
+// 配置参考 ConfigFromConf，opts 优先于配置
+//
+//	client := xhttp.NewClient("user", xhttp.WithBaseURL("http://user.svc:8080"))
+//	var user User
+//	_, err := client.Get(ctx, "/users/1", xhttp.Query("fields", "name"), xhttp.Into(&user))
+func NewClient(name string, opts ...Option) *Client {
+	c := &Client{
+		name:   name,
+		config: ConfigFromConf(name),
+		header: http.Header{},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.transport == nil {
+		c.transport = c.config.transport()
+	}
+	c.config.setDefaults()
 
-	// query参数
-	for k, v := range query {
-		req.URL.Query().Add(k, v)
+	c.cli = &http.Client{
+		Transport: c.transport,
+		Timeout:   c.config.Timeout,
+	}
+	c.tracer = otel.Tracer("nautilus.xhttp", oteltrace.WithInstrumentationVersion(xtrace.SemVersion()))
+	if c.config.Breaker.FailureRatio > 0 {
+		c.breaker = newBreaker(name, c.config.Breaker)
 	}
 
-	// header头设置
-	for k, v := range header {
-		req.Header.Add(k, v)
+	return c
+}
+
+// Name 下游服务名
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Get 发起 GET 请求
+func (c *Client) Get(ctx context.Context, url string, opts ...RequestOption) (*Response, error) {
+	return c.Do(ctx, http.MethodGet, url, opts...)
+}
+
+// Head 发起 HEAD 请求
+func (c *Client) Head(ctx context.Context, url string, opts ...RequestOption) (*Response, error) {
+	return c.Do(ctx, http.MethodHead, url, opts...)
+}
+
+// Post 发起 POST 请求
+func (c *Client) Post(ctx context.Context, url string, opts ...RequestOption) (*Response, error) {
+	return c.Do(ctx, http.MethodPost, url, opts...)
+}
+
+// Put 发起 PUT 请求
+func (c *Client) Put(ctx context.Context, url string, opts ...RequestOption) (*Response, error) {
+	return c.Do(ctx, http.MethodPut, url, opts...)
+}
+
+// Patch 发起 PATCH 请求
+func (c *Client) Patch(ctx context.Context, url string, opts ...RequestOption) (*Response, error) {
+	return c.Do(ctx, http.MethodPatch, url, opts...)
+}
+
+// Delete 发起 DELETE 请求
+func (c *Client) Delete(ctx context.Context, url string, opts ...RequestOption) (*Response, error) {
+	return c.Do(ctx, http.MethodDelete, url, opts...)
+}
+
+// Options 发起 OPTIONS 请求
+func (c *Client) Options(ctx context.Context, url string, opts ...RequestOption) (*Response, error) {
+	return c.Do(ctx, http.MethodOptions, url, opts...)
+}
+
+// Do 发起请求，rawURL 为相对路径时拼接在 BaseURL 之后
+// 按照配置重试、对冲和熔断，参考 RetryConfig、HedgeConfig、BreakerConfig，每次请求都是一个子 span
+// 返回的错误都是 *errors.Error：
+//   - 非 2xx 响应参考 errors.FromHTTPStatus，同时返回 Response，下游返回 {code,msg} 时使用下游的错误码和信息
+//   - 超时为 errors.RequestTimeout，熔断和其他网络错误为 errors.ServiceUnavailable
+func (c *Client) Do(ctx context.Context, method string, rawURL string, opts ...RequestOption) (*Response, error) {
+	r := &request{header: http.Header{}, query: url.Values{}}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.err != nil {
+		return nil, xerrors.Wrap(r.err, xerrors.BadRequest, fmt.Sprintf("xhttp: %s encode request failed", c.name))
 	}
 
-	// TODO(@x-lambda): 这里是在干啥?
-	tracer := NewClientTrace(ctx)
-	ctx = httptrace.WithClientTrace(ctx, tracer)
-	req = req.WithContext(ctx)
+	// 提前检查请求，避免重试时重复出错
+	if _, err := c.newRequest(ctx, method, rawURL, r); err != nil {
+		return nil, xerrors.Wrap(err, xerrors.BadRequest, fmt.Sprintf("xhttp: %s invalid request", c.name))
+	}
 
-	// trace.InjectHeader(span.Context(), req)
+	ctx, span := c.tracer.Start(ctx, "xhttp "+c.name+" "+method)
+	defer span.End()
 
-	start := time.Now()
-	resp, err = c.cli.Do(req)
-	duration := time.Since(start)
+	var resp *Response
+	var err error
+retry:
+	for attempt := 0; ; attempt++ {
+		if method == http.MethodGet && c.config.Hedge.Delay > 0 {
+			resp, err = c.hedge(ctx, method, rawURL, r, attempt)
+		} else {
+			resp, err = c.try(ctx, method, rawURL, r, attempt, false)
+		}
+
+		wait, ok := c.retryWait(ctx, method, r, resp, err, attempt)
+		if !ok {
+			break
+		}
+
+		metrics.HTTPRetryCount.WithLabelValues(c.name, "retry").Inc()
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			// 等待期间 ctx 结束，不再发出请求，返回最后一次的结果
+			timer.Stop()
+			break retry
+		case <-timer.C:
+		}
+	}
 
-	// 只有err==nil时 resp不为nil
-	status := http.StatusOK
 	if err != nil {
 		onSpanError(span, err)
-		status = http.StatusGatewayTimeout
-	} else {
-		status = resp.StatusCode
+		return nil, c.transportError(err)
 	}
 
-	log.Get(ctx).Debugf("[HTTP] Get url: %s, status: %d query: %s", url, status, req.URL.RawQuery)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err := c.statusError(resp)
+		onSpanError(span, err)
+		return resp, err
+	}
 
-	//span.SetAttributes(string(ext.Component), "http")
-	//span.SetAttributes(string(ext.HTTPUrl), url)
-	//span.SetAttributes(string(ext.HTTPMethod), req.Method)
-	//span.SetAttributes(string(ext.HTTPStatusCode), status)
+	if r.into != nil && len(resp.Body) > 0 {
+		if err := resp.JSON(r.into); err != nil {
+			return resp, xerrors.Wrap(err, xerrors.Internal, fmt.Sprintf("xhttp: %s decode response failed", c.name))
+		}
+	}
 
-	// url 中带有的纯数字替换成 %d，不然 prometheus 就炸了
-	// /v123/4/56/foo => /v123/%d/%d/foo
-	url = digitsRE.ReplaceAllString(url, "%d")
-	metrics.HTTPDurationSeconds.WithLabelValues(url, fmt.Sprint(status)).Observe(duration.Seconds())
-	return
+	return resp, nil
 }
 
-// DoPost 发起Post请求
-// 注意: 上层应用读取response之后，需要close
-func (c *myClient) DoPost(ctx context.Context, url string, header map[string]string, body interface{}) (resp *http.Response, err error) {
-	tr := otel.Tracer("HTTP-Call")
-	ctx, span := tr.Start(ctx, "do-post-http")
-	defer span.End()
+// newRequest 合并 BaseURL、query、header，body 为空时不设置 Content-Type
+func (c *Client) newRequest(ctx context.Context, method string, rawURL string, r *request) (*http.Request, error) {
+	if c.config.BaseURL != "" && !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
+		rawURL = strings.TrimRight(c.config.BaseURL, "/") + "/" + strings.TrimLeft(rawURL, "/")
+	}
 
-	b, err := json.Marshal(body)
+	u, err := url.Parse(rawURL)
 	if err != nil {
-		return
+		return nil, err
+	}
+	if len(r.query) > 0 {
+		q := u.Query()
+		for k, vs := range r.query {
+			for _, v := range vs {
+				q.Add(k, v)
+			}
+		}
+		u.RawQuery = q.Encode()
 	}
 
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(b))
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
 	if err != nil {
-		onSpanError(span, err)
-		return
+		return nil, err
+	}
+
+	for k, vs := range c.header {
+		req.Header[k] = vs
+	}
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+	if r.into != nil {
+		req.Header.Set("Accept", "application/json")
 	}
+	for k, vs := range r.header {
+		req.Header[k] = vs
+	}
+
+	return req, nil
+}
 
-	// header头设置
-	for k, v := range header {
-		req.Header.Add(k, v)
+// try 经过熔断器发送一次请求
+func (c *Client) try(ctx context.Context, method string, rawURL string, r *request, attempt int, hedged bool) (*Response, error) {
+	var gen uint64
+	if c.breaker != nil {
+		var ok bool
+		if gen, ok = c.breaker.allow(); !ok {
+			metrics.HTTPBreakerRejected.WithLabelValues(c.name).Inc()
+			return nil, errBreakerOpen
+		}
 	}
 
+	req, err := c.newRequest(ctx, method, rawURL, r)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(ctx, req, attempt, hedged)
+
+	if c.breaker != nil {
+		// ctx 取消或者超时是调用方的原因，不计入熔断统计
+		result := resultSuccess
+		if ctx.Err() != nil {
+			result = resultIgnore
+		} else if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+			result = resultFailure
+		}
+		c.breaker.record(gen, result)
+	}
+
+	return resp, err
+}
+
+// send 发送请求并读取响应，记录 span、日志和监控
+func (c *Client) send(ctx context.Context, req *http.Request, attempt int, hedged bool) (*Response, error) {
+	ctx, span := c.tracer.Start(ctx, "HTTP "+req.Method,
+		oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+		oteltrace.WithAttributes(semconv.HTTPClientAttributesFromHTTPRequest(req)...),
+		oteltrace.WithAttributes(semconv.PeerServiceKey.String(c.name), HTTPRetryCount.Int(attempt), HTTPHedged.Bool(hedged)),
+	)
+	defer span.End()
+
+	// 连接、dns、tls 等阶段记录为子 span
+	ctx = httptrace.WithClientTrace(ctx, NewClientTrace(ctx))
 	req = req.WithContext(ctx)
 
-	// trace.InjectHeader(span.Context(), req)
+	// 下游通过 traceparent 串联 trace
+	xtrace.InjectHeader(ctx, req)
 
 	start := time.Now()
-	resp, err = c.cli.Do(req)
+	resp, err := c.do(req)
 	duration := time.Since(start)
 
-	// 只有err==nil时 response不为nil
-	status := http.StatusOK
+	// 只有err==nil时 resp不为nil，网络错误的 status 为 timeout/canceled/error
+	var status string
 	if err != nil {
 		onSpanError(span, err)
-		status = http.StatusGatewayTimeout
+		status = errorStatus(err)
 	} else {
-		status = resp.StatusCode
+		status = strconv.Itoa(resp.StatusCode)
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(resp.StatusCode))
 	}
 
-	log.Get(ctx).Debugf("[HTTP] Get url: %s, status: %d query: %s", url, status, req.URL.RawQuery)
-
-	//span.SetTag(string(ext.Component), "http")
-	//span.SetTag(string(ext.HTTPUrl), url)
-	//span.SetTag(string(ext.HTTPMethod), req.Method)
-	//span.SetTag(string(ext.HTTPStatusCode), status)
+	log.Get(ctx).Debugf("[HTTP] %s %s url: %s, status: %s, retry: %d, hedged: %t, cost: %s", c.name, req.Method, req.URL.Path, status, attempt, hedged, duration)
 
 	// url 中带有的纯数字替换成 %d，不然 prometheus 就炸了
 	// /v123/4/56/foo => /v123/%d/%d/foo
-	url = digitsRE.ReplaceAllString(url, "%d")
-	metrics.HTTPDurationSeconds.WithLabelValues(url, fmt.Sprint(status)).Observe(duration.Seconds())
-	return
+	label := httpURLs.Value(digitsRE.ReplaceAllString(req.URL.Host+req.URL.Path, "%d"))
+	metrics.HTTPDurationSeconds.WithLabelValues(label, status).Observe(duration.Seconds())
+
+	return resp, err
 }
 
-// NewCommonHeader
-func NewCommonHeader() (header map[string]string) {
-	header = map[string]string{
-		"Content-Type": "text/plain",
+// errorStatus 网络错误在监控中的 status
+func errorStatus(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case isTimeout(err):
+		return "timeout"
+	default:
+		return "error"
 	}
-	return
+}
+
+// isTimeout 是否为超时错误
+func isTimeout(err error) bool {
+	var ne interface{ Timeout() bool }
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout())
+}
+
+// do 发送请求，响应超过 MaxResponseSize 时返回错误
+func (c *Client) do(req *http.Request) (*Response, error) {
+	resp, err := c.cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, c.config.MaxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > c.config.MaxResponseSize {
+		return nil, errResponseTooLarge
+	}
+
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
+
+// transportError 网络错误转换为 *errors.Error
+func (c *Client) transportError(err error) *xerrors.Error {
+	switch {
+	case errors.Is(err, errResponseTooLarge):
+		return xerrors.Wrap(err, xerrors.Internal, fmt.Sprintf("xhttp: %s response exceeds %d bytes", c.name, c.config.MaxResponseSize))
+	case errors.Is(err, errBreakerOpen):
+		return xerrors.Wrap(err, xerrors.ServiceUnavailable, fmt.Sprintf("xhttp: %s circuit breaker open", c.name))
+	case isTimeout(err):
+		return xerrors.Wrap(err, xerrors.RequestTimeout, fmt.Sprintf("xhttp: %s timeout", c.name))
+	}
+
+	return xerrors.Wrap(err, xerrors.ServiceUnavailable, fmt.Sprintf("xhttp: %s unavailable", c.name))
+}
+
+// statusError 非 2xx 响应转换为 *errors.Error，下游是本项目的服务时响应为 {code,msg}
+func (c *Client) statusError(resp *Response) *xerrors.Error {
+	var body struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	_ = json.Unmarshal(resp.Body, &body)
+
+	msg := body.Msg
+	if msg == "" {
+		msg = http.StatusText(resp.StatusCode)
+	}
+
+	e := xerrors.FromHTTPStatus(resp.StatusCode, fmt.Sprintf("xhttp: %s: %s", c.name, msg)).WithMetadata("service", c.name)
+	if body.Code != 0 {
+		e = e.WithCode(body.Code)
+	}
+
+	return e
 }
 
 // onSpanError error信息记录到span上
 func onSpanError(span oteltrace.Span, err error) {
-	// span.SetTag(string(ext.Error), true)
-	// span.LogKV(xlog.Error(err))
 	span.RecordError(err)
 	span.SetStatus(codes.Error, err.Error())
 }
@@ -2,58 +2,242 @@ package xhttp
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
+	"errors"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"nautilus/pkg/ctxkit"
+	xerrors "nautilus/pkg/errors"
+	xtrace "nautilus/pkg/trace"
+
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func TestMyClient_DoGet(t *testing.T) {
-	c := NewClient(time.Minute)
+// echo 收到的请求
+type echo struct {
+	Method string              `json:"method"`
+	Path   string              `json:"path"`
+	Query  url.Values          `json:"query"`
+	Header http.Header         `json:"header"`
+	Body   string              `json:"body"`
+	Form   map[string][]string `json:"form"`
+}
 
-	header := map[string]string{
-		"X-Forward-IP": "127.0.0.1",
-		"X-Trace-ID":   "1234",
-	}
+// echoServer 把收到的请求作为 JSON 响应返回
+func echoServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e := echo{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query(), Header: r.Header}
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			_ = r.ParseMultipartForm(1 << 20)
+			e.Form = r.MultipartForm.Value
+			for field, files := range r.MultipartForm.File {
+				f, _ := files[0].Open()
+				b, _ := ioutil.ReadAll(f)
+				e.Form[field] = []string{files[0].Filename, string(b)}
+			}
+		} else {
+			b, _ := ioutil.ReadAll(r.Body)
+			e.Body = string(b)
+		}
 
-	Query := map[string]string{
-		"a": fmt.Sprint(1),
-		"b": "c",
-	}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(e)
+	}))
+}
 
-	resp, err := c.DoGet(context.TODO(), "", header, Query)
-	assert.Nil(t, err)
+func TestClient_Get(t *testing.T) {
+	ts := echoServer()
+	defer ts.Close()
+
+	c := NewClient("echo", WithBaseURL(ts.URL+"/api"), WithDefaultHeader("X-App", "nautilus"))
 
-	body, err := ioutil.ReadAll(resp.Body)
+	var e echo
+	resp, err := c.Get(context.Background(), "/users?a=1",
+		Query("b", "2"),
+		Queries(map[string]string{"c": "3"}),
+		Headers(map[string]string{"X-Forward-IP": "127.0.0.1"}),
+		Into(&e),
+	)
 	assert.Nil(t, err)
-	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, http.MethodGet, e.Method)
+	assert.Equal(t, "/api/users", e.Path)
+	assert.Equal(t, url.Values{"a": {"1"}, "b": {"2"}, "c": {"3"}}, e.Query)
+	assert.Equal(t, "nautilus", e.Header.Get("X-App"))
+	assert.Equal(t, "127.0.0.1", e.Header.Get("X-Forward-IP"))
+	assert.Equal(t, "application/json", e.Header.Get("Accept"))
 
-	fmt.Println(string(body))
+	// 绝对地址不拼接 BaseURL
+	resp, err = c.Get(context.Background(), ts.URL+"/health")
+	assert.Nil(t, err)
+	assert.Nil(t, resp.JSON(&e))
+	assert.Equal(t, "/health", e.Path)
 }
 
-func TestMyClient_DoPOST(t *testing.T) {
-	c := NewClient(time.Minute)
+func TestClient_Body(t *testing.T) {
+	ts := echoServer()
+	defer ts.Close()
 
-	header := map[string]string{
-		"X-Forward-IP": "127.0.0.1",
-		"X-Trace-ID":   "1234",
-	}
+	c := NewClient("echo", WithBaseURL(ts.URL))
+	ctx := context.Background()
 
-	req := struct {
-		Name string `json:"name"`
-		UID  int64  `json:"uid"`
-	}{
-		UID:  10,
-		Name: "foo",
-	}
+	var e echo
+	_, err := c.Post(ctx, "/", JSON(map[string]interface{}{"uid": 10, "name": "foo"}), Into(&e))
+	assert.Nil(t, err)
+	assert.Equal(t, "application/json", e.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"uid":10,"name":"foo"}`, e.Body)
+
+	_, err = c.Put(ctx, "/", Form(url.Values{"name": {"foo bar"}}), Into(&e))
+	assert.Nil(t, err)
+	assert.Equal(t, http.MethodPut, e.Method)
+	assert.Equal(t, "application/x-www-form-urlencoded", e.Header.Get("Content-Type"))
+	assert.Equal(t, "name=foo+bar", e.Body)
+
+	_, err = c.Patch(ctx, "/", Multipart(map[string]string{"name": "foo"}, File{Field: "avatar", Name: "a.txt", Reader: strings.NewReader("hello")}), Into(&e))
+	assert.Nil(t, err)
+	assert.Equal(t, http.MethodPatch, e.Method)
+	assert.Equal(t, []string{"foo"}, e.Form["name"])
+	assert.Equal(t, []string{"a.txt", "hello"}, e.Form["avatar"])
 
-	resp, err := c.DoPost(context.TODO(), "", header, req)
+	_, err = c.Delete(ctx, "/", Body([]byte("raw"), "text/plain"), Into(&e))
+	assert.Nil(t, err)
+	assert.Equal(t, http.MethodDelete, e.Method)
+	assert.Equal(t, "text/plain", e.Header.Get("Content-Type"))
+	assert.Equal(t, "raw", e.Body)
+
+	resp, err := c.Head(ctx, "/")
+	assert.Nil(t, err)
+	assert.Empty(t, resp.Body)
 
-	body, err := ioutil.ReadAll(resp.Body)
+	_, err = c.Options(ctx, "/", Into(&e))
 	assert.Nil(t, err)
-	defer resp.Body.Close()
+	assert.Equal(t, http.MethodOptions, e.Method)
+
+	// 编码失败时不发送请求
+	_, err = c.Post(ctx, "/", JSON(make(chan int)))
+	assert.Equal(t, xerrors.BadRequest, xerrors.FromError(err).Type)
+}
 
-	fmt.Println(string(body))
+func TestClient_Error(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/biz":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"code":10001,"msg":"user not found"}`))
+		case "/large":
+			_, _ = w.Write([]byte(strings.Repeat("a", 100)))
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		case "/bad":
+			_, _ = w.Write([]byte(`{`))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer ts.Close()
+
+	c := NewClient("user", WithBaseURL(ts.URL), WithMaxResponseSize(50), WithTimeout(100*time.Millisecond))
+	ctx := context.Background()
+
+	resp, err := c.Get(ctx, "/biz")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	var e *xerrors.Error
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, xerrors.NotFound, e.Type)
+	assert.Equal(t, 10001, e.Code())
+	assert.Equal(t, "xhttp: user: user not found", e.Msg())
+	assert.Equal(t, "user", e.Metadata["service"])
+
+	_, err = c.Get(ctx, "/502")
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, xerrors.ServiceUnavailable, e.Type)
+	assert.Equal(t, http.StatusBadGateway, e.Code())
+
+	_, err = c.Get(ctx, "/large")
+	assert.True(t, errors.Is(err, errResponseTooLarge))
+
+	_, err = c.Get(ctx, "/slow")
+	assert.Equal(t, xerrors.RequestTimeout, xerrors.FromError(err).Type)
+
+	var v map[string]interface{}
+	_, err = c.Get(ctx, "/bad", Into(&v))
+	assert.Equal(t, xerrors.Internal, xerrors.FromError(err).Type)
+
+	ts.Close()
+	_, err = c.Get(ctx, "/")
+	assert.Equal(t, xerrors.ServiceUnavailable, xerrors.FromError(err).Type)
+}
+
+func TestConfigFromConf(t *testing.T) {
+	c := ConfigFromConf("user-svc")
+	assert.Equal(t, defaultTimeout, c.Timeout)
+	assert.Equal(t, int64(defaultMaxResponseSize), c.MaxResponseSize)
+	assert.Equal(t, 10, c.MaxIdleConnsPerHost)
+
+	os.Setenv("XHTTP_USER_SVC_BASE_URL", "http://user.svc:8080")
+	os.Setenv("XHTTP_USER_SVC_TIMEOUT", "500ms")
+	os.Setenv("XHTTP_USER_SVC_MAX_CONNS_PER_HOST", "20")
+	defer func() {
+		os.Unsetenv("XHTTP_USER_SVC_BASE_URL")
+		os.Unsetenv("XHTTP_USER_SVC_TIMEOUT")
+		os.Unsetenv("XHTTP_USER_SVC_MAX_CONNS_PER_HOST")
+	}()
+
+	c = ConfigFromConf("user-svc")
+	assert.Equal(t, "http://user.svc:8080", c.BaseURL)
+	assert.Equal(t, 500*time.Millisecond, c.Timeout)
+	assert.Equal(t, 20, c.MaxConnsPerHost)
+
+	assert.Same(t, Get("user-svc"), Get("user-svc"))
+	assert.Equal(t, "user-svc", Get("user-svc").Name())
+}
+
+func TestPropagation(t *testing.T) {
+	assert.Nil(t, xtrace.Init(context.Background(), &xtrace.Config{Sampler: 1, B3: true}))
+	defer xtrace.Stop()
+
+	ts := echoServer()
+	defer ts.Close()
+
+	ctx := ctxkit.WithUID(context.Background(), 10)
+	ctx = ctxkit.WithPlatform(ctx, "ios")
+	ctx = ctxkit.WithAppkey(ctx, "app1")
+	member, _ := baggage.NewMember("tenant", "t1")
+	bag, _ := baggage.New(member)
+	ctx = baggage.ContextWithBaggage(ctx, bag)
+
+	c := NewClient("echo", WithBaseURL(ts.URL))
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		t.Run(method, func(t *testing.T) {
+			var e echo
+			_, err := c.Do(ctx, method, "/", Into(&e))
+			assert.Nil(t, err)
+			header := e.Header
+
+			// 下游解析出的 span 和 b3 头一致
+			sc := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(context.Background(), propagation.HeaderCarrier(header)))
+			assert.True(t, sc.IsValid())
+			assert.True(t, sc.IsSampled())
+			assert.Equal(t, sc.TraceID().String(), header.Get("X-B3-Traceid"))
+			assert.Equal(t, sc.SpanID().String(), header.Get("X-B3-Spanid"))
+			assert.Equal(t, "1", header.Get("X-B3-Sampled"))
+
+			got, err := baggage.Parse(header.Get("Baggage"))
+			assert.Nil(t, err)
+			assert.Equal(t, "10", got.Member(xtrace.BaggageUID).Value())
+			assert.Equal(t, "ios", got.Member(xtrace.BaggagePlatform).Value())
+			assert.Equal(t, "app1", got.Member(xtrace.BaggageAppkey).Value())
+			assert.Equal(t, "t1", got.Member("tenant").Value())
+		})
+	}
 }
@@ -0,0 +1,368 @@
+package xhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"nautilus/pkg/conf"
+)
+
+const (
+	defaultTimeout         = 3 * time.Second
+	defaultMaxResponseSize = 10 << 20
+)
+
+// Config 下游服务的配置，每个下游服务一个连接池
+type Config struct {
+	// BaseURL 相对路径的请求会拼接在 BaseURL 之后，例如 http://user.svc:8080
+	BaseURL string
+	// Timeout 单次请求的超时时间，包括读取响应
+	Timeout time.Duration
+	// MaxResponseSize 响应最大字节数，超过时返回错误
+	MaxResponseSize int64
+
+	// 连接池配置，参考 http.Transport
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+
+	Retry   RetryConfig
+	Hedge   HedgeConfig
+	Breaker BreakerConfig
+}
+
+// ConfigFromConf 从配置中读取下游服务的配置，name 为下游服务名，没有配置的项使用默认值
+//
+//	XHTTP_${NAME}_BASE_URL                 下游服务地址
+//	XHTTP_${NAME}_TIMEOUT                  超时时间，默认 3s
+//	XHTTP_${NAME}_MAX_RESPONSE_SIZE        响应最大字节数，默认 10MB
+//	XHTTP_${NAME}_MAX_IDLE_CONNS           最大空闲连接数，默认 100
+//	XHTTP_${NAME}_MAX_IDLE_CONNS_PER_HOST  每个 host 最大空闲连接数，默认 10
+//	XHTTP_${NAME}_MAX_CONNS_PER_HOST       每个 host 最大连接数，默认 0 不限制
+//	XHTTP_${NAME}_IDLE_CONN_TIMEOUT        空闲连接超时时间，默认 90s
+//
+// 重试、对冲和熔断，参考 RetryConfig、HedgeConfig、BreakerConfig
+//
+//	XHTTP_${NAME}_RETRY_MAX                    最多重试次数，默认 0 不重试
+//	XHTTP_${NAME}_RETRY_BACKOFF                第一次重试的等待时间，默认 50ms
+//	XHTTP_${NAME}_RETRY_MAX_BACKOFF            最长等待时间，默认 1s
+//	XHTTP_${NAME}_RETRY_STATUS                 重试的状态码，逗号分隔，默认 429,502,503,504
+//	XHTTP_${NAME}_HEDGE_DELAY                  GET 请求多久没有响应时发起对冲请求，默认 0 不对冲
+//	XHTTP_${NAME}_HEDGE_MAX                    最多对冲请求数，默认 1
+//	XHTTP_${NAME}_BREAKER_FAILURE_RATIO        熔断的失败比例，默认 0 不熔断
+//	XHTTP_${NAME}_BREAKER_MIN_REQUESTS         统计窗口内最少请求数，默认 20
+//	XHTTP_${NAME}_BREAKER_WINDOW               统计窗口，默认 10s
+//	XHTTP_${NAME}_BREAKER_OPEN_TIMEOUT         熔断之后多久进入半开状态，默认 5s
+//	XHTTP_${NAME}_BREAKER_HALF_OPEN_REQUESTS   半开状态的探测请求数，默认 1
+func ConfigFromConf(name string) Config {
+	c := Config{
+		Timeout:             defaultTimeout,
+		MaxResponseSize:     defaultMaxResponseSize,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	prefix := "XHTTP_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(name)) + "_"
+	c.BaseURL = conf.Get(prefix + "BASE_URL")
+	if conf.Get(prefix+"TIMEOUT") != "" {
+		c.Timeout = conf.GetDuration(prefix + "TIMEOUT")
+	}
+	if conf.Get(prefix+"MAX_RESPONSE_SIZE") != "" {
+		c.MaxResponseSize = conf.GetInt64(prefix + "MAX_RESPONSE_SIZE")
+	}
+	if conf.Get(prefix+"MAX_IDLE_CONNS") != "" {
+		c.MaxIdleConns = int(conf.GetInt64(prefix + "MAX_IDLE_CONNS"))
+	}
+	if conf.Get(prefix+"MAX_IDLE_CONNS_PER_HOST") != "" {
+		c.MaxIdleConnsPerHost = int(conf.GetInt64(prefix + "MAX_IDLE_CONNS_PER_HOST"))
+	}
+	if conf.Get(prefix+"MAX_CONNS_PER_HOST") != "" {
+		c.MaxConnsPerHost = int(conf.GetInt64(prefix + "MAX_CONNS_PER_HOST"))
+	}
+	if conf.Get(prefix+"IDLE_CONN_TIMEOUT") != "" {
+		c.IdleConnTimeout = conf.GetDuration(prefix + "IDLE_CONN_TIMEOUT")
+	}
+
+	c.Retry.MaxRetries = int(conf.GetInt64(prefix + "RETRY_MAX"))
+	if conf.Get(prefix+"RETRY_BACKOFF") != "" {
+		c.Retry.Backoff = conf.GetDuration(prefix + "RETRY_BACKOFF")
+	}
+	if conf.Get(prefix+"RETRY_MAX_BACKOFF") != "" {
+		c.Retry.MaxBackoff = conf.GetDuration(prefix + "RETRY_MAX_BACKOFF")
+	}
+	if codes := conf.GetStrings(prefix + "RETRY_STATUS"); len(codes) > 0 {
+		for _, code := range codes {
+			if s, err := strconv.Atoi(strings.TrimSpace(code)); err == nil {
+				c.Retry.Status = append(c.Retry.Status, s)
+			}
+		}
+	}
+
+	c.Hedge.Delay = conf.GetDuration(prefix + "HEDGE_DELAY")
+	if conf.Get(prefix+"HEDGE_MAX") != "" {
+		c.Hedge.Max = int(conf.GetInt64(prefix + "HEDGE_MAX"))
+	}
+
+	c.Breaker.FailureRatio = conf.GetFloat64(prefix + "BREAKER_FAILURE_RATIO")
+	if conf.Get(prefix+"BREAKER_MIN_REQUESTS") != "" {
+		c.Breaker.MinRequests = int(conf.GetInt64(prefix + "BREAKER_MIN_REQUESTS"))
+	}
+	if conf.Get(prefix+"BREAKER_WINDOW") != "" {
+		c.Breaker.Window = conf.GetDuration(prefix + "BREAKER_WINDOW")
+	}
+	if conf.Get(prefix+"BREAKER_OPEN_TIMEOUT") != "" {
+		c.Breaker.OpenTimeout = conf.GetDuration(prefix + "BREAKER_OPEN_TIMEOUT")
+	}
+	if conf.Get(prefix+"BREAKER_HALF_OPEN_REQUESTS") != "" {
+		c.Breaker.HalfOpenRequests = int(conf.GetInt64(prefix + "BREAKER_HALF_OPEN_REQUESTS"))
+	}
+
+	c.setDefaults()
+	return c
+}
+
+// setDefaults 填充为 0 的配置，Timeout 为 0 时不限制，不会被替换
+func (c *Config) setDefaults() {
+	if c.MaxResponseSize <= 0 {
+		c.MaxResponseSize = defaultMaxResponseSize
+	}
+
+	if c.Retry.Backoff <= 0 {
+		c.Retry.Backoff = 50 * time.Millisecond
+	}
+	if c.Retry.MaxBackoff <= 0 {
+		c.Retry.MaxBackoff = time.Second
+	}
+	if len(c.Retry.Status) == 0 {
+		c.Retry.Status = defaultRetryStatus
+	}
+
+	if c.Hedge.Max <= 0 {
+		c.Hedge.Max = 1
+	}
+
+	if c.Breaker.MinRequests <= 0 {
+		c.Breaker.MinRequests = 20
+	}
+	if c.Breaker.Window <= 0 {
+		c.Breaker.Window = 10 * time.Second
+	}
+	if c.Breaker.OpenTimeout <= 0 {
+		c.Breaker.OpenTimeout = 5 * time.Second
+	}
+	if c.Breaker.HalfOpenRequests <= 0 {
+		c.Breaker.HalfOpenRequests = 1
+	}
+}
+
+// transport 根据连接池配置创建 Transport
+func (c Config) transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = c.MaxIdleConns
+	t.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	t.MaxConnsPerHost = c.MaxConnsPerHost
+	t.IdleConnTimeout = c.IdleConnTimeout
+	return t
+}
+
+// Option 创建 Client 的选项，优先于配置
+type Option func(c *Client)
+
+// WithBaseURL 下游服务地址
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.config.BaseURL = baseURL
+	}
+}
+
+// WithTimeout 单次请求的超时时间
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.config.Timeout = timeout
+	}
+}
+
+// WithMaxResponseSize 响应最大字节数
+func WithMaxResponseSize(size int64) Option {
+	return func(c *Client) {
+		c.config.MaxResponseSize = size
+	}
+}
+
+// WithRetry 重试配置
+func WithRetry(retry RetryConfig) Option {
+	return func(c *Client) {
+		c.config.Retry = retry
+	}
+}
+
+// WithHedge 对冲配置
+func WithHedge(hedge HedgeConfig) Option {
+	return func(c *Client) {
+		c.config.Hedge = hedge
+	}
+}
+
+// WithBreaker 熔断配置
+func WithBreaker(breaker BreakerConfig) Option {
+	return func(c *Client) {
+		c.config.Breaker = breaker
+	}
+}
+
+// WithConfig 替换所有配置，不再读取 XHTTP_${NAME}_*
+func WithConfig(config Config) Option {
+	return func(c *Client) {
+		c.config = config
+	}
+}
+
+// WithTransport 替换连接池，例如测试中使用 httptest.Server 的 Client().Transport
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.transport = rt
+	}
+}
+
+// WithDefaultHeader 所有请求都带上的 header，请求中设置的同名 header 优先
+func WithDefaultHeader(key, value string) Option {
+	return func(c *Client) {
+		c.header.Set(key, value)
+	}
+}
+
+// request 单次请求的参数，body 在发送之前编码好，方便重试时重复发送
+type request struct {
+	header      http.Header
+	query       url.Values
+	body        []byte
+	contentType string
+	// into 2xx 时把响应解析到 into
+	into interface{}
+	// idempotent 非幂等的方法也可以重试
+	idempotent bool
+	err        error
+}
+
+// RequestOption 单次请求的选项
+type RequestOption func(r *request)
+
+// Header 设置请求头
+func Header(key, value string) RequestOption {
+	return func(r *request) {
+		r.header.Set(key, value)
+	}
+}
+
+// Headers 设置多个请求头
+func Headers(header map[string]string) RequestOption {
+	return func(r *request) {
+		for k, v := range header {
+			r.header.Set(k, v)
+		}
+	}
+}
+
+// Query 添加 query 参数，url 中已有的参数会保留
+func Query(key, value string) RequestOption {
+	return func(r *request) {
+		r.query.Add(key, value)
+	}
+}
+
+// Queries 添加多个 query 参数
+func Queries(query map[string]string) RequestOption {
+	return func(r *request) {
+		for k, v := range query {
+			r.query.Add(k, v)
+		}
+	}
+}
+
+// JSON 请求体编码为 JSON
+func JSON(body interface{}) RequestOption {
+	return func(r *request) {
+		r.body, r.err = json.Marshal(body)
+		r.contentType = "application/json"
+	}
+}
+
+// Form 请求体编码为 application/x-www-form-urlencoded
+func Form(form url.Values) RequestOption {
+	return func(r *request) {
+		r.body = []byte(form.Encode())
+		r.contentType = "application/x-www-form-urlencoded"
+	}
+}
+
+// File multipart 请求中的文件
+type File struct {
+	// Field 表单字段名
+	Field string
+	// Name 文件名
+	Name   string
+	Reader io.Reader
+}
+
+// Multipart 请求体编码为 multipart/form-data，文件内容会读到内存中
+func Multipart(fields map[string]string, files ...File) RequestOption {
+	return func(r *request) {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		for k, v := range fields {
+			if r.err = w.WriteField(k, v); r.err != nil {
+				return
+			}
+		}
+		for _, f := range files {
+			fw, err := w.CreateFormFile(f.Field, f.Name)
+			if err == nil {
+				_, err = io.Copy(fw, f.Reader)
+			}
+			if err != nil {
+				r.err = err
+				return
+			}
+		}
+		if r.err = w.Close(); r.err != nil {
+			return
+		}
+
+		r.body = buf.Bytes()
+		r.contentType = w.FormDataContentType()
+	}
+}
+
+// Body 原样发送请求体
+func Body(body []byte, contentType string) RequestOption {
+	return func(r *request) {
+		r.body = body
+		r.contentType = contentType
+	}
+}
+
+// Idempotent 声明请求是幂等的，POST/PATCH 等方法也会重试，例如带有幂等 key 的请求
+func Idempotent() RequestOption {
+	return func(r *request) {
+		r.idempotent = true
+	}
+}
+
+// Into 2xx 时把 JSON 响应解析到 v，v 需要是指针
+//
+//	var user User
+//	_, err := client.Get(ctx, "/users/1", xhttp.Into(&user))
+func Into(v interface{}) RequestOption {
+	return func(r *request) {
+		r.into = v
+	}
+}
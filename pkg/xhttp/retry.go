@@ -0,0 +1,180 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"nautilus/pkg/metrics"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// span 属性
+var (
+	// HTTPRetryCount 第几次重试，0 为第一次请求
+	HTTPRetryCount = attribute.Key("http.retry_count")
+	// HTTPHedged 是否为对冲请求
+	HTTPHedged = attribute.Key("http.hedged")
+)
+
+// defaultRetryStatus 默认重试的状态码
+var defaultRetryStatus = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryConfig 重试配置，MaxRetries 为 0 时不重试
+//
+// 只重试幂等的请求：GET/HEAD/OPTIONS/PUT/DELETE，其他方法需要通过 Idempotent 声明
+// 网络错误和 Status 中的状态码会重试，等待时间为指数退避加随机抖动，不超过 MaxBackoff
+// 响应带有 Retry-After 时至少等待 Retry-After，超过 MaxBackoff 或者 ctx 的 deadline 时不再重试
+type RetryConfig struct {
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Status     []int
+}
+
+// HedgeConfig 对冲配置，Delay 为 0 时不对冲，只对 GET 生效
+// 请求发出 Delay 之后还没有响应时再发一个相同的请求，最多 Max 个，使用最先成功的响应，其他请求取消
+type HedgeConfig struct {
+	Delay time.Duration
+	Max   int
+}
+
+// idempotent 幂等的方法
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+
+	return false
+}
+
+// retryStatus 是否为需要重试的状态码
+func (c *Client) retryStatus(status int) bool {
+	for _, s := range c.config.Retry.Status {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backoff 第 attempt 次重试前的等待时间，在 [d/2, d) 之间随机，d 为 Backoff*2^attempt
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.config.Retry.MaxBackoff
+	if attempt < 32 {
+		if exp := c.config.Retry.Backoff << uint(attempt); exp > 0 && exp < d {
+			d = exp
+		}
+	}
+	if d <= 1 {
+		return d
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+// retryWait 是否重试以及重试前的等待时间
+func (c *Client) retryWait(ctx context.Context, method string, r *request, resp *Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= c.config.Retry.MaxRetries || ctx.Err() != nil {
+		return 0, false
+	}
+	if !idempotent(method) && !r.idempotent {
+		return 0, false
+	}
+
+	wait := c.backoff(attempt)
+	if err != nil {
+		if errors.Is(err, errBreakerOpen) || errors.Is(err, errResponseTooLarge) {
+			return 0, false
+		}
+	} else {
+		if !c.retryStatus(resp.StatusCode) {
+			return 0, false
+		}
+		if ra, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			if ra > c.config.Retry.MaxBackoff {
+				return 0, false
+			}
+			if ra > wait {
+				wait = ra
+			}
+		}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+		return 0, false
+	}
+
+	return wait, true
+}
+
+// retryAfter 解析 Retry-After，支持秒数和 http 时间
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+
+	return 0, false
+}
+
+// hedge 发起对冲请求，返回最先成功的响应；都失败时返回最后一个结果，由调用方决定是否重试
+func (c *Client) hedge(ctx context.Context, method string, rawURL string, r *request, attempt int) (*Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		resp *Response
+		err  error
+	}
+	results := make(chan result, c.config.Hedge.Max+1)
+	launch := func(hedged bool) {
+		go func() {
+			resp, err := c.try(ctx, method, rawURL, r, attempt, hedged)
+			results <- result{resp, err}
+		}()
+	}
+
+	launch(false)
+	inflight, hedges := 1, 0
+	timer := time.NewTimer(c.config.Hedge.Delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case res := <-results:
+			inflight--
+			if (res.err == nil && !c.retryStatus(res.resp.StatusCode)) || inflight == 0 {
+				return res.resp, res.err
+			}
+		case <-timer.C:
+			if hedges < c.config.Hedge.Max {
+				hedges++
+				inflight++
+				metrics.HTTPRetryCount.WithLabelValues(c.name, "hedge").Inc()
+				launch(true)
+				timer.Reset(c.config.Hedge.Delay)
+			}
+		}
+	}
+}
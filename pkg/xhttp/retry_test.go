@@ -0,0 +1,341 @@
+package xhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	xerrors "nautilus/pkg/errors"
+	"nautilus/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// flakyServer 前 failures 个请求返回 status，之后返回 200
+func flakyServer(failures int32, status int, header map[string]string) (*httptest.Server, *int32) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) <= failures {
+			for k, v := range header {
+				w.Header().Set(k, v)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+
+	return ts, &hits
+}
+
+func TestRetry(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+
+	ts, hits := flakyServer(2, http.StatusServiceUnavailable, nil)
+	defer ts.Close()
+
+	c := NewClient("flaky", WithBaseURL(ts.URL), WithRetry(RetryConfig{MaxRetries: 3, Backoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}))
+	retries := testutil.ToFloat64(metrics.HTTPRetryCount.WithLabelValues("flaky", "retry"))
+
+	var v struct{ OK bool }
+	resp, err := c.Get(context.Background(), "/", Into(&v))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, v.OK)
+	assert.Equal(t, int32(3), atomic.LoadInt32(hits))
+	assert.Equal(t, retries+2, testutil.ToFloat64(metrics.HTTPRetryCount.WithLabelValues("flaky", "retry")))
+
+	// 每次请求都是 Do 的子 span
+	var parent sdktrace.ReadOnlySpan
+	var attempts []sdktrace.ReadOnlySpan
+	for _, s := range sr.Ended() {
+		switch s.Name() {
+		case "xhttp flaky GET":
+			parent = s
+		case "HTTP GET":
+			attempts = append(attempts, s)
+		}
+	}
+	assert.NotNil(t, parent)
+	assert.Len(t, attempts, 3)
+	for i, s := range attempts {
+		assert.Equal(t, parent.SpanContext().SpanID(), s.Parent().SpanID())
+		for _, attr := range s.Attributes() {
+			if attr.Key == HTTPRetryCount {
+				assert.Equal(t, int64(i), attr.Value.AsInt64())
+			}
+		}
+	}
+
+	// 超过最多重试次数时返回最后一次的错误
+	ts2, hits2 := flakyServer(10, http.StatusBadGateway, nil)
+	defer ts2.Close()
+	c = NewClient("flaky", WithBaseURL(ts2.URL), WithRetry(RetryConfig{MaxRetries: 2, Backoff: time.Millisecond}))
+	resp, err = c.Get(context.Background(), "/")
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, xerrors.ServiceUnavailable, xerrors.FromError(err).Type)
+	assert.Equal(t, int32(3), atomic.LoadInt32(hits2))
+}
+
+func TestRetry_Idempotent(t *testing.T) {
+	ts, hits := flakyServer(1, http.StatusServiceUnavailable, nil)
+	defer ts.Close()
+
+	c := NewClient("flaky", WithBaseURL(ts.URL), WithRetry(RetryConfig{MaxRetries: 3, Backoff: time.Millisecond}))
+
+	// POST 默认不重试
+	_, err := c.Post(context.Background(), "/", JSON(map[string]int{"a": 1}))
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(hits))
+
+	// 不在 Status 中的状态码不重试
+	atomic.StoreInt32(hits, -10)
+	_, err = NewClient("flaky", WithBaseURL(ts.URL), WithRetry(RetryConfig{MaxRetries: 3, Status: []int{http.StatusBadGateway}})).Get(context.Background(), "/")
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(-9), atomic.LoadInt32(hits))
+
+	// 声明幂等之后重试，body 每次都会重新发送
+	atomic.StoreInt32(hits, 0)
+	var v struct{ OK bool }
+	_, err = c.Post(context.Background(), "/", JSON(map[string]int{"a": 1}), Idempotent(), Into(&v))
+	assert.Nil(t, err)
+	assert.True(t, v.OK)
+	assert.Equal(t, int32(2), atomic.LoadInt32(hits))
+}
+
+func TestRetry_RetryAfter(t *testing.T) {
+	ts, hits := flakyServer(1, http.StatusTooManyRequests, map[string]string{"Retry-After": "1"})
+	defer ts.Close()
+
+	c := NewClient("flaky", WithBaseURL(ts.URL), WithRetry(RetryConfig{MaxRetries: 1, Backoff: time.Millisecond, MaxBackoff: 2 * time.Second}))
+	start := time.Now()
+	_, err := c.Get(context.Background(), "/")
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) >= time.Second)
+	assert.Equal(t, int32(2), atomic.LoadInt32(hits))
+
+	// Retry-After 超过 MaxBackoff 时不重试
+	atomic.StoreInt32(hits, 0)
+	c = NewClient("flaky", WithBaseURL(ts.URL), WithRetry(RetryConfig{MaxRetries: 1, MaxBackoff: 100 * time.Millisecond}))
+	_, err = c.Get(context.Background(), "/")
+	assert.Equal(t, xerrors.TooManyRequests, xerrors.FromError(err).Type)
+	assert.Equal(t, int32(1), atomic.LoadInt32(hits))
+
+	// 等待时间超过 ctx 的 deadline 时不重试
+	atomic.StoreInt32(hits, 0)
+	c = NewClient("flaky", WithBaseURL(ts.URL), WithRetry(RetryConfig{MaxRetries: 1, MaxBackoff: 2 * time.Second}))
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	_, err = c.Get(ctx, "/")
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(start) < 200*time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(hits))
+
+	d, ok := retryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.True(t, ok)
+	assert.True(t, d > 58*time.Second && d <= time.Minute)
+	_, ok = retryAfter("soon")
+	assert.False(t, ok)
+}
+
+func TestRetry_NetworkError(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			// 直接断开连接
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c := NewClient("flaky", WithBaseURL(ts.URL), WithRetry(RetryConfig{MaxRetries: 1, Backoff: time.Millisecond}))
+	resp, err := c.Delete(context.Background(), "/")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
+
+func TestBackoff(t *testing.T) {
+	c := NewClient("flaky", WithRetry(RetryConfig{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}))
+	for attempt, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		d := c.backoff(attempt)
+		assert.True(t, d >= max/2 && d < max, "attempt %d: %s", attempt, d)
+	}
+	assert.True(t, c.backoff(100) < time.Second)
+}
+
+func TestHedge(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			// 第一个请求很慢，直到被取消
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+			return
+		}
+		_, _ = w.Write([]byte("fast"))
+	}))
+	defer ts.Close()
+
+	c := NewClient("hedge", WithBaseURL(ts.URL), WithHedge(HedgeConfig{Delay: 50 * time.Millisecond}))
+	hedges := testutil.ToFloat64(metrics.HTTPRetryCount.WithLabelValues("hedge", "hedge"))
+
+	start := time.Now()
+	resp, err := c.Get(context.Background(), "/")
+	assert.Nil(t, err)
+	assert.Equal(t, "fast", string(resp.Body))
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	assert.Equal(t, hedges+1, testutil.ToFloat64(metrics.HTTPRetryCount.WithLabelValues("hedge", "hedge")))
+
+	// 只对 GET 对冲
+	atomic.StoreInt32(&hits, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err = c.Put(ctx, "/")
+	assert.Equal(t, xerrors.RequestTimeout, xerrors.FromError(err).Type)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestBreaker(t *testing.T) {
+	var healthy int32
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	c := NewClient("breaker", WithBaseURL(ts.URL), WithBreaker(BreakerConfig{FailureRatio: 0.5, MinRequests: 4, OpenTimeout: 100 * time.Millisecond}))
+	ctx := context.Background()
+
+	for i := 0; i < 4; i++ {
+		_, err := c.Get(ctx, "/")
+		assert.Equal(t, xerrors.Internal, xerrors.FromError(err).Type)
+	}
+
+	// 打开之后请求不会发出
+	rejected := testutil.ToFloat64(metrics.HTTPBreakerRejected.WithLabelValues("breaker"))
+	_, err := c.Get(ctx, "/")
+	assert.Equal(t, xerrors.ServiceUnavailable, xerrors.FromError(err).Type)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&hits))
+	assert.Equal(t, rejected+1, testutil.ToFloat64(metrics.HTTPBreakerRejected.WithLabelValues("breaker")))
+	assert.Equal(t, float64(stateOpen), testutil.ToFloat64(metrics.HTTPBreakerState.WithLabelValues("breaker")))
+
+	// 半开状态探测失败，重新打开
+	time.Sleep(120 * time.Millisecond)
+	_, err = c.Get(ctx, "/")
+	assert.Equal(t, xerrors.Internal, xerrors.FromError(err).Type)
+	_, err = c.Get(ctx, "/")
+	assert.Equal(t, xerrors.ServiceUnavailable, xerrors.FromError(err).Type)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&hits))
+
+	// 探测成功，关闭
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(120 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		_, err = c.Get(ctx, "/")
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, float64(stateClosed), testutil.ToFloat64(metrics.HTTPBreakerState.WithLabelValues("breaker")))
+}
+
+func TestBreaker_State(t *testing.T) {
+	now := time.Now()
+	b := newBreaker("state", BreakerConfig{FailureRatio: 0.5, MinRequests: 2, Window: time.Second, OpenTimeout: time.Second, HalfOpenRequests: 2})
+	b.now = func() time.Time { return now }
+	b.windowStart = now
+
+	// 窗口过期之后重新统计
+	gen, ok := b.allow()
+	assert.True(t, ok)
+	b.record(gen, resultFailure)
+	now = now.Add(time.Second)
+	gen, _ = b.allow()
+	b.record(gen, resultSuccess)
+	gen, _ = b.allow()
+	b.record(gen, resultIgnore)
+	assert.Equal(t, stateClosed, b.state)
+
+	gen, _ = b.allow()
+	b.record(gen, resultFailure)
+	assert.Equal(t, stateOpen, b.state)
+	_, ok = b.allow()
+	assert.False(t, ok)
+
+	// 半开状态最多放行 HalfOpenRequests 个探测请求，打开之前放行的请求结果不计入
+	now = now.Add(time.Second)
+	g1, ok := b.allow()
+	assert.True(t, ok)
+	assert.Equal(t, stateHalfOpen, b.state)
+	b.record(gen, resultFailure)
+	assert.Equal(t, stateHalfOpen, b.state)
+	g2, ok := b.allow()
+	assert.True(t, ok)
+	_, ok = b.allow()
+	assert.False(t, ok)
+
+	// 取消的探测请求让出名额
+	b.record(g2, resultIgnore)
+	g2, ok = b.allow()
+	assert.True(t, ok)
+
+	b.record(g1, resultSuccess)
+	assert.Equal(t, stateHalfOpen, b.state)
+	b.record(g2, resultSuccess)
+	assert.Equal(t, stateClosed, b.state)
+}
+
+func TestRetry_ContextDone(t *testing.T) {
+	ts, hits := flakyServer(10, http.StatusServiceUnavailable, nil)
+	defer ts.Close()
+
+	// 等待重试期间 ctx 被取消，不再发出请求
+	c := NewClient("ctxdone", WithBaseURL(ts.URL), WithRetry(RetryConfig{MaxRetries: 3, Backoff: time.Second, MaxBackoff: time.Second}))
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	resp, err := c.Get(ctx, "/")
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(hits))
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestBreaker_ContextDone(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	// 调用方超时不计入失败，熔断器保持关闭
+	c := NewClient("breakerctx", WithBaseURL(ts.URL), WithBreaker(BreakerConfig{FailureRatio: 0.5, MinRequests: 2, Window: time.Minute, OpenTimeout: time.Minute}))
+	for i := 0; i < 4; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err := c.Get(ctx, "/")
+		cancel()
+		assert.NotNil(t, err)
+	}
+	assert.Equal(t, stateClosed, c.breaker.state)
+}
@@ -0,0 +1,191 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	"nautilus/dao/admin"
+	"nautilus/pkg/errors"
+	"nautilus/pkg/jwt"
+	"nautilus/pkg/sqlx"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+// schema sqlite 内存数据库代替 mysql
+const schema = `
+CREATE TABLE t_admin (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT UNIQUE,
+	password TEXT NOT NULL DEFAULT '',
+	phone TEXT UNIQUE,
+	role_type INTEGER NOT NULL DEFAULT 0,
+	ctime DATETIME NOT NULL,
+	mtime DATETIME NOT NULL
+);
+CREATE TABLE t_token (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	uid INTEGER NOT NULL DEFAULT 0,
+	` + "`key`" + ` TEXT NOT NULL UNIQUE,
+	ctime DATETIME NOT NULL,
+	mtime DATETIME NOT NULL
+);`
+
+func TestMain(m *testing.M) {
+	db, err := sqlx.Open("pension", "sqlite3", "file:pension?mode=memory&cache=shared")
+	if err != nil {
+		panic(err)
+	}
+	db.MustExec(schema)
+
+	if err := jwt.SetKeys(jwt.NewHMACKey("test", []byte("0123456789abcdef0123456789abcdef"))); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// smsBox 记录发送的验证码
+type smsBox map[string]string
+
+func (b smsBox) send(ctx context.Context, phone, code string) error {
+	b[phone] = code
+	return nil
+}
+
+func TestLoginByPassword(t *testing.T) {
+	ctx := context.TODO()
+
+	id, err := CreateAdmin(ctx, admin.Profile{Username: "normal", Password: "123456"})
+	assert.Nil(t, err)
+
+	u, err := admin.QueryByUID(ctx, id)
+	assert.Nil(t, err)
+	assert.NotEqual(t, "123456", u.Password)
+
+	_, err = LoginByPassword(ctx, "normal", "654321")
+	assert.ErrorIs(t, err, ErrLoginFailed)
+
+	_, err = LoginByPassword(ctx, "nobody", "123456")
+	assert.ErrorIs(t, err, ErrLoginFailed)
+
+	p, err := LoginByPassword(ctx, "normal", "123456")
+	assert.Nil(t, err)
+
+	c, err := jwt.Verify(p.AccessToken, jwt.TypeAccess)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(id), c.UID)
+
+	revoked, err := IsRevoked(ctx, c)
+	assert.Nil(t, err)
+	assert.False(t, revoked)
+
+	// 超级管理员不能使用密码登录
+	_, err = CreateAdmin(ctx, admin.Profile{Username: "root", Password: "123456", Phone: "13800000000", RoleType: admin.RoleSuper})
+	assert.Nil(t, err)
+	_, err = LoginByPassword(ctx, "root", "123456")
+	assert.ErrorIs(t, err, ErrPhoneLoginRequired)
+}
+
+func TestLoginByPhone(t *testing.T) {
+	ctx := context.TODO()
+	box := smsBox{}
+	Codes, SendSMS = NewMemoryCodeStore(), box.send
+
+	id, err := CreateAdmin(ctx, admin.Profile{Username: "super", Password: "123456", Phone: "13900000000", RoleType: admin.RoleSuper})
+	assert.Nil(t, err)
+	_, err = CreateAdmin(ctx, admin.Profile{Username: "staff", Password: "123456", Phone: "13700000000"})
+	assert.Nil(t, err)
+
+	// 普通管理员收不到验证码，但是接口同样返回成功
+	assert.Nil(t, SendCode(ctx, "13700000000"))
+	assert.Empty(t, box["13700000000"])
+
+	assert.Nil(t, SendCode(ctx, "13900000000"))
+	assert.Len(t, box["13900000000"], 6)
+	assert.ErrorIs(t, SendCode(ctx, "13900000000"), ErrCodeTooFrequent)
+
+	_, err = LoginByPhone(ctx, "13900000000", "000000x")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+
+	p, err := LoginByPhone(ctx, "13900000000", box["13900000000"])
+	assert.Nil(t, err)
+	c, err := jwt.Verify(p.AccessToken, jwt.TypeAccess)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(id), c.UID)
+
+	// 验证码只能使用一次
+	_, err = LoginByPhone(ctx, "13900000000", box["13900000000"])
+	assert.ErrorIs(t, err, ErrInvalidCode)
+}
+
+func TestRefreshAndRevoke(t *testing.T) {
+	ctx := context.TODO()
+
+	id, err := CreateAdmin(ctx, admin.Profile{Username: "refresh", Password: "123456"})
+	assert.Nil(t, err)
+
+	p, err := IssueToken(ctx, admin.Profile{ID: id})
+	assert.Nil(t, err)
+
+	np, err := RefreshToken(ctx, p.RefreshToken)
+	assert.Nil(t, err)
+
+	// 刷新后旧的 token 失效，refresh token 不能重复使用
+	_, err = RefreshToken(ctx, p.RefreshToken)
+	assert.ErrorIs(t, err, ErrRevokedToken)
+
+	old, _ := jwt.Verify(p.AccessToken, jwt.TypeAccess)
+	revoked, err := IsRevoked(ctx, old)
+	assert.Nil(t, err)
+	assert.True(t, revoked)
+
+	_, err = RefreshToken(ctx, np.AccessToken)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+	// 退出登录
+	c, _ := jwt.Verify(np.AccessToken, jwt.TypeAccess)
+	revoked, err = IsRevoked(ctx, c)
+	assert.Nil(t, err)
+	assert.False(t, revoked)
+
+	assert.Nil(t, RevokeToken(ctx, c.Id))
+	revoked, err = IsRevoked(ctx, c)
+	assert.Nil(t, err)
+	assert.True(t, revoked)
+
+	_, err = RefreshToken(ctx, np.RefreshToken)
+	assert.ErrorIs(t, err, ErrRevokedToken)
+}
+
+func TestCreateAdmin(t *testing.T) {
+	ctx := context.TODO()
+
+	_, err := CreateAdmin(ctx, admin.Profile{Username: "dup", Password: "123456"})
+	assert.Nil(t, err)
+
+	_, err = CreateAdmin(ctx, admin.Profile{Username: "dup", Password: "123456"})
+	assert.Error(t, err)
+}
+
+func TestCreateAdmin_Phone(t *testing.T) {
+	ctx := context.TODO()
+
+	// 只有手机号的超级管理员可以有多个
+	_, err := CreateAdmin(ctx, admin.Profile{Phone: "13800000001", RoleType: admin.RoleSuper})
+	assert.Nil(t, err)
+	_, err = CreateAdmin(ctx, admin.Profile{Phone: "13800000002", RoleType: admin.RoleSuper})
+	assert.Nil(t, err)
+
+	p, err := admin.QueryByPhone(ctx, "13800000002")
+	assert.Nil(t, err)
+	assert.Equal(t, "", p.Username)
+
+	// 手机号不能重复
+	_, err = CreateAdmin(ctx, admin.Profile{Username: "phone", Password: "123456", Phone: "13800000001"})
+	var e *errors.Error
+	assert.ErrorAs(t, err, &e)
+	assert.Equal(t, http.StatusConflict, e.HTTPCode())
+}
@@ -0,0 +1,151 @@
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"nautilus/dao/admin"
+	"nautilus/pkg/errors"
+	"nautilus/pkg/log"
+)
+
+const (
+	// codeTTL 验证码有效期
+	codeTTL = 5 * time.Minute
+	// codeInterval 同一个手机号两次发送的最小间隔
+	codeInterval = time.Minute
+	// codeAttempts 验证码最多校验次数，超过后需要重新发送
+	codeAttempts = 5
+)
+
+// ErrCodeTooFrequent 验证码发送太频繁
+var ErrCodeTooFrequent = errors.NewBadRequest("verification code sent too frequently").WithReason("CODE_TOO_FREQUENT").WithCode(20004)
+
+// CodeStore 短信验证码存储
+type CodeStore interface {
+	// Save 保存验证码，发送间隔内重复保存返回 false
+	Save(phone, code string) bool
+	// Verify 校验验证码，校验成功后验证码失效
+	Verify(phone, code string) bool
+}
+
+// Sender 发送短信验证码
+type Sender func(ctx context.Context, phone, code string) error
+
+var (
+	// Codes 验证码存储，默认保存在内存中，多实例部署时需要替换为共享存储
+	Codes CodeStore = NewMemoryCodeStore()
+	// SendSMS 短信发送，默认只打印日志，接入短信服务后替换
+	SendSMS Sender = logSender
+)
+
+// SendCode 向超级管理员的手机号发送登录验证码
+// 手机号不存在或者不是超级管理员时不发送，但是同样返回成功，避免被用来探测手机号
+func SendCode(ctx context.Context, phone string) (err error) {
+	code, err := newCode()
+	if err != nil {
+		return
+	}
+
+	// 所有手机号都限制发送频率，否则可以通过是否限频区分手机号
+	if !Codes.Save(phone, code) {
+		return ErrCodeTooFrequent
+	}
+
+	u, err := admin.QueryByPhone(ctx, phone)
+	if err != nil {
+		return
+	}
+
+	if u.ID == 0 || u.RoleType != admin.RoleSuper {
+		log.Get(ctx).WithField("phone", phone).Info("skip sending code to non super admin")
+		return
+	}
+
+	return SendSMS(ctx, phone, code)
+}
+
+// logSender 只打印验证码，用于本地开发
+func logSender(ctx context.Context, phone, code string) error {
+	log.Get(ctx).WithFields(log.Fields{"phone": phone, "code": code}).Warn("sms sender is not configured")
+	return nil
+}
+
+// newCode 生成6位数字验证码
+func newCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// codeEntry 内存中的验证码
+type codeEntry struct {
+	code     string
+	sentAt   time.Time
+	attempts int
+}
+
+// memoryCodeStore 内存验证码存储
+type memoryCodeStore struct {
+	mu    sync.Mutex
+	codes map[string]*codeEntry
+	now   func() time.Time
+}
+
+// NewMemoryCodeStore 创建内存验证码存储
+func NewMemoryCodeStore() CodeStore {
+	return &memoryCodeStore{codes: map[string]*codeEntry{}, now: time.Now}
+}
+
+// Save 保存验证码
+func (s *memoryCodeStore) Save(phone, code string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if e, ok := s.codes[phone]; ok && now.Sub(e.sentAt) < codeInterval {
+		return false
+	}
+
+	// 顺便清理过期的验证码
+	for k, e := range s.codes {
+		if now.Sub(e.sentAt) > codeTTL {
+			delete(s.codes, k)
+		}
+	}
+
+	s.codes[phone] = &codeEntry{code: code, sentAt: now}
+	return true
+}
+
+// Verify 校验验证码
+func (s *memoryCodeStore) Verify(phone, code string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.codes[phone]
+	if !ok || code == "" {
+		return false
+	}
+
+	if s.now().Sub(e.sentAt) > codeTTL || e.attempts >= codeAttempts {
+		delete(s.codes, phone)
+		return false
+	}
+
+	e.attempts++
+	if subtle.ConstantTimeCompare([]byte(e.code), []byte(code)) != 1 {
+		return false
+	}
+
+	delete(s.codes, phone)
+	return true
+}
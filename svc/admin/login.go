@@ -0,0 +1,115 @@
+package admin
+
+import (
+	"context"
+
+	"nautilus/dao/admin"
+	"nautilus/pkg/errors"
+	"nautilus/pkg/jwt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrLoginFailed 用户名或密码错误，不区分用户不存在和密码错误
+	ErrLoginFailed = errors.NewAuthorization("wrong username or password").WithReason("LOGIN_FAILED").WithCode(20001)
+	// ErrPhoneLoginRequired 超级管理员必须通过手机号登录
+	ErrPhoneLoginRequired = errors.NewAuthorization("super admin must login by phone").WithReason("PHONE_LOGIN_REQUIRED").WithCode(20002)
+	// ErrInvalidCode 验证码错误或已过期
+	ErrInvalidCode = errors.NewAuthorization("invalid verification code").WithReason("CODE_INVALID").WithCode(20003)
+)
+
+// dummyHash 用户不存在时也做一次 bcrypt 比较，避免通过响应时间判断用户名是否存在
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("nautilus"), bcrypt.DefaultCost)
+
+// HashPassword 使用 bcrypt 哈希密码
+func HashPassword(password string) (string, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// CreateAdmin 创建管理员，密码哈希后保存
+func CreateAdmin(ctx context.Context, p admin.Profile) (id int64, err error) {
+	var u admin.Profile
+
+	// 超级管理员可以只有手机号，空用户名存为 NULL，不参与唯一性检查
+	if p.Username != "" {
+		if u, err = admin.QueryByUsername(ctx, p.Username); err != nil {
+			return
+		}
+
+		if u.ID != 0 {
+			err = errors.NewConflict("username", p.Username)
+			return
+		}
+	}
+
+	// 手机号登录时根据手机号查找管理员，不能重复
+	if p.Phone != "" {
+		if u, err = admin.QueryByPhone(ctx, p.Phone); err != nil {
+			return
+		}
+
+		if u.ID != 0 {
+			err = errors.NewConflict("phone", p.Phone)
+			return
+		}
+	}
+
+	if p.Password, err = HashPassword(p.Password); err != nil {
+		return
+	}
+
+	return admin.CreateAdmin(ctx, p)
+}
+
+// LoginByPassword 普通管理员通过用户名密码登录
+func LoginByPassword(ctx context.Context, username, password string) (p jwt.Pair, err error) {
+	u, err := admin.QueryByUsername(ctx, username)
+	if err != nil {
+		return
+	}
+
+	if u.ID == 0 {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		err = ErrLoginFailed
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)) != nil {
+		err = ErrLoginFailed
+		return
+	}
+
+	if u.RoleType == admin.RoleSuper {
+		err = ErrPhoneLoginRequired
+		return
+	}
+
+	return IssueToken(ctx, u)
+}
+
+// LoginByPhone 超级管理员通过手机号和短信验证码登录
+func LoginByPhone(ctx context.Context, phone, code string) (p jwt.Pair, err error) {
+	if !Codes.Verify(phone, code) {
+		err = ErrInvalidCode
+		return
+	}
+
+	u, err := admin.QueryByPhone(ctx, phone)
+	if err != nil {
+		return
+	}
+
+	// 验证码只会发给超级管理员，这里再检查一次
+	if u.ID == 0 || u.RoleType != admin.RoleSuper {
+		err = ErrInvalidCode
+		return
+	}
+
+	return IssueToken(ctx, u)
+}
@@ -0,0 +1,104 @@
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"nautilus/dao/admin"
+	"nautilus/pkg/errors"
+	"nautilus/pkg/jwt"
+)
+
+var (
+	// ErrInvalidRefreshToken refresh token 不正确或已过期
+	ErrInvalidRefreshToken = errors.NewAuthorization("invalid refresh token").WithReason("REFRESH_TOKEN_INVALID").WithCode(20005)
+	// ErrRevokedToken 会话已注销，例如已经退出登录或者 refresh token 已经使用过
+	ErrRevokedToken = errors.NewAuthorization("token is revoked").WithReason("TOKEN_REVOKED").WithCode(20006)
+)
+
+// IssueToken 登录成功后创建会话，签发 access/refresh token
+func IssueToken(ctx context.Context, u admin.Profile) (p jwt.Pair, err error) {
+	sid, err := newSessionID()
+	if err != nil {
+		return
+	}
+
+	if _, err = admin.CreateToken(ctx, admin.Token{UID: u.ID, Key: sid}); err != nil {
+		return
+	}
+
+	return jwt.NewPair(uint64(u.ID), u.Role(), sid)
+}
+
+// RefreshToken 使用 refresh token 换取新的 token
+// 每次刷新都会更换会话 id，旧的 access/refresh token 随之失效，refresh token 只能使用一次
+// 新 token 中的角色重新从数据库读取，角色变更在刷新后生效
+func RefreshToken(ctx context.Context, refresh string) (p jwt.Pair, err error) {
+	c, err := jwt.Verify(refresh, jwt.TypeRefresh)
+	if err != nil {
+		err = ErrInvalidRefreshToken.WithCause(err)
+		return
+	}
+
+	u, err := admin.QueryByUID(ctx, int64(c.UID))
+	if err != nil {
+		return
+	}
+
+	if u.ID == 0 {
+		err = ErrRevokedToken
+		return
+	}
+
+	sid, err := newSessionID()
+	if err != nil {
+		return
+	}
+
+	ok, err := admin.ReplaceTokenKey(ctx, c.Id, sid)
+	if err != nil {
+		return
+	}
+
+	if !ok {
+		err = ErrRevokedToken
+		return
+	}
+
+	return jwt.NewPair(c.UID, u.Role(), sid)
+}
+
+// RevokeToken 退出登录，删除会话，该会话签发的 token 都不能再使用
+func RevokeToken(ctx context.Context, sid string) (err error) {
+	if sid == "" {
+		return
+	}
+
+	return admin.DeleteTokenByKey(ctx, sid)
+}
+
+// IsRevoked 判断 token 所属的会话是否已经注销，给 middleware.Auth 使用
+// 没有会话 id 的 token 无法注销，一律当作已注销
+func IsRevoked(ctx context.Context, c *jwt.Claims) (bool, error) {
+	if c.Id == "" {
+		return true, nil
+	}
+
+	t, err := admin.QueryTokenByKey(ctx, c.Id)
+	if err != nil {
+		return false, err
+	}
+
+	return t.ID == 0 || t.UID != int64(c.UID), nil
+}
+
+// newSessionID 生成随机的会话 id
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}